@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+const (
+	headerAPIKey = "X-Api-Key"
+	apiKeyPrefix = "ik_"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type APIKey struct {
+	ID   int64
+	Name string
+	Role string
+	// UserID binds a customer key to its user, it is zero for the other roles.
+	UserID int64
+	// TenantID is empty for the keys allowed to act on any tenant.
+	TenantID string
+	Scopes   []string
+}
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create stores the hash of the key of apiKey, the key itself is never stored.
+func (r *APIKeyRepository) Create(ctx context.Context, apiKey APIKey, key string) (_ int64, err error) {
+	ctx, span := storage.StartSpan(ctx, "auth.APIKeyRepository.Create", storage.OperationInsert, "api_keys")
+	defer storage.EndSpan(span, &err)
+
+	query := `
+		INSERT INTO jump.public.api_keys (name, key_hash, role, user_id, tenant_id, scopes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`
+
+	userID := sql.NullInt64{Int64: apiKey.UserID, Valid: apiKey.UserID != 0}
+	tenantID := sql.NullString{String: apiKey.TenantID, Valid: apiKey.TenantID != ""}
+	var id int64
+	row := r.db.QueryRowContext(ctx, storage.Dialect(r.db, query), apiKey.Name, HashAPIKey(key), apiKey.Role, userID, tenantID, pq.Array(apiKey.Scopes))
+	if err := row.Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return id, nil
+}
+
+// GetByHash returns the API key matching hash unless it was revoked.
+func (r *APIKeyRepository) GetByHash(ctx context.Context, hash string) (_ *APIKey, err error) {
+	ctx, span := storage.StartSpan(ctx, "auth.APIKeyRepository.GetByHash", storage.OperationSelect, "api_keys")
+	defer storage.EndSpan(span, &err)
+
+	query := `
+		SELECT id, name, role, COALESCE(user_id, 0), COALESCE(tenant_id, ''), scopes
+		FROM jump.public.api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL
+	`
+
+	key := &APIKey{}
+	err = r.db.QueryRowContext(ctx, storage.Dialect(r.db, query), hash).Scan(&key.ID, &key.Name, &key.Role, &key.UserID, &key.TenantID, pq.Array(&key.Scopes))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return key, nil
+}
+
+// GenerateAPIKey returns a new random API key, it is only shown once to its owner.
+func GenerateAPIKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("rand.Read: %w", err)
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashAPIKey returns the hex encoded SHA-256 of key, API keys are random enough for a fast unsalted hash.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyAuthenticator authenticates the requests carrying an API key in the X-Api-Key header or as an
+// "ApiKey" authorization.
+type APIKeyAuthenticator struct {
+	apiKeyRepository interface {
+		GetByHash(ctx context.Context, hash string) (*APIKey, error)
+	}
+}
+
+func NewAPIKeyAuthenticator(apiKeyRepository *APIKeyRepository) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{apiKeyRepository: apiKeyRepository}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(headerAPIKey)
+	if key == "" {
+		var ok bool
+		if key, ok = strings.CutPrefix(r.Header.Get(echo.HeaderAuthorization), "ApiKey "); !ok {
+			return nil, ErrNoCredentials
+		}
+	}
+
+	apiKey, err := a.apiKeyRepository.GetByHash(r.Context(), HashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("apiKeyRepository.GetByHash: %w", err)
+	}
+
+	return &Principal{
+		Subject:  apiKey.Name,
+		Method:   MethodAPIKey,
+		Role:     apiKey.Role,
+		UserID:   apiKey.UserID,
+		TenantID: apiKey.TenantID,
+		Scopes:   apiKey.Scopes,
+	}, nil
+}
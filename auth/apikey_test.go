@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyRepository_Create(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewAPIKeyRepository(db)
+
+	// Mock the expected query and result, only the hash of the key is stored
+	mock.ExpectQuery("INSERT INTO jump.public.api_keys (name, key_hash, role, user_id, tenant_id, scopes) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id").
+		WithArgs("portal", HashAPIKey("ik_key"), RoleCustomer, int64(7), "acme", "{\"invoices:read\"}").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	// Call the Create method
+	id, err := repo.Create(context.Background(), APIKey{Name: "portal", Role: RoleCustomer, UserID: 7, TenantID: "acme", Scopes: []string{ScopeInvoicesRead}}, "ik_key")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), id)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestAPIKeyAuthenticator_Authenticate(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	a := NewAPIKeyAuthenticator(NewAPIKeyRepository(db))
+	query := "SELECT id, name, role, COALESCE(user_id, 0), COALESCE(tenant_id, ''), scopes FROM jump.public.api_keys WHERE key_hash = $1 AND revoked_at IS NULL"
+
+	// Mock a known key and an unknown or revoked one
+	mock.ExpectQuery(query).
+		WithArgs(HashAPIKey("ik_valid")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "user_id", "tenant_id", "scopes"}).AddRow(1, "billing", RoleFinance, 0, "acme", "{invoices:read,invoices:write}"))
+	mock.ExpectQuery(query).
+		WithArgs(HashAPIKey("ik_revoked")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "user_id", "tenant_id", "scopes"}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Api-Key", "ik_valid")
+	p, err := a.Authenticate(r)
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "billing", Method: MethodAPIKey, Role: RoleFinance, TenantID: "acme", Scopes: []string{ScopeInvoicesRead, ScopeInvoicesWrite}}, p)
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "ApiKey ik_revoked")
+	_, err = a.Authenticate(r)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = a.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.ErrorIs(t, err, ErrNoCredentials)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	a, err := GenerateAPIKey()
+	require.NoError(t, err)
+	b, err := GenerateAPIKey()
+	require.NoError(t, err)
+
+	assert.NotEqual(t, a, b)
+	assert.Len(t, HashAPIKey(a), 64)
+}
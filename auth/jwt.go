@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	ErrNoVerificationKey = errors.New("neither an HMAC secret nor a JWKS is configured")
+	ErrUnknownKey        = errors.New("unknown key")
+)
+
+// JWTAuthenticator verifies bearer tokens signed with an HMAC secret or with one of the keys of a JWKS.
+type JWTAuthenticator struct {
+	secret []byte
+	keys   map[string]crypto.PublicKey
+	parser *jwt.Parser
+}
+
+// NewJWTAuthenticator accepts the tokens signed with secret when it is not empty and the tokens signed with the
+// key of keys matching their kid header, issuer and audience are only checked when set.
+func NewJWTAuthenticator(secret []byte, keys map[string]crypto.PublicKey, issuer, audience string) (*JWTAuthenticator, error) {
+	var methods []string
+	if len(secret) > 0 {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if len(keys) > 0 {
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA")
+	}
+	if len(methods) == 0 {
+		return nil, ErrNoVerificationKey
+	}
+
+	options := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		options = append(options, jwt.WithAudience(audience))
+	}
+
+	return &JWTAuthenticator{secret: secret, keys: keys, parser: jwt.NewParser(options...)}, nil
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	// Scope is the space separated list of RFC 8693, Scp the array some identity providers use instead.
+	Scope string   `json:"scope,omitempty"`
+	Scp   []string `json:"scp,omitempty"`
+	Role  string   `json:"role"`
+	// UserID binds a customer token to its user.
+	UserID int64  `json:"user_id,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	token, ok := strings.CutPrefix(r.Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	var c claims
+	if _, err := a.parser.ParseWithClaims(token, &c, a.key); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+
+	return &Principal{
+		Subject:  c.Subject,
+		Method:   MethodJWT,
+		Role:     c.Role,
+		UserID:   c.UserID,
+		TenantID: c.Tenant,
+		Scopes:   append(strings.Fields(c.Scope), c.Scp...),
+	}, nil
+}
+
+func (a *JWTAuthenticator) key(token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return a.secret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := a.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func bearerRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+func TestJWTAuthenticator_HMAC(t *testing.T) {
+	secret := []byte("secret")
+	a, err := NewJWTAuthenticator(secret, nil, "https://issuer", "invoices")
+	require.NoError(t, err)
+
+	sign := func(c jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(secret)
+		require.NoError(t, err)
+		return token
+	}
+	valid := jwt.MapClaims{
+		"sub":     "alice",
+		"iss":     "https://issuer",
+		"aud":     "invoices",
+		"exp":     time.Now().Add(time.Hour).Unix(),
+		"scope":   "invoices:read invoices:write",
+		"role":    RoleCustomer,
+		"user_id": 7,
+		"tenant":  "acme",
+	}
+
+	p, err := a.Authenticate(bearerRequest(sign(valid)))
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "alice", Method: MethodJWT, Role: RoleCustomer, UserID: 7, TenantID: "acme", Scopes: []string{ScopeInvoicesRead, ScopeInvoicesWrite}}, p)
+
+	for name, change := range map[string]func(c jwt.MapClaims){
+		"expired":      func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no expiry":    func(c jwt.MapClaims) { delete(c, "exp") },
+		"bad issuer":   func(c jwt.MapClaims) { c["iss"] = "https://other" },
+		"bad audience": func(c jwt.MapClaims) { c["aud"] = "other" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := jwt.MapClaims{}
+			for k, v := range valid {
+				c[k] = v
+			}
+			change(c)
+			_, err := a.Authenticate(bearerRequest(sign(c)))
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		})
+	}
+
+	t.Run("bad signature", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, valid).SignedString([]byte("other"))
+		require.NoError(t, err)
+		_, err = a.Authenticate(bearerRequest(token))
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("no credentials", func(t *testing.T) {
+		_, err := a.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.ErrorIs(t, err, ErrNoCredentials)
+	})
+}
+
+func TestJWTAuthenticator_JWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	set, err := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kid": "key-1",
+		"kty": "RSA",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, set, 0o600))
+
+	keys, err := LoadJWKS(path)
+	require.NoError(t, err)
+	a, err := NewJWTAuthenticator(nil, keys, "", "")
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{"sub": "bob", "exp": time.Now().Add(time.Hour).Unix(), "role": RoleFinance, "scp": []string{ScopeReportsRead}}
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+
+	p, err := a.Authenticate(bearerRequest(sign("key-1")))
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Subject: "bob", Method: MethodJWT, Role: RoleFinance, Scopes: []string{ScopeReportsRead}}, p)
+
+	_, err = a.Authenticate(bearerRequest(sign("key-2")))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// An HMAC token must not be accepted when no secret is configured.
+	hmacToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte{})
+	require.NoError(t, err)
+	_, err = a.Authenticate(bearerRequest(hmacToken))
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestNewJWTAuthenticator_NoKey(t *testing.T) {
+	_, err := NewJWTAuthenticator(nil, map[string]crypto.PublicKey{}, "", "")
+	assert.ErrorIs(t, err, ErrNoVerificationKey)
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/emilien-puget/invoice_microservice/logging"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	// ErrNoCredentials is returned by an Authenticator when the request does not carry its kind of credentials.
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// ErrInvalidPrincipal is returned by Authenticate for credentials that are valid but grant a principal that could not
+// be scoped, see Principal.Validate.
+var ErrInvalidPrincipal = errors.New("invalid principal")
+
+// Authenticate authenticates r with the first authenticator that finds credentials in it, it returns
+// ErrNoCredentials when none did.
+func Authenticate(r *http.Request, authenticators ...Authenticator) (*Principal, error) {
+	for _, authenticator := range authenticators {
+		p, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %s principal %q: %w", ErrInvalidPrincipal, p.Method, p.Subject, err)
+		}
+		return p, nil
+	}
+	return nil, ErrNoCredentials
+}
+
+// Middleware authenticates the request and stores the principal in the request context.
+func Middleware(authenticators ...Authenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p, err := Authenticate(c.Request(), authenticators...)
+			if err != nil {
+				logger := logging.FromContext(c.Request().Context())
+				switch {
+				case errors.Is(err, ErrInvalidPrincipal):
+					logger.Warn("principal rejected", slog.Any("error", err))
+				case !errors.Is(err, ErrNoCredentials) && !errors.Is(err, ErrInvalidCredentials):
+					logger.Error("authentication failed", slog.Any("error", err))
+				}
+				return unauthorized(c)
+			}
+
+			c.SetRequest(c.Request().WithContext(NewContext(c.Request().Context(), p)))
+			return next(c)
+		}
+	}
+}
+
+func unauthorized(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+	return problem.New(http.StatusUnauthorized, problem.CodeUnauthorized, "authentication required")
+}
+
+// RequirePermission rejects the requests whose principal was not granted permission, it must run after Middleware.
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p, ok := FromEcho(c)
+			if !ok {
+				return unauthorized(c)
+			}
+			if !p.Can(permission) {
+				return problem.New(http.StatusForbidden, problem.CodeMissingPermission, "missing permission "+permission)
+			}
+			return next(c)
+		}
+	}
+}
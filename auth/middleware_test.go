@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type authenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f authenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+func TestMiddleware(t *testing.T) {
+	none := authenticatorFunc(func(r *http.Request) (*Principal, error) { return nil, ErrNoCredentials })
+	invalid := authenticatorFunc(func(r *http.Request) (*Principal, error) { return nil, ErrInvalidCredentials })
+	reader := authenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return &Principal{Subject: "reader", Role: RoleFinance, Scopes: []string{ScopeInvoicesRead}}, nil
+	})
+	customer := authenticatorFunc(func(r *http.Request) (*Principal, error) {
+		return &Principal{Subject: "reader", Role: RoleCustomer, TenantID: "acme"}, nil
+	})
+
+	e := echo.New()
+	handler := func(c echo.Context) error {
+		p, _ := FromEcho(c)
+		return c.String(http.StatusOK, p.Subject)
+	}
+
+	for name, test := range map[string]struct {
+		authenticators []Authenticator
+		scope          string
+		status         int
+	}{
+		"authenticated":      {[]Authenticator{none, reader}, ScopeInvoicesRead, http.StatusOK},
+		"no credentials":     {[]Authenticator{none}, ScopeInvoicesRead, http.StatusUnauthorized},
+		"invalid credential": {[]Authenticator{invalid, reader}, ScopeInvoicesRead, http.StatusUnauthorized},
+		"missing scope":      {[]Authenticator{reader}, ScopeInvoicesWrite, http.StatusForbidden},
+		"customer sans user": {[]Authenticator{customer}, ScopeInvoicesRead, http.StatusUnauthorized},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+			err := Middleware(test.authenticators...)(RequirePermission(test.scope)(handler))(c)
+			if test.status == http.StatusOK {
+				assert.NoError(t, err)
+				assert.Equal(t, "reader", rec.Body.String())
+				return
+			}
+			var problemErr *problem.Error
+			assert.ErrorAs(t, err, &problemErr)
+			assert.Equal(t, test.status, problemErr.Status)
+		})
+	}
+}
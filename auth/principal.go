@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+)
+
+// Scopes are the permissions granted by roles, a token or an API key listing scopes only gets those.
+const (
+	ScopeUsersRead         = "users:read"
+	ScopeInvoicesRead      = "invoices:read"
+	ScopeInvoicesWrite     = "invoices:write"
+	ScopeTransactionsWrite = "transactions:write"
+	ScopeExportsRead       = "exports:read"
+	ScopeExportsWrite      = "exports:write"
+	ScopeReportsRead       = "reports:read"
+	ScopeWebhooksManage    = "webhooks:manage"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Subject is the sub claim of a token or the name of an API key.
+	Subject string
+	Method  string
+	Role    string
+	// UserID is the user a customer acts as, it is zero for the other roles.
+	UserID int64
+	// TenantID binds the principal to a tenant, principals without one select it per request.
+	TenantID string
+	Scopes   []string
+}
+
+// Can reports whether the role of the principal grants permission and, when the credentials were restricted to
+// scopes, whether permission is one of them.
+func (p *Principal) Can(permission string) bool {
+	if !contains(rolePermissions[p.Role], permission) {
+		return false
+	}
+	return len(p.Scopes) == 0 || contains(p.Scopes, permission)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal authenticated by Middleware.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// FromEcho is FromContext for the request of c.
+func FromEcho(c echo.Context) (*Principal, bool) {
+	return FromContext(c.Request().Context())
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	// RoleCustomer only reads its own user, invoices and statement.
+	RoleCustomer = "customer"
+	// RoleFinance reads everything, issues invoices, starts exports and manages the webhooks.
+	RoleFinance = "finance"
+	// RolePaymentProcessor records the payments of invoices.
+	RolePaymentProcessor = "payment_processor"
+)
+
+var rolePermissions = map[string][]string{
+	RoleCustomer:         {ScopeUsersRead, ScopeInvoicesRead},
+	RoleFinance:          {ScopeUsersRead, ScopeInvoicesRead, ScopeInvoicesWrite, ScopeExportsRead, ScopeExportsWrite, ScopeReportsRead, ScopeWebhooksManage},
+	RolePaymentProcessor: {ScopeTransactionsWrite, ScopeInvoicesRead},
+}
+
+var (
+	ErrUnknownRole           = errors.New("unknown role")
+	ErrCustomerWithoutUser   = errors.New("customer principal without user")
+	ErrCustomerWithoutTenant = errors.New("customer principal without tenant")
+)
+
+// Validate rejects the principals that could not be scoped: unknown roles and customers not bound to a user and
+// its tenant.
+func (p *Principal) Validate() error {
+	if _, ok := rolePermissions[p.Role]; !ok {
+		return fmt.Errorf("%w %q", ErrUnknownRole, p.Role)
+	}
+	if p.Role == RoleCustomer && p.UserID == 0 {
+		return ErrCustomerWithoutUser
+	}
+	if p.Role == RoleCustomer && p.TenantID == "" {
+		return ErrCustomerWithoutTenant
+	}
+	return nil
+}
+
+// ScopedUserID returns the user the data read on behalf of the principal of ctx is restricted to, zero when it is
+// not restricted. Repositories filter their reads with it so a customer gets not found for the data of other users.
+func ScopedUserID(ctx context.Context) int64 {
+	p, ok := FromContext(ctx)
+	if !ok || p.Role != RoleCustomer {
+		return 0
+	}
+	return p.UserID
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipal_Can(t *testing.T) {
+	customer := &Principal{Role: RoleCustomer, UserID: 1}
+	finance := &Principal{Role: RoleFinance}
+	processor := &Principal{Role: RolePaymentProcessor}
+	restricted := &Principal{Role: RoleFinance, Scopes: []string{ScopeReportsRead, ScopeTransactionsWrite}}
+
+	assert.True(t, customer.Can(ScopeInvoicesRead))
+	assert.False(t, customer.Can(ScopeInvoicesWrite))
+	assert.False(t, customer.Can(ScopeReportsRead))
+
+	assert.True(t, finance.Can(ScopeExportsRead))
+	assert.True(t, finance.Can(ScopeExportsWrite))
+	assert.False(t, customer.Can(ScopeExportsWrite), "only finance starts exports")
+	assert.False(t, processor.Can(ScopeExportsWrite))
+	assert.False(t, finance.Can(ScopeTransactionsWrite), "only the payment processor records transactions")
+	assert.True(t, processor.Can(ScopeTransactionsWrite))
+
+	// Scopes restrict a role, they never extend it.
+	assert.True(t, restricted.Can(ScopeReportsRead))
+	assert.False(t, restricted.Can(ScopeExportsRead))
+	assert.False(t, restricted.Can(ScopeTransactionsWrite))
+
+	assert.False(t, (&Principal{}).Can(ScopeUsersRead))
+}
+
+func TestScopedUserID(t *testing.T) {
+	assert.Zero(t, ScopedUserID(context.Background()))
+	assert.Zero(t, ScopedUserID(NewContext(context.Background(), &Principal{Role: RoleFinance})))
+	assert.Equal(t, int64(7), ScopedUserID(NewContext(context.Background(), &Principal{Role: RoleCustomer, UserID: 7})))
+}
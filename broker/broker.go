@@ -0,0 +1,28 @@
+package broker
+
+import (
+	"context"
+	"time"
+)
+
+// Message is a message delivered by a Subscriber, the handler settles it with exactly one of Ack, Nak and
+// DeadLetter. A message left unsettled is delivered again by the broker.
+type Message interface {
+	Data() []byte
+	// Deliveries is the number of times the message was delivered, starting at 1.
+	Deliveries() int
+	Ack(ctx context.Context) error
+	// Nak asks for the message to be delivered again after delay.
+	Nak(ctx context.Context, delay time.Duration) error
+	// DeadLetter sets the message aside with the reason it cannot be processed, it is not delivered again.
+	DeadLetter(ctx context.Context, reason string) error
+}
+
+// Handler processes a message, it is called for one message at a time.
+type Handler func(ctx context.Context, msg Message)
+
+type Subscriber interface {
+	// Subscribe calls handler for every message until ctx is done, then waits for the message in flight. The
+	// context of the handler is not cancelled with ctx so that the message in flight is settled.
+	Subscribe(ctx context.Context, handler Handler) error
+}
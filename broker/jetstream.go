@@ -0,0 +1,109 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// HeaderDeadLetterReason carries the reason a message was set aside, on the dead letter subject.
+const HeaderDeadLetterReason = "Dead-Letter-Reason"
+
+// JetStreamConfig selects the messages consumed. The stream is owned by the publisher, the dead letter subject must
+// be captured by a stream for the dead letters to be kept.
+type JetStreamConfig struct {
+	Stream            string
+	Subject           string
+	Durable           string
+	DeadLetterSubject string
+	// AckWait is how long a message may stay unsettled before it is delivered again.
+	AckWait time.Duration
+}
+
+// JetStream consumes a durable pull consumer with explicit acks.
+type JetStream struct {
+	js     jetstream.JetStream
+	config JetStreamConfig
+}
+
+func NewJetStream(nc *nats.Conn, config JetStreamConfig) (*JetStream, error) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, fmt.Errorf("jetstream.New: %w", err)
+	}
+	return &JetStream{js: js, config: config}, nil
+}
+
+// Subscribe creates or updates the durable consumer and consumes it until ctx is done. The number of deliveries is
+// left unbounded on the server, the handler decides when a message is dead.
+func (s *JetStream) Subscribe(ctx context.Context, handler Handler) error {
+	consumer, err := s.js.CreateOrUpdateConsumer(ctx, s.config.Stream, jetstream.ConsumerConfig{
+		Durable:       s.config.Durable,
+		FilterSubject: s.config.Subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       s.config.AckWait,
+		MaxDeliver:    -1,
+		// One message at a time, so that the payments are applied in the order they were published
+		MaxAckPending: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("create consumer: %w", err)
+	}
+
+	handlerCtx := context.WithoutCancel(ctx)
+	consumeContext, err := consumer.Consume(func(msg jetstream.Msg) {
+		handler(handlerCtx, &jetStreamMessage{msg: msg, js: s.js, deadLetterSubject: s.config.DeadLetterSubject})
+	})
+	if err != nil {
+		return fmt.Errorf("consume: %w", err)
+	}
+
+	<-ctx.Done()
+	// The messages already fetched are handled before returning
+	consumeContext.Drain()
+	<-consumeContext.Closed()
+	return nil
+}
+
+type jetStreamMessage struct {
+	msg               jetstream.Msg
+	js                jetstream.JetStream
+	deadLetterSubject string
+}
+
+func (m *jetStreamMessage) Data() []byte {
+	return m.msg.Data()
+}
+
+func (m *jetStreamMessage) Deliveries() int {
+	metadata, err := m.msg.Metadata()
+	if err != nil {
+		return 1
+	}
+	return int(metadata.NumDelivered)
+}
+
+// Ack waits for the server to confirm, so that a message acked is not delivered again.
+func (m *jetStreamMessage) Ack(ctx context.Context) error {
+	return m.msg.DoubleAck(ctx)
+}
+
+func (m *jetStreamMessage) Nak(_ context.Context, delay time.Duration) error {
+	return m.msg.NakWithDelay(delay)
+}
+
+// DeadLetter publishes the message on the dead letter subject before terminating it, it is naked when the publish
+// fails so that it is not lost.
+func (m *jetStreamMessage) DeadLetter(ctx context.Context, reason string) error {
+	deadLetter := nats.NewMsg(m.deadLetterSubject)
+	deadLetter.Data = m.msg.Data()
+	deadLetter.Header.Set(HeaderDeadLetterReason, reason)
+	if _, err := m.js.PublishMsg(ctx, deadLetter); err != nil {
+		_ = m.msg.Nak()
+		return fmt.Errorf("publish dead letter: %w", err)
+	}
+	return m.msg.TermWithReason(reason)
+}
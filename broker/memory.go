@@ -0,0 +1,135 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// memoryQueueSize bounds the messages waiting in a Memory broker.
+const memoryQueueSize = 1024
+
+// ErrSettled is returned by the messages of a Memory broker settled more than once.
+var ErrSettled = errors.New("message already settled")
+
+// DeadLetter is a message set aside by the handler.
+type DeadLetter struct {
+	Data   []byte
+	Reason string
+}
+
+// Memory is an in-memory broker for the tests. Messages are delivered again right away when naked, the delay is
+// only recorded. A message settled twice returns ErrSettled.
+type Memory struct {
+	queue chan *memoryMessage
+
+	mu          sync.Mutex
+	acked       [][]byte
+	delays      []time.Duration
+	deadLetters []DeadLetter
+}
+
+func NewMemory() *Memory {
+	return &Memory{queue: make(chan *memoryMessage, memoryQueueSize)}
+}
+
+// Publish queues a message for the subscriber.
+func (m *Memory) Publish(data []byte) {
+	m.queue <- &memoryMessage{broker: m, data: data, deliveries: 1}
+}
+
+func (m *Memory) Subscribe(ctx context.Context, handler Handler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-m.queue:
+			handler(context.WithoutCancel(ctx), msg)
+		}
+	}
+}
+
+// Acked returns the data of the messages acked so far.
+func (m *Memory) Acked() [][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([][]byte(nil), m.acked...)
+}
+
+// Delays returns the delays asked by the naks so far.
+func (m *Memory) Delays() []time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]time.Duration(nil), m.delays...)
+}
+
+// DeadLetters returns the messages set aside so far.
+func (m *Memory) DeadLetters() []DeadLetter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]DeadLetter(nil), m.deadLetters...)
+}
+
+type memoryMessage struct {
+	broker     *Memory
+	data       []byte
+	deliveries int
+	// settled is guarded by the mutex of the broker.
+	settled bool
+}
+
+func (m *memoryMessage) Data() []byte {
+	return m.data
+}
+
+func (m *memoryMessage) Deliveries() int {
+	return m.deliveries
+}
+
+// settle marks the message settled, the mutex of the broker is held.
+func (m *memoryMessage) settle() error {
+	if m.settled {
+		return ErrSettled
+	}
+	m.settled = true
+	return nil
+}
+
+func (m *memoryMessage) Ack(context.Context) error {
+	m.broker.mu.Lock()
+	defer m.broker.mu.Unlock()
+
+	if err := m.settle(); err != nil {
+		return err
+	}
+	m.broker.acked = append(m.broker.acked, m.data)
+	return nil
+}
+
+func (m *memoryMessage) Nak(_ context.Context, delay time.Duration) error {
+	m.broker.mu.Lock()
+	if err := m.settle(); err != nil {
+		m.broker.mu.Unlock()
+		return err
+	}
+	m.broker.delays = append(m.broker.delays, delay)
+	m.broker.mu.Unlock()
+
+	m.broker.queue <- &memoryMessage{broker: m.broker, data: m.data, deliveries: m.deliveries + 1}
+	return nil
+}
+
+func (m *memoryMessage) DeadLetter(_ context.Context, reason string) error {
+	m.broker.mu.Lock()
+	defer m.broker.mu.Unlock()
+
+	if err := m.settle(); err != nil {
+		return err
+	}
+	m.broker.deadLetters = append(m.broker.deadLetters, DeadLetter{Data: m.data, Reason: reason})
+	return nil
+}
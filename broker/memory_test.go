@@ -0,0 +1,111 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// subscribe runs the subscriber of memory with handler until stop returns true.
+func subscribe(t *testing.T, memory *Memory, handler Handler, stop func() bool) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- memory.Subscribe(ctx, handler) }()
+
+	require.Eventually(t, stop, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestMemory_Ack(t *testing.T) {
+	memory := NewMemory()
+	memory.Publish([]byte("first"))
+	memory.Publish([]byte("second"))
+
+	var deliveries []int
+	subscribe(t, memory, func(ctx context.Context, msg Message) {
+		deliveries = append(deliveries, msg.Deliveries())
+		assert.NoError(t, msg.Ack(ctx))
+	}, func() bool { return len(memory.Acked()) == 2 })
+
+	assert.Equal(t, [][]byte{[]byte("first"), []byte("second")}, memory.Acked())
+	assert.Equal(t, []int{1, 1}, deliveries)
+	assert.Empty(t, memory.Delays())
+	assert.Empty(t, memory.DeadLetters())
+}
+
+func TestMemory_Nak(t *testing.T) {
+	memory := NewMemory()
+	memory.Publish([]byte("retried"))
+
+	var deliveries []int
+	subscribe(t, memory, func(ctx context.Context, msg Message) {
+		deliveries = append(deliveries, msg.Deliveries())
+		if msg.Deliveries() < 3 {
+			assert.NoError(t, msg.Nak(ctx, time.Duration(msg.Deliveries())*time.Second))
+			return
+		}
+		assert.NoError(t, msg.Ack(ctx))
+	}, func() bool { return len(memory.Acked()) == 1 })
+
+	assert.Equal(t, []int{1, 2, 3}, deliveries, "a naked message is delivered again")
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, memory.Delays())
+	assert.Equal(t, [][]byte{[]byte("retried")}, memory.Acked())
+}
+
+func TestMemory_DeadLetter(t *testing.T) {
+	memory := NewMemory()
+	memory.Publish([]byte("poison"))
+
+	deliveries := 0
+	subscribe(t, memory, func(ctx context.Context, msg Message) {
+		deliveries++
+		assert.NoError(t, msg.DeadLetter(ctx, "not json"))
+	}, func() bool { return len(memory.DeadLetters()) == 1 })
+
+	assert.Equal(t, []DeadLetter{{Data: []byte("poison"), Reason: "not json"}}, memory.DeadLetters())
+	assert.Equal(t, 1, deliveries, "a dead letter is not delivered again")
+	assert.Empty(t, memory.Acked())
+}
+
+func TestMemory_SettledOnce(t *testing.T) {
+	settle := map[string]func(ctx context.Context, msg Message) error{
+		"ack": func(ctx context.Context, msg Message) error {
+			return msg.Ack(ctx)
+		},
+		"nak": func(ctx context.Context, msg Message) error {
+			return msg.Nak(ctx, time.Second)
+		},
+		"dead letter": func(ctx context.Context, msg Message) error {
+			return msg.DeadLetter(ctx, "not json")
+		},
+	}
+
+	for first, settleFirst := range settle {
+		for second, settleSecond := range settle {
+			t.Run(first+" then "+second, func(t *testing.T) {
+				memory := NewMemory()
+				ctx := context.Background()
+				memory.Publish([]byte("once"))
+				msg := <-memory.queue
+
+				require.NoError(t, settleFirst(ctx, msg))
+				assert.ErrorIs(t, settleSecond(ctx, msg), ErrSettled)
+
+				// Only the first settlement is recorded
+				settled := len(memory.Acked()) + len(memory.Delays()) + len(memory.DeadLetters())
+				assert.Equal(t, 1, settled)
+				if first != "nak" {
+					assert.Empty(t, memory.queue, "the message is not delivered again")
+				} else {
+					assert.Len(t, memory.queue, 1, "the message is delivered once again")
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/configuration"
+)
+
+var ErrMissingAPIKeyName = errors.New("-name is required")
+
+// apiKeyCommand creates an API key and prints it, only its hash is stored so it cannot be shown again.
+//
+//	invoice_microservice apikey -name billing -role finance -scopes invoices:read,invoices:write
+//	invoice_microservice apikey -name portal -role customer -tenant default -user-id 42
+func apiKeyCommand(c *configuration.Postgres, args []string) error {
+	flags := flag.NewFlagSet("apikey", flag.ContinueOnError)
+	name := flags.String("name", "", "name of the API key, used as the principal subject")
+	role := flags.String("role", "", "role of the API key: customer, finance or payment_processor")
+	userID := flags.Int64("user-id", 0, "user a customer API key is bound to")
+	tenantID := flags.String("tenant", "", "tenant the API key is bound to, any tenant can be selected per request when empty")
+	scopes := flags.String("scopes", "", "comma separated scopes restricting the permissions of the role")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+	if *name == "" {
+		return ErrMissingAPIKeyName
+	}
+	apiKey := auth.APIKey{Name: *name, Role: *role, UserID: *userID, TenantID: *tenantID, Scopes: []string{}}
+	if *scopes != "" {
+		apiKey.Scopes = strings.Split(*scopes, ",")
+	}
+	principal := auth.Principal{Role: apiKey.Role, UserID: apiKey.UserID, TenantID: apiKey.TenantID}
+	if err := principal.Validate(); err != nil {
+		return fmt.Errorf("invalid api key: %w", err)
+	}
+
+	db, err := initDb(context.Background(), c)
+	if err != nil {
+		return fmt.Errorf("init db: %w", err)
+	}
+	defer db.Close()
+
+	key, err := auth.GenerateAPIKey()
+	if err != nil {
+		return fmt.Errorf("generate api key: %w", err)
+	}
+	id, err := auth.NewAPIKeyRepository(db).Create(context.Background(), apiKey, key)
+	if err != nil {
+		return fmt.Errorf("create api key: %w", err)
+	}
+
+	fmt.Printf("api key %d created: %s\n", id, key)
+	return nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/emilien-puget/invoice_microservice/configuration"
+)
+
+var ErrUnknownConfigCommand = errors.New("unknown config command, expected print")
+
+// configCommand prints the effective configuration, once the file, the environment and the flags are layered on top
+// of the defaults, with the secrets masked.
+//
+//	invoice_microservice -config config.yaml config print
+func configCommand(c *configuration.Api, args []string, w io.Writer) error {
+	if len(args) != 1 || args[0] != "print" {
+		return ErrUnknownConfigCommand
+	}
+	if err := configuration.Print(w, c); err != nil {
+		return fmt.Errorf("configuration.Print: %w", err)
+	}
+	return nil
+}
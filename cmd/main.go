@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"crypto"
 	"database/sql"
 	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,99 +15,404 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/caarlos0/env/v6"
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/broker"
 	"github.com/emilien-puget/invoice_microservice/configuration"
+	"github.com/emilien-puget/invoice_microservice/export"
+	"github.com/emilien-puget/invoice_microservice/grpcapi"
+	"github.com/emilien-puget/invoice_microservice/health"
 	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/lifecycle"
+	"github.com/emilien-puget/invoice_microservice/logging"
+	"github.com/emilien-puget/invoice_microservice/migrations"
+	"github.com/emilien-puget/invoice_microservice/openapi"
+	"github.com/emilien-puget/invoice_microservice/outbox"
+	"github.com/emilien-puget/invoice_microservice/payment"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/emilien-puget/invoice_microservice/report"
+	"github.com/emilien-puget/invoice_microservice/statement"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/emilien-puget/invoice_microservice/tracing"
 	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/emilien-puget/invoice_microservice/webhook"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo-contrib/echoprometheus"
 	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
-	_ "github.com/lib/pq"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 )
 
 var ErrStopSignalReceived = errors.New("stop signal received")
 
+var ErrUnknownCommand = errors.New("unknown command, expected apikey or config")
+
 // Version set via ldflags
 var Version = "local"
 
 const service = "invoice_microservice"
 
 func main() {
-	eCfg := configuration.Api{}
-	if err := env.Parse(&eCfg, (env.Options{RequiredIfNoDef: true})); err != nil {
-		log.Printf("%+v\n", err)
+	eCfg, args, err := configuration.Load(os.Args[1:], os.Environ())
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		slog.Error("load configuration", slog.Any("error", err))
+		os.Exit(-1)
+	}
+	level, err := logging.ParseLevel(eCfg.LogLevel)
+	if err != nil {
+		slog.Error("parse log level", slog.Any("error", err))
 		os.Exit(-1)
 	}
+	logger := logging.New(os.Stdout, level).With(slog.String("service", service), slog.String("version", Version))
+	slog.SetDefault(logger)
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "apikey":
+			err = apiKeyCommand(&eCfg.Postgres, args[1:])
+		case "config":
+			err = configCommand(&eCfg, args[1:], os.Stdout)
+		default:
+			err = fmt.Errorf("%w: %s", ErrUnknownCommand, args[0])
+		}
+		if err != nil {
+			slog.Error(args[0], slog.Any("error", err))
+			os.Exit(-1)
+		}
+		return
+	}
 
 	ctx, cl := Init()
 	defer cl(nil)
+	ctx = logging.NewContext(ctx, logger)
+
+	// The components are started in the order they are added and stopped in reverse order
+	components := lifecycle.NewManager(eCfg.ShutdownTimeout)
+
+	shutdownTracing, err := tracing.Setup(ctx, eCfg.TraceExporter, os.Stdout, service, Version)
+	if err != nil {
+		cl(fmt.Errorf("init tracing:%w", err))
+		return
+	}
+	components.Add(lifecycle.Component{Name: "tracing", Stop: shutdownTracing})
 
 	validate := validator.New()
+	validate.RegisterTagNameFunc(problem.JSONName)
 
 	e := echo.New()
-	defer e.Shutdown(context.Background())
+	e.HideBanner = true
+	e.HidePort = true
+	e.HTTPErrorHandler = problem.ErrorHandler
 
-	db, err := initDb(&eCfg.Postgres)
+	db, err := initDb(ctx, &eCfg.Postgres)
 	if err != nil {
 		cl(fmt.Errorf("init db:%w", err))
 		return
 	}
-	defer db.Close()
+	components.Add(lifecycle.Component{Name: "database", Stop: func(context.Context) error { return db.Close() }})
 
 	userRepository := user.NewUserRepository(db)
+	users := user.NewUsers(userRepository)
 	invoiceRepository := invoice.NewInvoiceRepository(db)
-	usersHandler := user.NewGetAllHandler(userRepository)
-	transactionHandler := invoice.NewDoTransactionHandler(invoiceRepository, userRepository, validate)
-	invoiceHandler := invoice.NewCreateInvoiceHandler(validate, invoiceRepository, userRepository)
-	e.Use(middleware.Logger())
-	e.Use(echoprometheus.NewMiddleware(service))
-	e.GET("/metrics", echoprometheus.NewHandler())
-	e.GET("/users", usersHandler.Handle)
-	e.POST("/invoice", invoiceHandler.Handle)
-	e.POST("/transaction", transactionHandler.Handle)
+	transactionRepository := invoice.NewTransactionRepository(db)
+	usersHandler := user.NewGetAllHandler(users)
+	outboxRepository := outbox.NewOutboxRepository(db)
+	transactor := storage.NewTransactor(db)
+	checks, err := initChecks(&eCfg.Health, db, outboxRepository)
+	if err != nil {
+		cl(fmt.Errorf("init checks:%w", err))
+		return
+	}
+	srv := initInternalSrv(eCfg.InternalPort, checks)
+	components.Add(lifecycle.Server("internal server", srv.Addr, srv.Serve, srv.Shutdown))
+	billing := initBilling(&eCfg.Invoice)
+	metrics := invoice.NewMetrics(prometheus.DefaultRegisterer, service, billing)
+	payments := invoice.NewPayments(invoiceRepository, userRepository, transactionRepository, outboxRepository, transactor, validate, metrics)
+	transactionHandler := invoice.NewDoTransactionHandler(payments)
+	invoices := invoice.NewInvoices(validate, invoiceRepository, userRepository, outboxRepository, transactor, metrics)
+	invoiceHandler := invoice.NewCreateInvoiceHandler(invoices)
+	importHandler := invoice.NewImportHandler(validate, invoices, userRepository)
+	getInvoiceHandler := invoice.NewGetHandler(invoiceRepository, userRepository, billing)
+	pdfRenderer, err := initPDFRenderer(&eCfg.Invoice)
+	if err != nil {
+		cl(fmt.Errorf("init pdf renderer:%w", err))
+		return
+	}
+	pdfHandler := invoice.NewGetPDFHandler(invoiceRepository, userRepository, billing, pdfRenderer)
+	statementRepository := statement.NewStatementRepository(db)
+	statementHandler := statement.NewGetHandler(statementRepository, userRepository, billing)
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, eCfg.Postgres.Database))
+	exporter := export.NewExporter(invoiceRepository, userRepository, transactionRepository)
+	exportJobs := export.NewJobs(exporter, exportDir(&eCfg.Export), eCfg.Export.Retention)
+	components.Add(lifecycle.Worker("export jobs", exportJobs.Run))
+	exportHandler := export.NewStreamHandler(exporter)
+	createExportJobHandler := export.NewCreateJobHandler(exportJobs)
+	getExportJobHandler := export.NewGetJobHandler(exportJobs)
+	downloadExportJobHandler := export.NewDownloadJobHandler(exportJobs)
+	h := handlers{
+		users:             usersHandler,
+		statement:         statementHandler,
+		createInvoice:     invoiceHandler,
+		importInvoices:    importHandler,
+		getInvoice:        getInvoiceHandler,
+		getInvoicePDF:     pdfHandler,
+		transaction:       transactionHandler,
+		streamExport:      exportHandler,
+		createExportJob:   createExportJobHandler,
+		getExportJob:      getExportJobHandler,
+		downloadExportJob: downloadExportJobHandler,
+	}
+	// On SQLite the events are kept in the outbox, the relay takes an advisory lock of PostgreSQL. The webhooks they are
+	// delivered to and the reports are left out along with it.
+	if storage.IsSQLite(db) {
+		logger.Warn("the outbox relay, the webhooks and the reports are disabled on sqlite")
+	} else {
+		reportRepository := report.NewReportRepository(db)
+		prometheus.MustRegister(report.NewReceivablesCollector(reportRepository, service, eCfg.Invoice.Currency))
+		h.aging = report.NewAgingHandler(reportRepository, billing)
+		h.revenue = report.NewRevenueHandler(reportRepository, billing)
+		h.collections = report.NewCollectionsHandler(reportRepository, billing)
 
-	go func() {
-		err := e.Start(fmt.Sprintf(":%s", eCfg.Port))
+		webhookRepository := webhook.NewWebhookRepository(db)
+		relay := outbox.NewRelay(outboxRepository, initPublisher(&eCfg.Outbox, webhookRepository), eCfg.Outbox.Interval, eCfg.Outbox.BatchSize)
+		dispatcher := webhook.NewDispatcher(webhookRepository, eCfg.Webhook.Timeout, eCfg.Webhook.Interval, webhook.RetryPolicy{
+			MaxAttempts: eCfg.Webhook.MaxAttempts,
+			Backoff:     eCfg.Webhook.Backoff,
+			MaxBackoff:  eCfg.Webhook.MaxBackoff,
+		})
+		overdueNotifier := invoice.NewOverdueNotifier(invoiceRepository, outboxRepository, transactor, eCfg.Webhook.OverdueInterval)
+		components.Add(
+			lifecycle.Worker("outbox relay", relay.Run),
+			lifecycle.Worker("webhook dispatcher", dispatcher.Run),
+			lifecycle.Worker("overdue notifier", overdueNotifier.Run),
+		)
+		h.createSubscription = webhook.NewCreateSubscriptionHandler(validate, webhookRepository)
+		h.listSubscriptions = webhook.NewListSubscriptionsHandler(webhookRepository)
+		h.deleteSubscription = webhook.NewDeleteSubscriptionHandler(webhookRepository)
+		h.listDeliveries = webhook.NewListDeliveriesHandler(webhookRepository)
+		h.redeliver = webhook.NewRedeliverHandler(webhookRepository)
+	}
+	if eCfg.Payments.NatsUrl != "" {
+		nc, err := nats.Connect(eCfg.Payments.NatsUrl, nats.Name(service))
 		if err != nil {
-			cl(fmt.Errorf("exposed server:%w", err))
+			cl(fmt.Errorf("connect nats:%w", err))
+			return
 		}
-	}()
-
-	srv := initInternalSrv(eCfg.InternalPort)
-	defer srv.Shutdown(context.Background())
-	go func() {
-		err := srv.ListenAndServe()
+		components.Add(lifecycle.Component{Name: "nats", Stop: func(context.Context) error { return nc.Drain() }})
+		consumer, err := initPaymentConsumer(&eCfg.Payments, nc, db, payments, transactionRepository)
 		if err != nil {
-			cl(fmt.Errorf("internal server:%w", err))
+			cl(fmt.Errorf("init payment consumer:%w", err))
+			return
 		}
-	}()
-	log.Printf("starting %s", Version)
-	<-ctx.Done()
+		components.Add(lifecycle.Component{Name: "payment consumer", Run: consumer.Run})
+	}
+	authenticators, err := initAuthenticators(&eCfg.Auth, db)
+	if err != nil {
+		cl(fmt.Errorf("init authenticators:%w", err))
+		return
+	}
+	authn := auth.Middleware(authenticators...)
+	tenantRepository := tenant.NewTenantRepository(db)
+	tenancy := tenant.Middleware(tenantRepository)
+	// protected authenticates the request, resolves its tenant and checks that the principal was granted permission.
+	protected := func(permission string) []echo.MiddlewareFunc {
+		return []echo.MiddlewareFunc{authn, tenancy, auth.RequirePermission(permission)}
+	}
+	e.Use(otelecho.Middleware(service))
+	e.Use(logging.Middleware(logger))
+	e.Use(echoprometheus.NewMiddleware(service))
+	registerRoutes(e, h, protected)
+
+	grpcServer := grpcapi.NewServer(logger, users, invoices, payments, billing, tenantRepository, authenticators...)
+	httpServer := &http.Server{Handler: e}
+	components.Add(
+		lifecycle.Server("grpc server", fmt.Sprintf(":%s", eCfg.GrpcPort), grpcServer.Serve, grpcServer.Shutdown),
+		lifecycle.Server("http server", fmt.Sprintf(":%s", eCfg.Port), httpServer.Serve, httpServer.Shutdown),
+		// Stopped first, readiness fails for the shutdown delay while the servers still serve
+		lifecycle.Component{Name: "readiness", Stop: func(ctx context.Context) error {
+			checks.Drain()
+			grpcServer.Drain()
+			select {
+			case <-time.After(eCfg.Health.ShutdownDelay):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}},
+	)
+
+	logger.Info("starting")
+	if err := components.Run(ctx); err != nil {
+		cl(err)
+	}
 }
 
-func initDb(c *configuration.Postgres) (*sql.DB, error) {
-	db, err := sql.Open("postgres", fmt.Sprintf("postgresql://%s:%s@%s:%s/%s?sslmode=%s", c.User, c.Password, c.Host, c.Port, c.Database, c.Sslmode))
+// initChecks registers the dependencies the service needs to be ready.
+func initChecks(c *configuration.Health, db *sql.DB, outboxRepository *outbox.Repository) (*health.Registry, error) {
+	latest, err := migrations.Latest()
 	if err != nil {
-		return nil, fmt.Errorf("sql.open: %w", err)
+		return nil, fmt.Errorf("migrations.Latest: %w", err)
 	}
 
-	timeout, cancelFunc := context.WithTimeout(context.Background(), 30*time.Second)
+	checks := health.NewRegistry(c.Timeout)
+	checks.Register("database", health.Ping(db))
+	checks.Register("migrations", health.Migrations(db, latest))
+	// The outbox is not relayed on SQLite, its lag only grows
+	if !storage.IsSQLite(db) {
+		checks.Register("outbox", health.OutboxLag(outboxRepository, c.MaxOutboxLag))
+	}
+	return checks, nil
+}
+
+// initDb opens the pool of connections to the database, waiting for the database to answer. A SQLite database is
+// migrated then, PostgreSQL is migrated with golang-migrate before the service starts.
+func initDb(ctx context.Context, c *configuration.Postgres) (*sql.DB, error) {
+	dsn, err := c.DataSource()
+	if err != nil {
+		return nil, fmt.Errorf("data source: %w", err)
+	}
+	db, err := storage.Open(c.Driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("storage.Open: %w", err)
+	}
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+
+	timeout, cancelFunc := context.WithTimeout(ctx, c.ConnectTimeout)
 	defer cancelFunc()
-	err = db.PingContext(timeout)
+	err = storage.Ping(timeout, db, 250*time.Millisecond, 5*time.Second)
 	if err != nil {
+		db.Close()
 		return nil, fmt.Errorf("ping: %w", err)
 	}
+	if storage.IsSQLite(db) {
+		if err := migrateSQLite(ctx, db); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+	}
 	return db, nil
 }
 
-func initInternalSrv(internalPort string) *http.Server {
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	all, err := migrations.SQLite()
+	if err != nil {
+		return fmt.Errorf("migrations.SQLite: %w", err)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("db.Conn: %w", err)
+	}
+	defer conn.Close()
+	return migrations.Up(ctx, conn, all)
+}
+
+// initAuthenticators always accepts API keys, bearer tokens are only accepted once a way to verify them is configured.
+func initAuthenticators(c *configuration.Auth, db *sql.DB) ([]auth.Authenticator, error) {
+	authenticators := []auth.Authenticator{auth.NewAPIKeyAuthenticator(auth.NewAPIKeyRepository(db))}
+	if c.Jwt.HmacSecret == "" && c.Jwt.JwksFile == "" {
+		return authenticators, nil
+	}
+
+	var keys map[string]crypto.PublicKey
+	if c.Jwt.JwksFile != "" {
+		var err error
+		keys, err = auth.LoadJWKS(c.Jwt.JwksFile)
+		if err != nil {
+			return nil, fmt.Errorf("load jwks: %w", err)
+		}
+	}
+	jwtAuthenticator, err := auth.NewJWTAuthenticator([]byte(c.Jwt.HmacSecret), keys, c.Jwt.Issuer, c.Jwt.Audience)
+	if err != nil {
+		return nil, fmt.Errorf("jwt authenticator: %w", err)
+	}
+
+	return append(authenticators, jwtAuthenticator), nil
+}
+
+func initPaymentConsumer(c *configuration.Payments, nc *nats.Conn, db *sql.DB, payments *invoice.Payments, transactionRepository *invoice.TransactionRepository) (*payment.Consumer, error) {
+	subscriber, err := broker.NewJetStream(nc, broker.JetStreamConfig{
+		Stream:            c.Stream,
+		Subject:           c.Subject,
+		Durable:           c.Durable,
+		DeadLetterSubject: c.DeadLetterSubject,
+		AckWait:           c.AckWait,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payment.NewConsumer(subscriber, tenant.NewTenantRepository(db), payments, transactionRepository, c.MaxDeliveries, c.Backoff), nil
+}
+
+// initPublisher publishes the outbox to the webhooks, and to the log when enabled.
+func initPublisher(c *configuration.Outbox, webhookRepository *webhook.Repository) outbox.Publisher {
+	publishers := outbox.Publishers{webhook.NewPublisher(webhookRepository)}
+	if c.Log {
+		publishers = append(publishers, outbox.NewLogPublisher())
+	}
+	return publishers
+}
+
+func exportDir(c *configuration.Export) string {
+	if c.Dir == "" {
+		return os.TempDir()
+	}
+	return c.Dir
+}
+
+func initBilling(c *configuration.Invoice) invoice.Billing {
+	return invoice.Billing{
+		NumberFormat: c.NumberFormat,
+		Currency:     c.Currency,
+		TaxRate:      c.TaxRate,
+		Seller: invoice.Party{
+			Name:        c.Seller.Name,
+			VatID:       c.Seller.VatID,
+			Street:      c.Seller.Street,
+			PostalCode:  c.Seller.PostalCode,
+			City:        c.Seller.City,
+			CountryCode: c.Seller.Country,
+		},
+		SellerIBAN:       c.Seller.Iban,
+		BuyerCountryCode: c.BuyerCountry,
+	}
+}
+
+func initPDFRenderer(c *configuration.Invoice) (*invoice.PDFRenderer, error) {
+	var logo []byte
+	if c.Pdf.LogoPath != "" {
+		var err error
+		logo, err = os.ReadFile(c.Pdf.LogoPath)
+		if err != nil {
+			return nil, fmt.Errorf("read logo: %w", err)
+		}
+	}
+
+	return invoice.NewPDFRenderer(invoice.PDFTemplate{
+		PaymentInstructions: c.PaymentInstructions,
+		Footer:              c.Pdf.Footer,
+		Logo:                logo,
+	})
+}
+
+func initInternalSrv(internalPort string, checks *health.Registry) *http.Server {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ping", func(writer http.ResponseWriter, request *http.Request) {
 		writer.WriteHeader(http.StatusOK)
 	})
+	mux.Handle("/livez", checks.Live())
+	mux.Handle("/readyz", checks.Ready())
 	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("/openapi.json", openapi.Handler())
+	mux.Handle("/docs", openapi.DocsHandler("/openapi.json"))
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%s", internalPort),
 		Handler: mux,
@@ -141,14 +448,14 @@ func loggingExit(ctx context.Context) {
 	err := context.Cause(ctx)
 	if err != nil {
 		if errors.Is(err, ErrStopSignalReceived) {
-			log.Print("stop signal received")
+			slog.Info("stop signal received")
 			return
 		}
 		if errors.Is(err, context.Canceled) {
-			log.Print("context cancel without cause")
+			slog.Info("context cancel without cause")
 			return
 		}
-		log.Print(err)
+		slog.Error("exit", slog.Any("error", err))
 		return
 	}
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/export"
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/report"
+	"github.com/emilien-puget/invoice_microservice/statement"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/emilien-puget/invoice_microservice/webhook"
+	"github.com/labstack/echo/v4"
+)
+
+// handlers are the handlers of the REST API.
+type handlers struct {
+	users              *user.GetAllHandler
+	statement          *statement.GetHandler
+	createInvoice      *invoice.CreateInvoiceHandler
+	importInvoices     *invoice.ImportHandler
+	getInvoice         *invoice.GetHandler
+	getInvoicePDF      *invoice.GetPDFHandler
+	transaction        *invoice.DoTransactionHandler
+	aging              *report.AgingHandler
+	revenue            *report.RevenueHandler
+	collections        *report.CollectionsHandler
+	streamExport       *export.StreamHandler
+	createExportJob    *export.CreateJobHandler
+	getExportJob       *export.GetJobHandler
+	downloadExportJob  *export.DownloadJobHandler
+	createSubscription *webhook.CreateSubscriptionHandler
+	listSubscriptions  *webhook.ListSubscriptionsHandler
+	deleteSubscription *webhook.DeleteSubscriptionHandler
+	listDeliveries     *webhook.ListDeliveriesHandler
+	redeliver          *webhook.RedeliverHandler
+}
+
+// registerRoutes registers the REST API, openapi/openapi.json must describe every route registered here. The reports and
+// the webhooks need PostgreSQL, their routes are left out when their handlers are nil.
+func registerRoutes(e *echo.Echo, h handlers, protected func(permission string) []echo.MiddlewareFunc) {
+	e.GET("/users", h.users.Handle, protected(auth.ScopeUsersRead)...)
+	e.GET("/users/:id/statement", h.statement.Handle, protected(auth.ScopeUsersRead)...)
+	e.POST("/invoice", h.createInvoice.Handle, protected(auth.ScopeInvoicesWrite)...)
+	e.POST("/invoices/import", h.importInvoices.Handle, protected(auth.ScopeInvoicesWrite)...)
+	e.GET("/invoices/:id", h.getInvoice.Handle, protected(auth.ScopeInvoicesRead)...)
+	e.GET("/invoices/:id/pdf", h.getInvoicePDF.Handle, protected(auth.ScopeInvoicesRead)...)
+	e.POST("/transaction", h.transaction.Handle, protected(auth.ScopeTransactionsWrite)...)
+	if h.aging != nil {
+		e.GET("/reports/ar-aging", h.aging.Handle, protected(auth.ScopeReportsRead)...)
+		e.GET("/reports/revenue", h.revenue.Handle, protected(auth.ScopeReportsRead)...)
+		e.GET("/reports/collections", h.collections.Handle, protected(auth.ScopeReportsRead)...)
+	}
+	e.GET("/exports/jobs/:id", h.getExportJob.Handle, protected(auth.ScopeExportsRead)...)
+	e.GET("/exports/jobs/:id/download", h.downloadExportJob.Handle, protected(auth.ScopeExportsRead)...)
+	e.GET("/exports/:dataset", h.streamExport.Handle, protected(auth.ScopeExportsRead)...)
+	e.POST("/exports/:dataset", h.createExportJob.Handle, protected(auth.ScopeExportsWrite)...)
+	if h.createSubscription != nil {
+		e.POST("/webhooks", h.createSubscription.Handle, protected(auth.ScopeWebhooksManage)...)
+		e.GET("/webhooks", h.listSubscriptions.Handle, protected(auth.ScopeWebhooksManage)...)
+		e.DELETE("/webhooks/:id", h.deleteSubscription.Handle, protected(auth.ScopeWebhooksManage)...)
+		e.GET("/webhooks/deliveries", h.listDeliveries.Handle, protected(auth.ScopeWebhooksManage)...)
+		e.POST("/webhooks/deliveries/:id/redeliver", h.redeliver.Handle, protected(auth.ScopeWebhooksManage)...)
+	}
+}
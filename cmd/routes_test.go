@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/emilien-puget/invoice_microservice/export"
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/openapi"
+	"github.com/emilien-puget/invoice_microservice/report"
+	"github.com/emilien-puget/invoice_microservice/statement"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/emilien-puget/invoice_microservice/webhook"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type specOperation struct {
+	Description string                 `json:"description"`
+	Security    *[]map[string][]string `json:"security"`
+	Responses   map[string]any         `json:"responses"`
+}
+
+var (
+	pathParameter = regexp.MustCompile(`\{(\w+)\}`)
+	// routeParameter is an echo path parameter, replaced by a value to request the route.
+	routeParameter = regexp.MustCompile(`:\w+`)
+	// permission is the permission an operation documents in its description.
+	permission = regexp.MustCompile("Requires the `([a-z:]+)` permission")
+)
+
+// permissionHeader is set by the middleware of the test to the permission the route is protected with, the handler
+// is not called.
+const permissionHeader = "X-Test-Permission"
+
+func TestRegisterRoutes_MatchesOpenAPI(t *testing.T) {
+	e := echo.New()
+	registerRoutes(e, handlers{
+		users:              new(user.GetAllHandler),
+		statement:          new(statement.GetHandler),
+		createInvoice:      new(invoice.CreateInvoiceHandler),
+		importInvoices:     new(invoice.ImportHandler),
+		getInvoice:         new(invoice.GetHandler),
+		getInvoicePDF:      new(invoice.GetPDFHandler),
+		transaction:        new(invoice.DoTransactionHandler),
+		aging:              new(report.AgingHandler),
+		revenue:            new(report.RevenueHandler),
+		collections:        new(report.CollectionsHandler),
+		streamExport:       new(export.StreamHandler),
+		createExportJob:    new(export.CreateJobHandler),
+		getExportJob:       new(export.GetJobHandler),
+		downloadExportJob:  new(export.DownloadJobHandler),
+		createSubscription: new(webhook.CreateSubscriptionHandler),
+		listSubscriptions:  new(webhook.ListSubscriptionsHandler),
+		deleteSubscription: new(webhook.DeleteSubscriptionHandler),
+		listDeliveries:     new(webhook.ListDeliveriesHandler),
+		redeliver:          new(webhook.RedeliverHandler),
+	}, func(permission string) []echo.MiddlewareFunc {
+		return []echo.MiddlewareFunc{func(echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				c.Response().Header().Set(permissionHeader, permission)
+				return c.NoContent(http.StatusNoContent)
+			}
+		}}
+	})
+
+	var spec struct {
+		OpenAPI string                              `json:"openapi"`
+		Paths   map[string]map[string]specOperation `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(openapi.Spec, &spec))
+	assert.Equal(t, "3.1.0", spec.OpenAPI)
+
+	var documented []string
+	permissions := make(map[string]string)
+	for path, operations := range spec.Paths {
+		for method, operation := range operations {
+			route := strings.ToUpper(method) + " " + pathParameter.ReplaceAllString(path, ":$1")
+			documented = append(documented, route)
+
+			// Operations are authenticated unless they opt out with an empty security
+			if operation.Security == nil {
+				for _, status := range []string{"400", "401", "403", "500"} {
+					assert.Contains(t, operation.Responses, status, "%s does not document its %s response", route, status)
+				}
+				if match := permission.FindStringSubmatch(operation.Description); assert.NotNil(t, match, "%s does not document its permission", route) {
+					permissions[route] = match[1]
+				}
+			}
+		}
+	}
+
+	var registered []string
+	for _, route := range e.Routes() {
+		name := route.Method + " " + route.Path
+		registered = append(registered, name)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(route.Method, routeParameter.ReplaceAllString(route.Path, "1"), nil))
+		if documented, ok := permissions[name]; ok {
+			assert.Equal(t, documented, rec.Header().Get(permissionHeader), "%s is not protected with the permission it documents", name)
+		}
+	}
+
+	assert.ElementsMatch(t, registered, documented, "the routes registered and the paths of openapi/openapi.json differ")
+}
+
+func TestRegisterRoutes_WithoutPostgres(t *testing.T) {
+	e := echo.New()
+	registerRoutes(e, handlers{
+		users:             new(user.GetAllHandler),
+		statement:         new(statement.GetHandler),
+		createInvoice:     new(invoice.CreateInvoiceHandler),
+		importInvoices:    new(invoice.ImportHandler),
+		getInvoice:        new(invoice.GetHandler),
+		getInvoicePDF:     new(invoice.GetPDFHandler),
+		transaction:       new(invoice.DoTransactionHandler),
+		streamExport:      new(export.StreamHandler),
+		createExportJob:   new(export.CreateJobHandler),
+		getExportJob:      new(export.GetJobHandler),
+		downloadExportJob: new(export.DownloadJobHandler),
+	}, func(string) []echo.MiddlewareFunc { return nil })
+
+	for _, route := range e.Routes() {
+		assert.False(t, strings.HasPrefix(route.Path, "/reports") || strings.HasPrefix(route.Path, "/webhooks"),
+			"%s %s is registered without its handler", route.Method, route.Path)
+	}
+	assert.NotEmpty(t, e.Routes())
+}
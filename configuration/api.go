@@ -1,16 +1,124 @@
 package configuration
 
+import "time"
+
 type Api struct {
-	Port         string   `env:"PORT" envDefault:"8080"`
-	InternalPort string   `env:"INTERNAL_PORT" envDefault:"2112"`
-	Postgres     Postgres `envPrefix:"POSTGRES_"`
+	Port            string        `env:"PORT" envDefault:"8080" validate:"numeric"`
+	InternalPort    string        `env:"INTERNAL_PORT" envDefault:"2112" validate:"numeric"`
+	GrpcPort        string        `env:"GRPC_PORT" envDefault:"9090" validate:"numeric"`
+	LogLevel        string        `env:"LOG_LEVEL" envDefault:"info" validate:"log_level"`
+	TraceExporter   string        `env:"TRACE_EXPORTER" envDefault:"none" validate:"oneof=otlp stdout none"`
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"15s" validate:"gt=0"`
+	Postgres        Postgres      `envPrefix:"POSTGRES_"`
+	Invoice         Invoice       `envPrefix:"INVOICE_"`
+	Export          Export        `envPrefix:"EXPORT_"`
+	Auth            Auth          `envPrefix:"AUTH_"`
+	Webhook         Webhook       `envPrefix:"WEBHOOK_"`
+	Outbox          Outbox        `envPrefix:"OUTBOX_"`
+	Payments        Payments      `envPrefix:"PAYMENTS_"`
+	Health          Health        `envPrefix:"HEALTH_"`
 }
 
+// Postgres configures the connection to the database through Driver, lib/pq, pgx or sqlite. Dsn replaces the connection
+// settings, from User to Sslmode, when set. SqlitePath is the database file of the sqlite driver, which takes no other
+// connection setting. A StatementTimeout of zero disables it, MaxOpenConns of zero leaves the pool unlimited.
+// ConnectTimeout is the time given to the database to answer on startup.
 type Postgres struct {
-	User     string `env:"USER"`
-	Password string `env:"PASSWORD"`
-	Host     string `env:"HOST"`
-	Port     string `env:"PORT"`
-	Database string `env:"DATABASE"`
-	Sslmode  string `env:"SSL_MODE"`
+	Driver           string        `env:"DRIVER" envDefault:"pq" validate:"oneof=pq pgx sqlite"`
+	SqlitePath       string        `env:"SQLITE_PATH" envDefault:"" validate:"required_if=Driver sqlite,excluded_unless=Driver sqlite"`
+	Dsn              string        `env:"DSN" envDefault:"" secret:"true" validate:"omitempty,url"`
+	User             string        `env:"USER" envDefault:"" validate:"required_without_all=Dsn SqlitePath"`
+	Password         string        `env:"PASSWORD" envDefault:"" secret:"true"`
+	Host             string        `env:"HOST" envDefault:"" validate:"required_without_all=Dsn SqlitePath"`
+	Port             string        `env:"PORT" envDefault:"5432" validate:"numeric"`
+	Database         string        `env:"DATABASE" envDefault:"" validate:"required_without_all=Dsn SqlitePath"`
+	Sslmode          string        `env:"SSL_MODE" envDefault:"" validate:"required_without_all=Dsn SqlitePath,omitempty,oneof=disable allow prefer require verify-ca verify-full"`
+	ApplicationName  string        `env:"APPLICATION_NAME" envDefault:"invoice_microservice"`
+	StatementTimeout time.Duration `env:"STATEMENT_TIMEOUT" envDefault:"30s" validate:"gte=0"`
+	MaxOpenConns     int           `env:"MAX_OPEN_CONNS" envDefault:"20" validate:"gte=0"`
+	MaxIdleConns     int           `env:"MAX_IDLE_CONNS" envDefault:"10" validate:"gte=0"`
+	ConnMaxLifetime  time.Duration `env:"CONN_MAX_LIFETIME" envDefault:"30m" validate:"gte=0"`
+	ConnMaxIdleTime  time.Duration `env:"CONN_MAX_IDLE_TIME" envDefault:"5m" validate:"gte=0"`
+	ConnectTimeout   time.Duration `env:"CONNECT_TIMEOUT" envDefault:"30s" validate:"gt=0"`
+}
+
+type Invoice struct {
+	NumberFormat        string  `env:"NUMBER_FORMAT" envDefault:"INV-%06d" validate:"number_format"`
+	Currency            string  `env:"CURRENCY" envDefault:"EUR" validate:"iso4217"`
+	TaxRate             float64 `env:"TAX_RATE" envDefault:"20" validate:"gte=0,lte=100"`
+	PaymentInstructions string  `env:"PAYMENT_INSTRUCTIONS" envDefault:"Please pay {{.Total}} {{.Currency}} before {{.DueDate}} using the reference {{.Number}}."`
+	BuyerCountry        string  `env:"BUYER_COUNTRY" envDefault:"FR" validate:"iso3166_1_alpha2"`
+	Seller              Seller  `envPrefix:"SELLER_"`
+	Pdf                 Pdf     `envPrefix:"PDF_"`
+}
+
+type Seller struct {
+	Name       string `env:"NAME" envDefault:"Jump"`
+	VatID      string `env:"VAT_ID" envDefault:""`
+	Street     string `env:"STREET" envDefault:""`
+	PostalCode string `env:"POSTAL_CODE" envDefault:""`
+	City       string `env:"CITY" envDefault:""`
+	Country    string `env:"COUNTRY" envDefault:"FR" validate:"iso3166_1_alpha2"`
+	Iban       string `env:"IBAN" envDefault:""`
+}
+
+type Pdf struct {
+	LogoPath string `env:"LOGO_PATH" envDefault:""`
+	Footer   string `env:"FOOTER" envDefault:""`
+}
+
+type Export struct {
+	// Dir is where the asynchronous exports are written, the system temporary directory when empty.
+	Dir       string        `env:"DIR" envDefault:""`
+	Retention time.Duration `env:"RETENTION" envDefault:"24h" validate:"gt=0"`
+}
+
+type Auth struct {
+	Jwt Jwt `envPrefix:"JWT_"`
+}
+
+// Jwt configures the bearer tokens, they are rejected when neither HmacSecret nor JwksFile is set.
+type Jwt struct {
+	HmacSecret string `env:"HMAC_SECRET" envDefault:"" secret:"true"`
+	JwksFile   string `env:"JWKS_FILE" envDefault:""`
+	Issuer     string `env:"ISSUER" envDefault:""`
+	Audience   string `env:"AUDIENCE" envDefault:""`
+}
+
+// Webhook configures the deliveries, a delivery is dead once MaxAttempts failed.
+type Webhook struct {
+	Interval        time.Duration `env:"INTERVAL" envDefault:"5s" validate:"gt=0"`
+	Timeout         time.Duration `env:"TIMEOUT" envDefault:"10s" validate:"gt=0"`
+	MaxAttempts     int           `env:"MAX_ATTEMPTS" envDefault:"8" validate:"min=1"`
+	Backoff         time.Duration `env:"BACKOFF" envDefault:"30s" validate:"gt=0"`
+	MaxBackoff      time.Duration `env:"MAX_BACKOFF" envDefault:"6h" validate:"gtefield=Backoff"`
+	OverdueInterval time.Duration `env:"OVERDUE_INTERVAL" envDefault:"1h" validate:"gt=0"`
+}
+
+// Outbox configures the relay, Log also publishes the events to the log.
+type Outbox struct {
+	Interval  time.Duration `env:"INTERVAL" envDefault:"1s" validate:"gt=0"`
+	BatchSize int           `env:"BATCH_SIZE" envDefault:"100" validate:"min=1"`
+	Log       bool          `env:"LOG" envDefault:"false"`
+}
+
+// Payments configures the intake of the payment confirmations from NATS JetStream, it is disabled when NatsUrl is
+// empty. A confirmation is dead once delivered MaxDeliveries times.
+type Payments struct {
+	NatsUrl           string        `env:"NATS_URL" envDefault:"" validate:"omitempty,url"`
+	Stream            string        `env:"STREAM" envDefault:"PAYMENTS" validate:"required"`
+	Subject           string        `env:"SUBJECT" envDefault:"payments.confirmed" validate:"required"`
+	Durable           string        `env:"DURABLE" envDefault:"invoice_microservice" validate:"required"`
+	DeadLetterSubject string        `env:"DEAD_LETTER_SUBJECT" envDefault:"payments.dead" validate:"required"`
+	AckWait           time.Duration `env:"ACK_WAIT" envDefault:"30s" validate:"gt=0"`
+	MaxDeliveries     int           `env:"MAX_DELIVERIES" envDefault:"10" validate:"min=1"`
+	Backoff           time.Duration `env:"BACKOFF" envDefault:"5s" validate:"gt=0"`
+}
+
+// Health configures the readiness, a check fails once it took Timeout. Readiness fails for ShutdownDelay before the
+// servers stop so that the load balancers stop sending requests first.
+type Health struct {
+	Timeout       time.Duration `env:"TIMEOUT" envDefault:"2s" validate:"gt=0"`
+	MaxOutboxLag  time.Duration `env:"MAX_OUTBOX_LAG" envDefault:"5m" validate:"gt=0"`
+	ShutdownDelay time.Duration `env:"SHUTDOWN_DELAY" envDefault:"5s" validate:"gte=0"`
 }
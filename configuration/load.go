@@ -0,0 +1,403 @@
+package configuration
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"github.com/caarlos0/env/v6"
+	"github.com/emilien-puget/invoice_microservice/logging"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalid       = errors.New("invalid configuration")
+	ErrUnknownFormat = errors.New("unknown configuration file format, expected .yaml, .yml or .toml")
+)
+
+// fileSuffix is appended to the name of a setting to read its value from a file, as mounted by Docker and Kubernetes
+// secrets.
+const fileSuffix = "_FILE"
+
+// setting is a field of Api, named after its environment variable. Its key in the configuration file is the
+// environment variable lowercased below its prefixes, e.g. POSTGRES_SSL_MODE is ssl_mode below postgres.
+type setting struct {
+	key    string
+	name   string
+	value  reflect.Value
+	def    string
+	secret bool
+}
+
+// flagName is the command line flag of the setting, e.g. -postgres-ssl-mode.
+func (s setting) flagName() string {
+	return strings.ToLower(strings.ReplaceAll(s.key, "_", "-"))
+}
+
+func settings(v reflect.Value, prefix, name string) []setting {
+	var all []setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if envPrefix, ok := field.Tag.Lookup("envPrefix"); ok {
+			all = append(all, settings(v.Field(i), prefix+envPrefix, name+strings.ToLower(envPrefix[:len(envPrefix)-1])+".")...)
+			continue
+		}
+		key, _, _ := strings.Cut(field.Tag.Get("env"), ",")
+		if key == "" {
+			continue
+		}
+		all = append(all, setting{
+			key:    prefix + key,
+			name:   name + strings.ToLower(key),
+			value:  v.Field(i),
+			def:    field.Tag.Get("envDefault"),
+			secret: field.Tag.Get("secret") == "true",
+		})
+	}
+	return all
+}
+
+// Load layers the configuration: the defaults, then the configuration file, then the environment, then the flags.
+// The file is the one of the -config flag, or of the CONFIG_FILE environment variable. Every setting can be read from
+// the file named by its key suffixed with _FILE, in the file or in the environment. The arguments left after the
+// flags are returned, they are the command to run.
+func Load(args, environ []string) (Api, []string, error) {
+	c := Api{}
+	all := settings(reflect.ValueOf(&c).Elem(), "", "")
+	known := make(map[string]setting, len(all))
+	for _, s := range all {
+		known[s.key] = s
+	}
+	environment := toMap(environ)
+
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	file := flags.String("config", environment["CONFIG_FILE"], "YAML or TOML configuration file")
+	for _, s := range all {
+		flags.String(s.flagName(), s.def, "overrides "+s.key)
+	}
+	if err := flags.Parse(args); err != nil {
+		return c, nil, fmt.Errorf("parse flags: %w", err)
+	}
+
+	values := make(map[string]string)
+	if *file != "" {
+		fromFile, err := readFile(*file, known)
+		if err != nil {
+			return c, nil, err
+		}
+		values = fromFile
+	}
+	fromEnvironment := make(map[string]string)
+	for key, value := range environment {
+		if isSetting(key, known) {
+			fromEnvironment[key] = value
+		}
+	}
+	if err := readSecrets(fromEnvironment, known); err != nil {
+		return c, nil, err
+	}
+	for key, value := range fromEnvironment {
+		values[key] = value
+	}
+	flagKeys := make(map[string]string, len(all))
+	for _, s := range all {
+		flagKeys[s.flagName()] = s.key
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if key, ok := flagKeys[f.Name]; ok {
+			values[key] = f.Value.String()
+		}
+	})
+
+	if err := check(values, known); err != nil {
+		return c, nil, err
+	}
+	if err := env.Parse(&c, env.Options{Environment: values, RequiredIfNoDef: true}); err != nil {
+		return c, nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if err := checkRules(&c, known); err != nil {
+		return c, nil, err
+	}
+	return c, flags.Args(), nil
+}
+
+// isSetting reports whether key is the one of a setting, or of the file of a setting.
+func isSetting(key string, known map[string]setting) bool {
+	if _, ok := known[key]; ok {
+		return true
+	}
+	_, ok := known[strings.TrimSuffix(key, fileSuffix)]
+	return ok && strings.HasSuffix(key, fileSuffix)
+}
+
+func toMap(environ []string) map[string]string {
+	m := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		m[key] = value
+	}
+	return m
+}
+
+// readFile returns the settings of the configuration file, keyed by their environment variable.
+func readFile(path string, known map[string]setting) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %w", err)
+	}
+
+	document := make(map[string]any)
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &document)
+	case ".toml":
+		err = toml.Unmarshal(content, &document)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: parse %s: %w", ErrInvalid, path, err)
+	}
+
+	values := make(map[string]string)
+	var problems []string
+	flatten(document, "", "", values, func(key, name string) {
+		if !isSetting(key, known) {
+			problems = append(problems, name+" is not a setting")
+		}
+	})
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%w: %s: %s", ErrInvalid, path, strings.Join(problems, "; "))
+	}
+	if err := readSecrets(values, known); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// flatten keys the values of document by their environment variable, visiting every key along with its name in the
+// document.
+func flatten(document map[string]any, prefix, name string, values map[string]string, visit func(key, name string)) {
+	for k, v := range document {
+		key := prefix + strings.ToUpper(k)
+		switch v := v.(type) {
+		case map[string]any:
+			flatten(v, key+"_", name+k+".", values, visit)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			visit(key, name+k)
+			values[key] = strings.Join(items, ",")
+		default:
+			visit(key, name+k)
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// readSecrets replaces the settings keyed with the _FILE suffix by the content of the file they name, without the
+// trailing newline.
+func readSecrets(values map[string]string, known map[string]setting) error {
+	for key, path := range values {
+		if _, ok := known[key]; ok {
+			continue
+		}
+		key = strings.TrimSuffix(key, fileSuffix)
+		if _, ok := values[key]; ok {
+			return fmt.Errorf("%w: both %s and %s%s are set", ErrInvalid, key, key, fileSuffix)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%w: %s%s: %w", ErrInvalid, key, fileSuffix, err)
+		}
+		delete(values, key+fileSuffix)
+		values[key] = strings.TrimRight(string(content), "\r\n")
+	}
+	return nil
+}
+
+// check reports every value that cannot be parsed into its setting, env.Parse names them after the field of the struct.
+func check(values map[string]string, known map[string]setting) error {
+	var problems []string
+	for key, value := range values {
+		s := known[key]
+		var kind string
+		var err error
+		switch s.value.Interface().(type) {
+		case time.Duration:
+			kind = "duration such as 1m30s"
+			_, err = time.ParseDuration(value)
+		case int:
+			kind = "whole number"
+			_, err = strconv.Atoi(value)
+		case float64:
+			kind = "number"
+			_, err = strconv.ParseFloat(value, 64)
+		case bool:
+			kind = "boolean"
+			_, err = strconv.ParseBool(value)
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s must be a %s, got %q", describe(s), kind, value))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkRules checks the rules of the validate tags of Api.
+func checkRules(c *Api, known map[string]setting) error {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		if envPrefix, ok := field.Tag.Lookup("envPrefix"); ok {
+			return strings.TrimSuffix(envPrefix, "_")
+		}
+		key, _, _ := strings.Cut(field.Tag.Get("env"), ",")
+		return key
+	})
+	if err := validate.RegisterValidation("log_level", func(fl validator.FieldLevel) bool {
+		_, err := logging.ParseLevel(fl.Field().String())
+		return err == nil
+	}); err != nil {
+		return fmt.Errorf("validate.RegisterValidation: %w", err)
+	}
+	if err := validate.RegisterValidation("number_format", func(fl validator.FieldLevel) bool {
+		return tenant.CheckNumberFormat(fl.Field().String()) == nil
+	}); err != nil {
+		return fmt.Errorf("validate.RegisterValidation: %w", err)
+	}
+
+	err := validate.Struct(c)
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+	problems := make([]string, len(validationErrors))
+	for i, fieldError := range validationErrors {
+		_, namespace, _ := strings.Cut(fieldError.Namespace(), ".")
+		s := known[strings.ReplaceAll(namespace, ".", "_")]
+		problems[i] = fmt.Sprintf("%s %s, got %q", describe(s), message(fieldError), fmt.Sprint(fieldError.Value()))
+	}
+	return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(problems, "; "))
+}
+
+// describe names a setting in all the layers it can be set in.
+func describe(s setting) string {
+	return fmt.Sprintf("%s (%s, -%s)", s.name, s.key, s.flagName())
+}
+
+func message(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return "is required unless " + fieldName(fieldError.Param()) + " is set"
+	case "required_without_all":
+		return "is required unless " + strings.Join(fieldNames(fieldError.Param()), " or ") + " is set"
+	case "required_if":
+		field, value, _ := strings.Cut(fieldError.Param(), " ")
+		return "is required when " + fieldName(field) + " is " + value
+	case "excluded_unless":
+		field, value, _ := strings.Cut(fieldError.Param(), " ")
+		return "must be empty unless " + fieldName(field) + " is " + value
+	case "numeric":
+		return "must be a number"
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldError.Param(), " ", ", ")
+	case "gt":
+		return "must be greater than " + fieldError.Param()
+	case "gte":
+		return "must be at least " + fieldError.Param()
+	case "lte":
+		return "must be at most " + fieldError.Param()
+	case "min":
+		return "must be at least " + fieldError.Param()
+	case "gtefield":
+		return "must not be less than " + fieldName(fieldError.Param())
+	case "log_level":
+		return "must be one of debug, info, warn, error"
+	case "number_format":
+		return "must have a single integer verb such as INV-%06d"
+	case "url":
+		return "must be a URL"
+	case "iso4217":
+		return "must be an ISO 4217 currency code"
+	case "iso3166_1_alpha2":
+		return "must be an ISO 3166 country code"
+	default:
+		return "fails the " + fieldError.Tag() + " rule"
+	}
+}
+
+// fieldName names a field of a rule after its setting, e.g. SqlitePath is sqlite_path.
+func fieldName(field string) string {
+	var b strings.Builder
+	for i, r := range field {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func fieldNames(fields string) []string {
+	names := strings.Fields(fields)
+	for i, field := range names {
+		names[i] = fieldName(field)
+	}
+	return names
+}
+
+// Print writes c in the YAML format of the configuration file, with the secrets masked.
+func Print(w io.Writer, c *Api) error {
+	document := make(map[string]any)
+	for _, s := range settings(reflect.ValueOf(c).Elem(), "", "") {
+		parent := document
+		path := strings.Split(s.name, ".")
+		for _, k := range path[:len(path)-1] {
+			child, ok := parent[k].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				parent[k] = child
+			}
+			parent = child
+		}
+
+		var value any = s.value.Interface()
+		switch v := value.(type) {
+		case time.Duration:
+			value = v.String()
+		case string:
+			if s.secret && v != "" {
+				value = logging.Redacted
+			}
+		}
+		parent[path[len(path)-1]] = value
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return fmt.Errorf("encoder.Encode: %w", err)
+	}
+	return encoder.Close()
+}
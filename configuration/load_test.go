@@ -0,0 +1,183 @@
+package configuration
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// required are the settings without a default.
+var required = []string{"POSTGRES_USER=jump", "POSTGRES_HOST=localhost", "POSTGRES_DATABASE=jump", "POSTGRES_SSL_MODE=disable"}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Layers(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+port: 8081
+grpc_port: 9091
+internal_port: 2113
+outbox:
+  batch_size: 10
+  interval: 2s
+`)
+
+	c, args, err := Load(
+		[]string{"-config", file, "-port", "8083", "apikey", "-name", "billing"},
+		append([]string{"PORT=8082", "GRPC_PORT=9092", "AUTH_JWT_JWKS_FILE=/etc/jwks.json"}, required...),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "8083", c.Port, "flags override the environment")
+	assert.Equal(t, "9092", c.GrpcPort, "the environment overrides the file")
+	assert.Equal(t, "2113", c.InternalPort, "the file overrides the defaults")
+	assert.Equal(t, 10, c.Outbox.BatchSize)
+	assert.Equal(t, 2*time.Second, c.Outbox.Interval)
+	assert.Equal(t, "EUR", c.Invoice.Currency, "defaults apply to the rest")
+	assert.Equal(t, "/etc/jwks.json", c.Auth.Jwt.JwksFile, "a setting ending with _FILE is not read as a secret file")
+	assert.Equal(t, []string{"apikey", "-name", "billing"}, args)
+}
+
+func TestLoad_TOML(t *testing.T) {
+	file := writeFile(t, "config.toml", `
+port = 8081
+
+[postgres]
+host = "db"
+
+[invoice.seller]
+name = "Acme"
+`)
+
+	c, _, err := Load(nil, append([]string{"CONFIG_FILE=" + file}, required[:1]...))
+	require.Error(t, err, "the database and ssl mode are missing")
+
+	c, _, err = Load(nil, append([]string{"CONFIG_FILE=" + file}, required...))
+	require.NoError(t, err)
+	assert.Equal(t, "8081", c.Port)
+	assert.Equal(t, "localhost", c.Postgres.Host, "the environment overrides the file")
+	assert.Equal(t, "Acme", c.Invoice.Seller.Name)
+}
+
+func TestLoad_Secrets(t *testing.T) {
+	password := writeFile(t, "password", "s3cret\n")
+	hmac := writeFile(t, "hmac", "h4c")
+	file := writeFile(t, "config.yaml", "auth:\n  jwt:\n    hmac_secret_file: "+hmac+"\n")
+
+	c, _, err := Load([]string{"-config", file}, append([]string{"POSTGRES_PASSWORD_FILE=" + password}, required...))
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", c.Postgres.Password, "the trailing newline is trimmed")
+	assert.Equal(t, "h4c", c.Auth.Jwt.HmacSecret)
+
+	_, _, err = Load(nil, append([]string{"POSTGRES_PASSWORD=s3cret", "POSTGRES_PASSWORD_FILE=" + password}, required...))
+	assert.ErrorIs(t, err, ErrInvalid)
+	assert.ErrorContains(t, err, "both POSTGRES_PASSWORD and POSTGRES_PASSWORD_FILE are set")
+}
+
+func TestLoad_Dsn(t *testing.T) {
+	c, _, err := Load(nil, []string{"POSTGRES_DSN=postgres://jump:secret@db:5432/jump?sslmode=disable"})
+	require.NoError(t, err, "the connection settings are not required along with a dsn")
+	assert.Equal(t, "postgres://jump:secret@db:5432/jump?sslmode=disable", c.Postgres.Dsn)
+}
+
+func TestLoad_SQLite(t *testing.T) {
+	c, _, err := Load(nil, []string{"POSTGRES_DRIVER=sqlite", "POSTGRES_SQLITE_PATH=/var/lib/jump/jump.db"})
+	require.NoError(t, err, "the connection settings are not required along with a sqlite path")
+	assert.Equal(t, "/var/lib/jump/jump.db", c.Postgres.SqlitePath)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		args    []string
+		environ []string
+		file    string
+		wantErr string
+	}{
+		"required": {
+			environ: required[1:],
+			wantErr: "postgres.user (POSTGRES_USER, -postgres-user) is required",
+		},
+		"required without dsn": {
+			environ: []string{"POSTGRES_HOST=db"},
+			wantErr: "postgres.user (POSTGRES_USER, -postgres-user) is required unless dsn or sqlite_path is set",
+		},
+		"sqlite without path": {
+			environ: []string{"POSTGRES_DRIVER=sqlite"},
+			wantErr: `postgres.sqlite_path (POSTGRES_SQLITE_PATH, -postgres-sqlite-path) is required when driver is sqlite, got ""`,
+		},
+		"path without sqlite": {
+			environ: append([]string{"POSTGRES_SQLITE_PATH=jump.db"}, required...),
+			wantErr: `postgres.sqlite_path (POSTGRES_SQLITE_PATH, -postgres-sqlite-path) must be empty unless driver is sqlite, got "jump.db"`,
+		},
+		"not a duration": {
+			environ: append([]string{"OUTBOX_INTERVAL=soon"}, required...),
+			wantErr: `outbox.interval (OUTBOX_INTERVAL, -outbox-interval) must be a duration such as 1m30s, got "soon"`,
+		},
+		"rule": {
+			args:    []string{"-invoice-currency", "EURO"},
+			environ: required,
+			wantErr: `invoice.currency (INVOICE_CURRENCY, -invoice-currency) must be an ISO 4217 currency code, got "EURO"`,
+		},
+		"log level": {
+			environ: append([]string{"LOG_LEVEL=loud"}, required...),
+			wantErr: `log_level (LOG_LEVEL, -log-level) must be one of debug, info, warn, error, got "loud"`,
+		},
+		"number format": {
+			environ: append([]string{"INVOICE_NUMBER_FORMAT=INV-%s-%d"}, required...),
+			wantErr: `invoice.number_format (INVOICE_NUMBER_FORMAT, -invoice-number-format) must have a single integer verb such as INV-%06d, got "INV-%s-%d"`,
+		},
+		"unknown setting": {
+			environ: required,
+			file:    "postgres:\n  hots: db\n",
+			wantErr: "postgres.hots is not a setting",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, "config.yaml", tt.file)}, args...)
+			}
+
+			_, _, err := Load(args, tt.environ)
+			assert.ErrorIs(t, err, ErrInvalid)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLoad_UnknownFormat(t *testing.T) {
+	_, _, err := Load([]string{"-config", writeFile(t, "config.json", "{}")}, required)
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestLoad_Help(t *testing.T) {
+	_, _, err := Load([]string{"-h"}, required)
+	assert.ErrorIs(t, err, flag.ErrHelp)
+}
+
+func TestPrint(t *testing.T) {
+	c, _, err := Load(nil, append([]string{"POSTGRES_PASSWORD=s3cret"}, required...))
+	require.NoError(t, err)
+
+	var b bytes.Buffer
+	require.NoError(t, Print(&b, &c))
+	assert.NotContains(t, b.String(), "s3cret")
+	assert.Contains(t, b.String(), "password: '[REDACTED]'")
+	assert.Contains(t, b.String(), "hmac_secret: \"\"", "an empty secret is shown as empty")
+
+	printed := writeFile(t, "printed.yaml", b.String())
+	reloaded, _, err := Load([]string{"-config", printed}, nil)
+	require.NoError(t, err, "the output is a configuration file")
+	assert.Equal(t, c.Outbox, reloaded.Outbox)
+}
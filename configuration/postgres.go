@@ -0,0 +1,54 @@
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/emilien-puget/invoice_microservice/storage"
+)
+
+var ErrInvalidDsn = errors.New("dsn must be a postgres:// or postgresql:// URL")
+
+// DataSource returns what storage.Open takes for Driver: the path of the database file for sqlite, the connection URL
+// of ConnString otherwise.
+func (p *Postgres) DataSource() (string, error) {
+	if p.Driver == storage.DriverSQLite {
+		return p.SqlitePath, nil
+	}
+	return p.ConnString()
+}
+
+// ConnString returns the connection URL of the database, escaping the user, the password and the database. The
+// application name and the statement timeout are added to Dsn unless it sets them already.
+func (p *Postgres) ConnString() (string, error) {
+	u := &url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(p.User, p.Password),
+		Host:   net.JoinHostPort(p.Host, p.Port),
+		Path:   "/" + p.Database,
+	}
+	query := url.Values{"sslmode": {p.Sslmode}}
+	if p.Dsn != "" {
+		var err error
+		u, err = url.Parse(p.Dsn)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidDsn, err)
+		}
+		if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			return "", ErrInvalidDsn
+		}
+		query = u.Query()
+	}
+
+	if !query.Has("application_name") {
+		query.Set("application_name", p.ApplicationName)
+	}
+	if !query.Has("statement_timeout") {
+		query.Set("statement_timeout", strconv.FormatInt(p.StatementTimeout.Milliseconds(), 10))
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/user"
+)
+
+const (
+	datasetInvoices     = "invoices"
+	datasetUsers        = "users"
+	datasetTransactions = "transactions"
+)
+
+var ErrUnknownDataset = errors.New("unknown dataset")
+
+// Request describes an export, rows created in [From, To) are exported.
+type Request struct {
+	Dataset string
+	Format  string
+	From    time.Time
+	To      time.Time
+}
+
+// Exporter streams datasets, rows are written as soon as they are read from the database.
+type Exporter struct {
+	invoiceRepository interface {
+		Export(ctx context.Context, from, to time.Time, fn func(*invoice.Invoice) error) error
+	}
+	userRepository interface {
+		Export(ctx context.Context, from, to time.Time, fn func(*user.User) error) error
+	}
+	transactionRepository interface {
+		Export(ctx context.Context, from, to time.Time, fn func(*invoice.Transaction) error) error
+	}
+}
+
+func NewExporter(invoiceRepository *invoice.Repository, userRepository *user.Repository, transactionRepository *invoice.TransactionRepository) *Exporter {
+	return &Exporter{invoiceRepository: invoiceRepository, userRepository: userRepository, transactionRepository: transactionRepository}
+}
+
+func (e *Exporter) Export(ctx context.Context, w io.Writer, req Request) error {
+	var model row
+	switch req.Dataset {
+	case datasetInvoices:
+		model = &invoiceRow{}
+	case datasetUsers:
+		model = &userRow{}
+	case datasetTransactions:
+		model = &transactionRow{}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownDataset, req.Dataset)
+	}
+
+	writer, err := newRowWriter(w, req.Format, model)
+	if err != nil {
+		return err
+	}
+
+	switch req.Dataset {
+	case datasetInvoices:
+		err = e.invoiceRepository.Export(ctx, req.From, req.To, func(i *invoice.Invoice) error {
+			return writer.Write(newInvoiceRow(i))
+		})
+	case datasetUsers:
+		err = e.userRepository.Export(ctx, req.From, req.To, func(u *user.User) error {
+			return writer.Write(newUserRow(u))
+		})
+	case datasetTransactions:
+		err = e.transactionRepository.Export(ctx, req.From, req.To, func(t *invoice.Transaction) error {
+			return writer.Write(newTransactionRow(t))
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("export %s: %w", req.Dataset, err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("close writer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeInvoiceRepository []invoice.Invoice
+
+func (f fakeInvoiceRepository) Export(_ context.Context, from, to time.Time, fn func(*invoice.Invoice) error) error {
+	for i := range f {
+		if f[i].CreatedAt.Before(from) || !f[i].CreatedAt.Before(to) {
+			continue
+		}
+		if err := fn(&f[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func testExporter() *Exporter {
+	createdAt := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	dueAt := time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)
+	return &Exporter{invoiceRepository: fakeInvoiceRepository{
+		{ID: 1, Number: 1, UserID: 1, Status: "paid", Label: "First, with a comma", Amount: 1000, CreatedAt: createdAt, DueAt: dueAt},
+		{ID: 2, Number: 2, UserID: 2, Status: "pending", Label: "Second", Amount: 2050, CreatedAt: createdAt, DueAt: dueAt},
+		{ID: 3, Number: 3, UserID: 2, Status: "pending", Label: "Out of range", Amount: 1, CreatedAt: dueAt, DueAt: dueAt},
+	}}
+}
+
+func TestExporter_Export(t *testing.T) {
+	req := Request{
+		Dataset: datasetInvoices,
+		From:    time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		req.Format = "csv"
+		var buf bytes.Buffer
+		require.NoError(t, testExporter().Export(context.Background(), &buf, req))
+		assert.Equal(t, "invoice_id,number,user_id,status,label,amount_cents,created_at,due_at\n"+
+			"1,1,1,paid,\"First, with a comma\",1000,2023-07-01T00:00:00Z,2023-07-31T00:00:00Z\n"+
+			"2,2,2,pending,Second,2050,2023-07-01T00:00:00Z,2023-07-31T00:00:00Z\n", buf.String())
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		req.Format = "jsonl"
+		var buf bytes.Buffer
+		require.NoError(t, testExporter().Export(context.Background(), &buf, req))
+		assert.Equal(t, `{"invoice_id":1,"number":1,"user_id":1,"status":"paid","label":"First, with a comma","amount_cents":1000,"created_at":"2023-07-01T00:00:00Z","due_at":"2023-07-31T00:00:00Z"}`+"\n"+
+			`{"invoice_id":2,"number":2,"user_id":2,"status":"pending","label":"Second","amount_cents":2050,"created_at":"2023-07-01T00:00:00Z","due_at":"2023-07-31T00:00:00Z"}`+"\n", buf.String())
+	})
+
+	t.Run("parquet", func(t *testing.T) {
+		req.Format = "parquet"
+		var buf bytes.Buffer
+		require.NoError(t, testExporter().Export(context.Background(), &buf, req))
+
+		rows, err := parquet.Read[invoiceRow](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, int64(2), rows[1].InvoiceID)
+		assert.Equal(t, int64(2050), rows[1].AmountCents)
+		assert.True(t, rows[1].DueAt.Equal(time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		req.Format = "xlsx"
+		require.ErrorIs(t, testExporter().Export(context.Background(), &bytes.Buffer{}, req), ErrUnknownFormat)
+	})
+}
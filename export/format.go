@@ -0,0 +1,188 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/parquet-go/parquet-go"
+)
+
+// contentTypes lists the supported formats and the content type they are served with.
+var contentTypes = map[string]string{
+	"csv":     "text/csv",
+	"jsonl":   "application/x-ndjson",
+	"parquet": "application/vnd.apache.parquet",
+}
+
+// maxRowsPerRowGroup bounds the number of rows the parquet writer keeps in memory.
+const maxRowsPerRowGroup = 10000
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+// row is a line of an export, the same struct is used by every format.
+type row interface {
+	header() []string
+	record() []string
+}
+
+type rowWriter interface {
+	Write(r row) error
+	Close() error
+}
+
+func newRowWriter(w io.Writer, format string, model row) (rowWriter, error) {
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(model.header()); err != nil {
+			return nil, fmt.Errorf("write header: %w", err)
+		}
+		return csvWriter{writer: writer}, nil
+	case "jsonl":
+		return jsonlWriter{encoder: json.NewEncoder(w)}, nil
+	case "parquet":
+		return parquetWriter{writer: parquet.NewWriter(w, parquet.SchemaOf(model), parquet.MaxRowsPerRowGroup(maxRowsPerRowGroup))}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (w csvWriter) Write(r row) error {
+	return w.writer.Write(r.record())
+}
+
+func (w csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (w jsonlWriter) Write(r row) error {
+	return w.encoder.Encode(r)
+}
+
+func (w jsonlWriter) Close() error {
+	return nil
+}
+
+type parquetWriter struct {
+	writer *parquet.Writer
+}
+
+func (w parquetWriter) Write(r row) error {
+	return w.writer.Write(r)
+}
+
+func (w parquetWriter) Close() error {
+	return w.writer.Close()
+}
+
+func formatInt(i int64) string {
+	return strconv.FormatInt(i, 10)
+}
+
+type invoiceRow struct {
+	InvoiceID   int64     `json:"invoice_id" parquet:"invoice_id"`
+	Number      int64     `json:"number" parquet:"number"`
+	UserID      int64     `json:"user_id" parquet:"user_id"`
+	Status      string    `json:"status" parquet:"status"`
+	Label       string    `json:"label" parquet:"label"`
+	AmountCents int64     `json:"amount_cents" parquet:"amount_cents"`
+	CreatedAt   time.Time `json:"created_at" parquet:"created_at"`
+	DueAt       time.Time `json:"due_at" parquet:"due_at"`
+}
+
+func newInvoiceRow(i *invoice.Invoice) *invoiceRow {
+	return &invoiceRow{
+		InvoiceID:   i.ID,
+		Number:      i.Number,
+		UserID:      i.UserID,
+		Status:      i.Status,
+		Label:       i.Label,
+		AmountCents: int64(i.Amount),
+		CreatedAt:   i.CreatedAt,
+		DueAt:       i.DueAt,
+	}
+}
+
+func (r *invoiceRow) header() []string {
+	return []string{"invoice_id", "number", "user_id", "status", "label", "amount_cents", "created_at", "due_at"}
+}
+
+func (r *invoiceRow) record() []string {
+	return []string{
+		formatInt(r.InvoiceID), formatInt(r.Number), formatInt(r.UserID), r.Status, r.Label, formatInt(r.AmountCents),
+		r.CreatedAt.Format(time.RFC3339), r.DueAt.Format(time.RFC3339),
+	}
+}
+
+type userRow struct {
+	UserID       int64     `json:"user_id" parquet:"user_id"`
+	FirstName    string    `json:"first_name" parquet:"first_name"`
+	LastName     string    `json:"last_name" parquet:"last_name"`
+	BalanceCents int64     `json:"balance_cents" parquet:"balance_cents"`
+	CreatedAt    time.Time `json:"created_at" parquet:"created_at"`
+}
+
+func newUserRow(u *user.User) *userRow {
+	return &userRow{
+		UserID:       u.ID,
+		FirstName:    u.FirstName,
+		LastName:     u.LastName,
+		BalanceCents: int64(u.Balance),
+		CreatedAt:    u.CreatedAt,
+	}
+}
+
+func (r *userRow) header() []string {
+	return []string{"user_id", "first_name", "last_name", "balance_cents", "created_at"}
+}
+
+func (r *userRow) record() []string {
+	return []string{formatInt(r.UserID), r.FirstName, r.LastName, formatInt(r.BalanceCents), r.CreatedAt.Format(time.RFC3339)}
+}
+
+type transactionRow struct {
+	TransactionID int64     `json:"transaction_id" parquet:"transaction_id"`
+	InvoiceID     int64     `json:"invoice_id" parquet:"invoice_id"`
+	UserID        int64     `json:"user_id" parquet:"user_id"`
+	AmountCents   int64     `json:"amount_cents" parquet:"amount_cents"`
+	Reference     string    `json:"reference" parquet:"reference"`
+	CreatedAt     time.Time `json:"created_at" parquet:"created_at"`
+}
+
+func newTransactionRow(t *invoice.Transaction) *transactionRow {
+	return &transactionRow{
+		TransactionID: t.ID,
+		InvoiceID:     t.InvoiceID,
+		UserID:        t.UserID,
+		AmountCents:   int64(t.Amount),
+		Reference:     t.Reference,
+		CreatedAt:     t.CreatedAt,
+	}
+}
+
+func (r *transactionRow) header() []string {
+	return []string{"transaction_id", "invoice_id", "user_id", "amount_cents", "reference", "created_at"}
+}
+
+func (r *transactionRow) record() []string {
+	return []string{
+		formatInt(r.TransactionID), formatInt(r.InvoiceID), formatInt(r.UserID), formatInt(r.AmountCents),
+		r.Reference, r.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,111 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/labstack/echo/v4"
+)
+
+type JobResponse struct {
+	JobID       string     `json:"job_id"`
+	Dataset     string     `json:"dataset"`
+	Format      string     `json:"format"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+func newJobResponse(job Job) JobResponse {
+	response := JobResponse{
+		JobID:     job.ID,
+		Dataset:   job.Request.Dataset,
+		Format:    job.Request.Format,
+		Status:    job.Status,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+	}
+	if !job.FinishedAt.IsZero() {
+		response.FinishedAt = &job.FinishedAt
+	}
+	if job.Status == JobDone {
+		response.DownloadURL = fmt.Sprintf("/exports/jobs/%s/download", job.ID)
+	}
+	return response
+}
+
+type CreateJobHandler struct {
+	jobs interface {
+		Enqueue(ctx context.Context, req Request) (Job, error)
+	}
+}
+
+func NewCreateJobHandler(jobs *Jobs) *CreateJobHandler {
+	return &CreateJobHandler{jobs: jobs}
+}
+
+func (h CreateJobHandler) Handle(c echo.Context) error {
+	req, err := parseRequest(c)
+	if err != nil {
+		return err
+	}
+
+	job, err := h.jobs.Enqueue(c.Request().Context(), req)
+	if err != nil {
+		if errors.Is(err, ErrQueueFull) {
+			return problem.New(http.StatusServiceUnavailable, problem.CodeTooManyExports, "too many exports in progress")
+		}
+		return fmt.Errorf("jobs.Enqueue: %w", err)
+	}
+
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/exports/jobs/%s", job.ID))
+	return c.JSON(http.StatusAccepted, newJobResponse(job))
+}
+
+type GetJobHandler struct {
+	jobs interface {
+		Get(ctx context.Context, id string) (Job, error)
+	}
+}
+
+func NewGetJobHandler(jobs *Jobs) *GetJobHandler {
+	return &GetJobHandler{jobs: jobs}
+}
+
+func (h GetJobHandler) Handle(c echo.Context) error {
+	job, err := h.jobs.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return problem.New(http.StatusNotFound, problem.CodeExportJobNotFound, "export job not found")
+	}
+
+	return c.JSON(http.StatusOK, newJobResponse(job))
+}
+
+type DownloadJobHandler struct {
+	jobs interface {
+		Get(ctx context.Context, id string) (Job, error)
+	}
+}
+
+func NewDownloadJobHandler(jobs *Jobs) *DownloadJobHandler {
+	return &DownloadJobHandler{jobs: jobs}
+}
+
+func (h DownloadJobHandler) Handle(c echo.Context) error {
+	job, err := h.jobs.Get(c.Request().Context(), c.Param("id"))
+	if err != nil {
+		return problem.New(http.StatusNotFound, problem.CodeExportJobNotFound, "export job not found")
+	}
+	if job.Status != JobDone {
+		return problem.New(http.StatusConflict, problem.CodeExportJobNotDone, "export job is not done")
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, contentTypes[job.Request.Format])
+	return c.Attachment(job.path, filename(job.Request))
+}
@@ -0,0 +1,181 @@
+package export
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/logging"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+)
+
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// jobQueueSize bounds the number of exports waiting for the worker.
+const jobQueueSize = 16
+
+// Job is an export running in the background, its file is kept until the retention expires.
+type Job struct {
+	ID         string
+	Request    Request
+	Status     string
+	Error      string
+	CreatedAt  time.Time
+	FinishedAt time.Time
+	path       string
+	// tenant is the tenant the job was enqueued for, it only exports and shows the job to that tenant.
+	tenant *tenant.Tenant
+}
+
+// Jobs runs the exports one at a time and keeps track of their results.
+type Jobs struct {
+	exporter  *Exporter
+	dir       string
+	retention time.Duration
+	queue     chan *Job
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func NewJobs(exporter *Exporter, dir string, retention time.Duration) *Jobs {
+	return &Jobs{
+		exporter:  exporter,
+		dir:       dir,
+		retention: retention,
+		queue:     make(chan *Job, jobQueueSize),
+		jobs:      map[string]*Job{},
+	}
+}
+
+var (
+	ErrQueueFull   = errors.New("export queue is full")
+	ErrJobNotFound = errors.New("export job not found")
+)
+
+func (j *Jobs) Enqueue(ctx context.Context, req Request) (Job, error) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return Job{}, tenant.ErrNoTenant
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Job{}, fmt.Errorf("generate id: %w", err)
+	}
+
+	job := &Job{ID: hex.EncodeToString(id), Request: req, Status: JobPending, CreatedAt: time.Now(), tenant: t}
+	job.path = filepath.Join(j.dir, fmt.Sprintf("export-%s.%s", job.ID, req.Format))
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	select {
+	case j.queue <- job:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	j.jobs[job.ID] = job
+
+	return *job, nil
+}
+
+// Get returns a copy of the job, so that it can be read while the worker updates it. The jobs of the other tenants
+// are not found.
+func (j *Jobs) Get(ctx context.Context, id string) (Job, error) {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return Job{}, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, ok := j.jobs[id]
+	if !ok || job.tenant.ID != tenantID {
+		return Job{}, ErrJobNotFound
+	}
+	return *job, nil
+}
+
+// Run processes the queued jobs until ctx is done, expired jobs are removed along the way.
+func (j *Jobs) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.expire(ctx, time.Now())
+		case job := <-j.queue:
+			j.run(ctx, job)
+		}
+	}
+}
+
+func (j *Jobs) run(ctx context.Context, job *Job) {
+	j.update(job, JobRunning, nil)
+
+	err := j.write(tenant.NewContext(ctx, job.tenant), job)
+	if err != nil {
+		logging.FromContext(ctx).Error("export job failed", slog.String("job_id", job.ID), slog.Any("error", err))
+		_ = os.Remove(job.path)
+	}
+	j.update(job, JobDone, err)
+}
+
+func (j *Jobs) write(ctx context.Context, job *Job) error {
+	f, err := os.Create(job.path)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer f.Close()
+
+	if err := j.exporter.Export(ctx, f, job.Request); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (j *Jobs) update(job *Job, status string, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job.Status = status
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = "export failed"
+	}
+	if job.Status == JobDone || job.Status == JobFailed {
+		job.FinishedAt = time.Now()
+	}
+}
+
+func (j *Jobs) expire(ctx context.Context, now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for id, job := range j.jobs {
+		if job.FinishedAt.IsZero() || now.Sub(job.FinishedAt) < j.retention {
+			continue
+		}
+		if err := os.Remove(job.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logging.FromContext(ctx).Error("export file not removed", slog.String("job_id", id), slog.Any("error", err))
+			continue
+		}
+		delete(j.jobs, id)
+	}
+}
@@ -0,0 +1,45 @@
+package export
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobs(t *testing.T) {
+	jobs := NewJobs(testExporter(), t.TempDir(), time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go jobs.Run(ctx)
+
+	acme := tenant.NewContext(context.Background(), &tenant.Tenant{ID: "acme"})
+	job, err := jobs.Enqueue(acme, Request{Dataset: datasetInvoices, Format: "csv", To: endOfTime})
+	require.NoError(t, err)
+	assert.Equal(t, JobPending, job.Status)
+
+	// Wait for the worker to write the file
+	require.Eventually(t, func() bool {
+		job, err = jobs.Get(acme, job.ID)
+		return err == nil && job.Status == JobDone
+	}, time.Second, 10*time.Millisecond)
+
+	// The job is not visible to the other tenants
+	_, err = jobs.Get(tenant.NewContext(context.Background(), &tenant.Tenant{ID: "globex"}), job.ID)
+	require.ErrorIs(t, err, ErrJobNotFound)
+
+	content, err := os.ReadFile(job.path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "Out of range")
+
+	// Once the retention is over, the file and the job are removed
+	jobs.expire(context.Background(), job.FinishedAt.Add(time.Hour))
+	_, err = jobs.Get(acme, job.ID)
+	require.ErrorIs(t, err, ErrJobNotFound)
+	_, err = os.Stat(job.path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,88 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/labstack/echo/v4"
+)
+
+// endOfTime is used when the request does not bound the period.
+var endOfTime = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// parseRequest reads the dataset from the path, the format and the [from, to) period from the query.
+func parseRequest(c echo.Context) (Request, error) {
+	req := Request{Dataset: c.Param("dataset"), Format: c.QueryParam("format"), To: endOfTime}
+	switch req.Dataset {
+	case datasetInvoices, datasetUsers, datasetTransactions:
+	default:
+		return req, problem.New(http.StatusNotFound, problem.CodeDatasetNotFound, "dataset not found")
+	}
+
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+	if _, ok := contentTypes[req.Format]; !ok {
+		return req, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid format")
+	}
+
+	var err error
+	if from := c.QueryParam("from"); from != "" {
+		if req.From, err = parseDate(from); err != nil {
+			return req, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid from")
+		}
+	}
+	if to := c.QueryParam("to"); to != "" {
+		if req.To, err = parseDate(to); err != nil {
+			return req, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid to")
+		}
+	}
+	if !req.From.Before(req.To) {
+		return req, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "from must be before to")
+	}
+
+	return req, nil
+}
+
+func parseDate(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Parse(time.DateOnly, s)
+	}
+	return t, nil
+}
+
+func filename(req Request) string {
+	return fmt.Sprintf("%s.%s", req.Dataset, req.Format)
+}
+
+type StreamHandler struct {
+	exporter interface {
+		Export(ctx context.Context, w io.Writer, req Request) error
+	}
+}
+
+func NewStreamHandler(exporter *Exporter) *StreamHandler {
+	return &StreamHandler{exporter: exporter}
+}
+
+func (h StreamHandler) Handle(c echo.Context) error {
+	req, err := parseRequest(c)
+	if err != nil {
+		return err
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, contentTypes[req.Format])
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename(req)))
+	c.Response().WriteHeader(http.StatusOK)
+
+	// The status is already sent, a failure can only abort the stream.
+	if err := h.exporter.Export(c.Request().Context(), c.Response(), req); err != nil {
+		return fmt.Errorf("exporter.Export: %w", err)
+	}
+	return nil
+}
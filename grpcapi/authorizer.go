@@ -0,0 +1,93 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/logging"
+	invoicev1 "github.com/emilien-puget/invoice_microservice/proto/invoice/v1"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// permissions lists the permission required by every method, the methods missing are denied.
+var permissions = map[string]string{
+	invoicev1.UserService_ListUsers_FullMethodName:                auth.ScopeUsersRead,
+	invoicev1.InvoiceService_CreateInvoice_FullMethodName:         auth.ScopeInvoicesWrite,
+	invoicev1.InvoiceService_GetInvoice_FullMethodName:            auth.ScopeInvoicesRead,
+	invoicev1.TransactionService_CreateTransaction_FullMethodName: auth.ScopeTransactionsWrite,
+}
+
+// authorizer does for the calls what the auth and tenant middlewares do for the requests: it authenticates the
+// call, resolves its tenant and checks that the principal was granted the permission of the method. Credentials
+// and the tenant are read from the metadata, under the names of the HTTP headers.
+type authorizer struct {
+	tenantRepository interface {
+		GetByID(ctx context.Context, id string) (*tenant.Tenant, error)
+	}
+	authenticators []auth.Authenticator
+}
+
+// intercept lets the health checks through, the reflection is a stream and is not intercepted.
+func (a *authorizer) intercept(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if strings.HasPrefix(info.FullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/") {
+		return handler(ctx, req)
+	}
+	permission, ok := permissions[info.FullMethod]
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "method not allowed")
+	}
+
+	r := request(ctx)
+	p, err := auth.Authenticate(r, a.authenticators...)
+	if err != nil {
+		if !errors.Is(err, auth.ErrNoCredentials) && !errors.Is(err, auth.ErrInvalidCredentials) {
+			logging.FromContext(ctx).Error("authentication failed", slog.Any("error", err))
+		}
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	ctx = auth.NewContext(ctx, p)
+
+	t, err := tenant.Resolve(ctx, a.tenantRepository, r.Header.Get(tenant.HeaderTenantID))
+	if err != nil {
+		switch {
+		case errors.Is(err, tenant.ErrTenantNotAllowed):
+			return nil, status.Error(codes.PermissionDenied, "tenant not allowed")
+		case errors.Is(err, tenant.ErrTenantRequired):
+			return nil, status.Error(codes.InvalidArgument, "tenant required")
+		}
+		logging.FromContext(ctx).Error("call failed", slog.Any("error", err))
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	ctx = tenant.NewContext(ctx, t)
+
+	if !p.Can(permission) {
+		return nil, status.Error(codes.PermissionDenied, "missing permission "+permission)
+	}
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return resp, nil
+}
+
+// request carries the metadata of the call as headers, for the authenticators.
+func request(ctx context.Context) *http.Request {
+	r := &http.Request{Method: http.MethodPost, Header: http.Header{}}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		for _, value := range values {
+			r.Header.Add(key, value)
+		}
+	}
+	return r.WithContext(ctx)
+}
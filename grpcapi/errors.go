@@ -0,0 +1,39 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/logging"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus maps the errors of the services to the codes of the REST API statuses, the other errors are logged and
+// hidden from the caller.
+func toStatus(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrors):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, user.ErrUserNotFound):
+		return status.Error(codes.NotFound, "user not found")
+	case errors.Is(err, invoice.ErrInvoiceNotFound):
+		return status.Error(codes.NotFound, "invoice not found")
+	case errors.Is(err, invoice.ErrInvalidAmount):
+		return status.Error(codes.InvalidArgument, "invalid amount")
+	case errors.Is(err, invoice.ErrInvoiceAlreadyPaid):
+		return status.Error(codes.FailedPrecondition, "invoice already paid")
+	}
+
+	logging.FromContext(ctx).Error("call failed", slog.Any("error", err))
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,54 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	invoicev1 "github.com/emilien-puget/invoice_microservice/proto/invoice/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type invoiceService struct {
+	invoicev1.UnimplementedInvoiceServiceServer
+	invoices interface {
+		Create(ctx context.Context, payload invoice.CreateInvoicePayload) (invoice.Invoice, error)
+		Get(ctx context.Context, id int64) (*invoice.Invoice, error)
+	}
+	billing invoice.Billing
+}
+
+func (s *invoiceService) CreateInvoice(ctx context.Context, req *invoicev1.CreateInvoiceRequest) (*invoicev1.CreateInvoiceResponse, error) {
+	payload := invoice.CreateInvoicePayload{
+		UserID: req.GetUserId(),
+		Amount: req.GetAmount(),
+		Label:  req.GetLabel(),
+	}
+	if req.GetDueAt() != nil {
+		dueAt := req.GetDueAt().AsTime()
+		payload.DueAt = &dueAt
+	}
+
+	created, err := s.invoices.Create(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &invoicev1.CreateInvoiceResponse{InvoiceId: created.ID}, nil
+}
+
+func (s *invoiceService) GetInvoice(ctx context.Context, req *invoicev1.GetInvoiceRequest) (*invoicev1.GetInvoiceResponse, error) {
+	found, err := s.invoices.Get(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return &invoicev1.GetInvoiceResponse{Invoice: &invoicev1.Invoice{
+		Id:        found.ID,
+		Number:    s.billing.ForTenant(ctx).Number(found),
+		UserId:    found.UserID,
+		Status:    found.Status,
+		Label:     found.Label,
+		Amount:    found.Amount.ToFloat(),
+		CreatedAt: timestamppb.New(found.CreatedAt),
+		DueAt:     timestamppb.New(found.DueAt),
+	}}, nil
+}
@@ -0,0 +1,47 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// metadataRequestID is the X-Request-ID header of the REST API.
+const metadataRequestID = "x-request-id"
+
+// requestLogger propagates the request ID of the call, or a new ID, to the response headers and to the logger of the
+// call context, then logs the call once it is handled.
+func requestLogger(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		var id string
+		if values := metadata.ValueFromIncomingContext(ctx, metadataRequestID); len(values) > 0 {
+			id = values[0]
+		}
+		id = logging.RequestID(id)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(metadataRequestID, id))
+		callLogger := logging.ForRequest(ctx, logger, id)
+		ctx = logging.NewContext(ctx, callLogger)
+
+		resp, err := handler(ctx, req)
+
+		code := status.Code(err)
+		level := slog.LevelInfo
+		if code == codes.Internal || code == codes.Unknown {
+			level = slog.LevelError
+		}
+		callLogger.LogAttrs(ctx, level, "call",
+			slog.String("method", info.FullMethod),
+			slog.String("code", code.String()),
+			slog.Duration("latency", time.Since(start)),
+		)
+		return resp, err
+	}
+}
@@ -0,0 +1,88 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"net"
+
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	invoicev1 "github.com/emilien-puget/invoice_microservice/proto/invoice/v1"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server serves the gRPC API along with the health and reflection services, on top of the same services as the
+// REST API.
+type Server struct {
+	server *grpc.Server
+	health *health.Server
+}
+
+func NewServer(logger *slog.Logger, users *user.Users, invoices *invoice.Invoices, payments *invoice.Payments, billing invoice.Billing, tenantRepository *tenant.Repository, authenticators ...auth.Authenticator) *Server {
+	return newServer(
+		logger,
+		&userService{users: users},
+		&invoiceService{invoices: invoices, billing: billing},
+		&transactionService{payments: payments},
+		&authorizer{tenantRepository: tenantRepository, authenticators: authenticators},
+	)
+}
+
+func newServer(logger *slog.Logger, users invoicev1.UserServiceServer, invoices invoicev1.InvoiceServiceServer, transactions invoicev1.TransactionServiceServer, authorizer *authorizer) *Server {
+	s := &Server{
+		server: grpc.NewServer(
+			grpc.StatsHandler(otelgrpc.NewServerHandler()),
+			grpc.ChainUnaryInterceptor(requestLogger(logger), authorizer.intercept),
+		),
+		health: health.NewServer(),
+	}
+	invoicev1.RegisterUserServiceServer(s.server, users)
+	invoicev1.RegisterInvoiceServiceServer(s.server, invoices)
+	invoicev1.RegisterTransactionServiceServer(s.server, transactions)
+	healthpb.RegisterHealthServer(s.server, s.health)
+	reflection.Register(s.server)
+
+	for _, service := range []string{
+		invoicev1.UserService_ServiceDesc.ServiceName,
+		invoicev1.InvoiceService_ServiceDesc.ServiceName,
+		invoicev1.TransactionService_ServiceDesc.ServiceName,
+	} {
+		s.health.SetServingStatus(service, healthpb.HealthCheckResponse_SERVING)
+	}
+	return s
+}
+
+// Serve accepts the calls on lis until Shutdown is called.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.server.Serve(lis)
+}
+
+// Drain reports the services as not serving while they still serve, so that the clients move away before Shutdown.
+func (s *Server) Drain() {
+	s.health.Shutdown()
+}
+
+// Shutdown reports the services as not serving and waits for the calls in flight until ctx is done, they are cancelled
+// then.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,195 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/money"
+	invoicev1 "github.com/emilien-puget/invoice_microservice/proto/invoice/v1"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeAuthenticator authenticates the role named by the API key header.
+type fakeAuthenticator struct{}
+
+func (fakeAuthenticator) Authenticate(r *http.Request) (*auth.Principal, error) {
+	role := r.Header.Get("X-Api-Key")
+	if role == "" {
+		return nil, auth.ErrNoCredentials
+	}
+	return &auth.Principal{Subject: "test", Method: auth.MethodAPIKey, Role: role, UserID: 1, TenantID: "acme"}, nil
+}
+
+type fakeTenantRepository struct{}
+
+func (fakeTenantRepository) GetByID(_ context.Context, id string) (*tenant.Tenant, error) {
+	return &tenant.Tenant{ID: id}, nil
+}
+
+type fakeUsers struct{}
+
+func (fakeUsers) List(ctx context.Context) ([]*user.User, error) {
+	if _, err := tenant.ID(ctx); err != nil {
+		return nil, err
+	}
+	return []*user.User{{ID: 1, FirstName: "Ada", LastName: "Lovelace", Balance: money.NewMoneyFromFloat(12.5)}}, nil
+}
+
+type fakeInvoices struct{}
+
+func (fakeInvoices) Create(_ context.Context, payload invoice.CreateInvoicePayload) (invoice.Invoice, error) {
+	if err := validator.New().Struct(payload); err != nil {
+		return invoice.Invoice{}, err
+	}
+	if payload.UserID != 1 {
+		return invoice.Invoice{}, user.ErrUserNotFound
+	}
+	return invoice.Invoice{ID: 10}, nil
+}
+
+func (fakeInvoices) Get(_ context.Context, id int64) (*invoice.Invoice, error) {
+	if id != 10 {
+		return nil, invoice.ErrInvoiceNotFound
+	}
+	return &invoice.Invoice{ID: 10, Number: 3, UserID: 1, Status: "pending", Label: "test", Amount: money.NewMoneyFromFloat(20)}, nil
+}
+
+type fakePayments struct{}
+
+func (fakePayments) Apply(_ context.Context, payload invoice.TransactionPayload) error {
+	if payload.InvoiceID == 11 {
+		return invoice.ErrInvoiceAlreadyPaid
+	}
+	return nil
+}
+
+// dial serves the fakes on an in-memory listener.
+func dial(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	s := newServer(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		&userService{users: fakeUsers{}},
+		&invoiceService{invoices: fakeInvoices{}, billing: invoice.Billing{NumberFormat: "INV-%06d"}},
+		&transactionService{payments: fakePayments{}},
+		&authorizer{tenantRepository: fakeTenantRepository{}, authenticators: []auth.Authenticator{fakeAuthenticator{}}},
+	)
+	lis := bufconn.Listen(1024 * 1024)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(func() { _ = s.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func withRole(role string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", role)
+}
+
+func TestServer(t *testing.T) {
+	conn := dial(t)
+	users := invoicev1.NewUserServiceClient(conn)
+	invoices := invoicev1.NewInvoiceServiceClient(conn)
+	transactions := invoicev1.NewTransactionServiceClient(conn)
+
+	t.Run("list users", func(t *testing.T) {
+		resp, err := users.ListUsers(withRole(auth.RoleFinance), &invoicev1.ListUsersRequest{})
+		require.NoError(t, err)
+		require.Len(t, resp.GetUsers(), 1)
+		assert.Equal(t, "Ada", resp.GetUsers()[0].GetFirstName())
+		assert.Equal(t, 12.5, resp.GetUsers()[0].GetBalance())
+	})
+
+	t.Run("get invoice", func(t *testing.T) {
+		resp, err := invoices.GetInvoice(withRole(auth.RoleCustomer), &invoicev1.GetInvoiceRequest{Id: 10})
+		require.NoError(t, err)
+		assert.Equal(t, "INV-000003", resp.GetInvoice().GetNumber())
+		assert.Equal(t, 20.0, resp.GetInvoice().GetAmount())
+	})
+
+	t.Run("create invoice", func(t *testing.T) {
+		resp, err := invoices.CreateInvoice(withRole(auth.RoleFinance), &invoicev1.CreateInvoiceRequest{UserId: 1, Amount: 20, Label: "test"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), resp.GetInvoiceId())
+	})
+
+	for name, test := range map[string]struct {
+		call func() error
+		code codes.Code
+	}{
+		"no credentials": {
+			call: func() error {
+				_, err := users.ListUsers(context.Background(), &invoicev1.ListUsersRequest{})
+				return err
+			},
+			code: codes.Unauthenticated,
+		},
+		"missing permission": {
+			call: func() error {
+				_, err := transactions.CreateTransaction(withRole(auth.RoleCustomer), &invoicev1.CreateTransactionRequest{InvoiceId: 10, Amount: 20, Reference: "bank-1"})
+				return err
+			},
+			code: codes.PermissionDenied,
+		},
+		"invoice not found": {
+			call: func() error {
+				_, err := invoices.GetInvoice(withRole(auth.RoleFinance), &invoicev1.GetInvoiceRequest{Id: 404})
+				return err
+			},
+			code: codes.NotFound,
+		},
+		"user not found": {
+			call: func() error {
+				_, err := invoices.CreateInvoice(withRole(auth.RoleFinance), &invoicev1.CreateInvoiceRequest{UserId: 2, Amount: 20, Label: "test"})
+				return err
+			},
+			code: codes.NotFound,
+		},
+		"invalid payload": {
+			call: func() error {
+				_, err := invoices.CreateInvoice(withRole(auth.RoleFinance), &invoicev1.CreateInvoiceRequest{UserId: 1})
+				return err
+			},
+			code: codes.InvalidArgument,
+		},
+		"already paid": {
+			call: func() error {
+				_, err := transactions.CreateTransaction(withRole(auth.RolePaymentProcessor), &invoicev1.CreateTransactionRequest{InvoiceId: 11, Amount: 20, Reference: "bank-1"})
+				return err
+			},
+			code: codes.FailedPrecondition,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.code, status.Code(test.call()))
+		})
+	}
+
+	t.Run("health without credentials", func(t *testing.T) {
+		resp, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{Service: invoicev1.InvoiceService_ServiceDesc.ServiceName})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+	})
+}
@@ -0,0 +1,27 @@
+package grpcapi
+
+import (
+	"context"
+
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	invoicev1 "github.com/emilien-puget/invoice_microservice/proto/invoice/v1"
+)
+
+type transactionService struct {
+	invoicev1.UnimplementedTransactionServiceServer
+	payments interface {
+		Apply(ctx context.Context, payload invoice.TransactionPayload) error
+	}
+}
+
+func (s *transactionService) CreateTransaction(ctx context.Context, req *invoicev1.CreateTransactionRequest) (*invoicev1.CreateTransactionResponse, error) {
+	err := s.payments.Apply(ctx, invoice.TransactionPayload{
+		InvoiceID: req.GetInvoiceId(),
+		Amount:    req.GetAmount(),
+		Reference: req.GetReference(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &invoicev1.CreateTransactionResponse{}, nil
+}
@@ -0,0 +1,33 @@
+package grpcapi
+
+import (
+	"context"
+
+	invoicev1 "github.com/emilien-puget/invoice_microservice/proto/invoice/v1"
+	"github.com/emilien-puget/invoice_microservice/user"
+)
+
+type userService struct {
+	invoicev1.UnimplementedUserServiceServer
+	users interface {
+		List(ctx context.Context) ([]*user.User, error)
+	}
+}
+
+func (s *userService) ListUsers(ctx context.Context, _ *invoicev1.ListUsersRequest) (*invoicev1.ListUsersResponse, error) {
+	users, err := s.users.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &invoicev1.ListUsersResponse{Users: make([]*invoicev1.User, len(users))}
+	for i, u := range users {
+		resp.Users[i] = &invoicev1.User{
+			Id:        u.ID,
+			FirstName: u.FirstName,
+			LastName:  u.LastName,
+			Balance:   u.Balance.ToFloat(),
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,69 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/storage"
+)
+
+var (
+	ErrSchemaDirty    = errors.New("a migration failed midway")
+	ErrSchemaOutdated = errors.New("migrations are missing")
+	ErrOutboxLagging  = errors.New("outbox is lagging")
+)
+
+// Ping checks that the database answers.
+func Ping(db *sql.DB) Check {
+	return func(ctx context.Context) (string, error) {
+		if err := db.PingContext(ctx); err != nil {
+			return "", fmt.Errorf("db.PingContext: %w", err)
+		}
+		stats := db.Stats()
+		return fmt.Sprintf("%d open connections, %d in use", stats.OpenConnections, stats.InUse), nil
+	}
+}
+
+// Migrations checks that the schema is at latest, the version of the last migration known to this build. A schema
+// ahead of it passes, it is the one of a newer build being rolled out.
+func Migrations(db *sql.DB, latest uint) Check {
+	return func(ctx context.Context) (string, error) {
+		version, dirty, err := storage.SchemaVersion(ctx, db)
+		if err != nil {
+			return "", err
+		}
+
+		detail := fmt.Sprintf("version %d, expected %d", version, latest)
+		switch {
+		case dirty:
+			return detail, ErrSchemaDirty
+		case version < latest:
+			return detail, ErrSchemaOutdated
+		default:
+			return detail, nil
+		}
+	}
+}
+
+// OutboxLag checks that the oldest unpublished event of the outbox is not older than maxLag, the relay is stuck
+// otherwise.
+func OutboxLag(outboxRepository interface {
+	Lag(ctx context.Context) (time.Duration, error)
+}, maxLag time.Duration,
+) Check {
+	return func(ctx context.Context) (string, error) {
+		lag, err := outboxRepository.Lag(ctx)
+		if err != nil {
+			return "", fmt.Errorf("outboxRepository.Lag: %w", err)
+		}
+
+		detail := fmt.Sprintf("oldest unpublished event is %s old", lag.Round(time.Millisecond))
+		if lag > maxLag {
+			return detail, fmt.Errorf("%w: more than %s", ErrOutboxLagging, maxLag)
+		}
+		return detail, nil
+	}
+}
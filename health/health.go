@@ -0,0 +1,139 @@
+// Package health tells the orchestrator whether the service is alive and whether it is ready to serve requests.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The statuses of the reports and of the checks.
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+	// StatusDraining is the status of readiness once the service is shutting down.
+	StatusDraining = "draining"
+)
+
+// Check probes a dependency, it returns an error when the dependency is unusable. The detail is reported either way.
+type Check func(ctx context.Context) (detail string, err error)
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Registry runs the checks of the dependencies the service needs to serve requests.
+type Registry struct {
+	timeout  time.Duration
+	mu       sync.RWMutex
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// NewRegistry returns a registry giving up on a check after timeout.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds a check to readiness, name identifies it in the reports.
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, namedCheck{name: name, check: check})
+}
+
+// Drain makes readiness fail from now on, call it when the shutdown starts so that the load balancers stop sending
+// requests before the servers stop.
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+// Report is the body of the health endpoints.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Status   string `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Live reports that the process is up, it does not depend on the checks so that a failing dependency does not get
+// the service restarted.
+func (r *Registry) Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: StatusOK})
+	})
+}
+
+// Ready runs every check and fails when one of them fails or when the service is draining.
+func (r *Registry) Ready() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.run(req.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, status, report)
+	})
+}
+
+// run runs the checks concurrently.
+func (r *Registry) run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := r.checks
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = r.runCheck(ctx, check)
+		}(i, check.check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, check := range checks {
+		report.Checks[check.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	if r.draining.Load() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func (r *Registry) runCheck(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := check(ctx)
+	result := Result{Status: StatusOK, Detail: detail, Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
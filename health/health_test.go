@@ -0,0 +1,173 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errUnreachable = errors.New("unreachable")
+
+func passing(context.Context) (string, error) {
+	return "fine", nil
+}
+
+func failing(context.Context) (string, error) {
+	return "", errUnreachable
+}
+
+// hanging returns once the registry gives up on it.
+func hanging(ctx context.Context) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestRegistry_Ready(t *testing.T) {
+	for name, test := range map[string]struct {
+		checks   map[string]Check
+		drain    bool
+		status   int
+		expected Report
+	}{
+		"ready": {
+			checks: map[string]Check{"database": passing, "outbox": passing},
+			status: http.StatusOK,
+			expected: Report{Status: StatusOK, Checks: map[string]Result{
+				"database": {Status: StatusOK, Detail: "fine"},
+				"outbox":   {Status: StatusOK, Detail: "fine"},
+			}},
+		},
+		"failing check": {
+			checks: map[string]Check{"database": failing, "outbox": passing},
+			status: http.StatusServiceUnavailable,
+			expected: Report{Status: StatusFailing, Checks: map[string]Result{
+				"database": {Status: StatusFailing, Error: "unreachable"},
+				"outbox":   {Status: StatusOK, Detail: "fine"},
+			}},
+		},
+		"timed out check": {
+			checks: map[string]Check{"database": hanging},
+			status: http.StatusServiceUnavailable,
+			expected: Report{Status: StatusFailing, Checks: map[string]Result{
+				"database": {Status: StatusFailing, Error: "context deadline exceeded"},
+			}},
+		},
+		"draining": {
+			checks: map[string]Check{"database": passing},
+			drain:  true,
+			status: http.StatusServiceUnavailable,
+			expected: Report{Status: StatusDraining, Checks: map[string]Result{
+				"database": {Status: StatusOK, Detail: "fine"},
+			}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			registry := NewRegistry(10 * time.Millisecond)
+			for name, check := range test.checks {
+				registry.Register(name, check)
+			}
+			if test.drain {
+				registry.Drain()
+			}
+
+			rec := httptest.NewRecorder()
+			registry.Ready().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, test.status, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			var report Report
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+			for name, result := range report.Checks {
+				assert.NotEmpty(t, result.Duration)
+				result.Duration = ""
+				report.Checks[name] = result
+			}
+			assert.Equal(t, test.expected, report)
+		})
+	}
+}
+
+func TestRegistry_Live(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register("database", failing)
+	registry.Drain()
+
+	rec := httptest.NewRecorder()
+	registry.Live().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	// A failing dependency or a shutdown does not make the process dead
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestMigrations(t *testing.T) {
+	const query = "SELECT version, dirty FROM jump.public.schema_migrations LIMIT 1"
+
+	for name, test := range map[string]struct {
+		rows   *sqlmock.Rows
+		detail string
+		err    error
+	}{
+		"up to date": {
+			rows:   sqlmock.NewRows([]string{"version", "dirty"}).AddRow(8, false),
+			detail: "version 8, expected 8",
+		},
+		"ahead": {
+			rows:   sqlmock.NewRows([]string{"version", "dirty"}).AddRow(9, false),
+			detail: "version 9, expected 8",
+		},
+		"outdated": {
+			rows:   sqlmock.NewRows([]string{"version", "dirty"}).AddRow(7, false),
+			detail: "version 7, expected 8",
+			err:    ErrSchemaOutdated,
+		},
+		"never migrated": {
+			rows:   sqlmock.NewRows([]string{"version", "dirty"}),
+			detail: "version 0, expected 8",
+			err:    ErrSchemaOutdated,
+		},
+		"dirty": {
+			rows:   sqlmock.NewRows([]string{"version", "dirty"}).AddRow(8, true),
+			detail: "version 8, expected 8",
+			err:    ErrSchemaDirty,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Create a new mock database connection
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer db.Close()
+
+			mock.ExpectQuery(query).WillReturnRows(test.rows)
+
+			detail, err := Migrations(db, 8)(context.Background())
+			assert.Equal(t, test.detail, detail)
+			assert.ErrorIs(t, err, test.err)
+			// Ensure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+type fakeOutbox time.Duration
+
+func (f fakeOutbox) Lag(context.Context) (time.Duration, error) {
+	return time.Duration(f), nil
+}
+
+func TestOutboxLag(t *testing.T) {
+	detail, err := OutboxLag(fakeOutbox(1500*time.Millisecond), time.Minute)(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "oldest unpublished event is 1.5s old", detail)
+
+	_, err = OutboxLag(fakeOutbox(2*time.Minute), time.Minute)(context.Background())
+	assert.ErrorIs(t, err, ErrOutboxLagging)
+}
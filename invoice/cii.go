@@ -0,0 +1,197 @@
+package invoice
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+)
+
+// ciiDateFormat is the UNTDID 2379 code for CCYYMMDD dates.
+const ciiDateFormat = "102"
+
+type ciiInvoice struct {
+	XMLName     xml.Name       `xml:"rsm:CrossIndustryInvoice"`
+	XmlnsRsm    string         `xml:"xmlns:rsm,attr"`
+	XmlnsRam    string         `xml:"xmlns:ram,attr"`
+	XmlnsUdt    string         `xml:"xmlns:udt,attr"`
+	Context     string         `xml:"rsm:ExchangedDocumentContext>ram:GuidelineSpecifiedDocumentContextParameter>ram:ID"`
+	Document    ciiDocument    `xml:"rsm:ExchangedDocument"`
+	Transaction ciiTransaction `xml:"rsm:SupplyChainTradeTransaction"`
+}
+
+type ciiDocument struct {
+	ID            string  `xml:"ram:ID"`
+	TypeCode      string  `xml:"ram:TypeCode"`
+	IssueDateTime ciiDate `xml:"ram:IssueDateTime>udt:DateTimeString"`
+}
+
+type ciiDate struct {
+	Format string `xml:"format,attr"`
+	Value  string `xml:",chardata"`
+}
+
+type ciiTransaction struct {
+	LineItem   ciiLineItem   `xml:"ram:IncludedSupplyChainTradeLineItem"`
+	Agreement  ciiAgreement  `xml:"ram:ApplicableHeaderTradeAgreement"`
+	Delivery   struct{}      `xml:"ram:ApplicableHeaderTradeDelivery"`
+	Settlement ciiSettlement `xml:"ram:ApplicableHeaderTradeSettlement"`
+}
+
+type ciiLineItem struct {
+	LineID         string            `xml:"ram:AssociatedDocumentLineDocument>ram:LineID"`
+	ProductName    string            `xml:"ram:SpecifiedTradeProduct>ram:Name"`
+	NetPrice       string            `xml:"ram:SpecifiedLineTradeAgreement>ram:NetPriceProductTradePrice>ram:ChargeAmount"`
+	BilledQuantity ciiQuantity       `xml:"ram:SpecifiedLineTradeDelivery>ram:BilledQuantity"`
+	Settlement     ciiLineSettlement `xml:"ram:SpecifiedLineTradeSettlement"`
+}
+
+type ciiQuantity struct {
+	UnitCode string `xml:"unitCode,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type ciiLineSettlement struct {
+	Tax             ciiTax `xml:"ram:ApplicableTradeTax"`
+	LineTotalAmount string `xml:"ram:SpecifiedTradeSettlementLineMonetarySummation>ram:LineTotalAmount"`
+}
+
+type ciiTax struct {
+	CalculatedAmount      string `xml:"ram:CalculatedAmount,omitempty"`
+	TypeCode              string `xml:"ram:TypeCode"`
+	BasisAmount           string `xml:"ram:BasisAmount,omitempty"`
+	CategoryCode          string `xml:"ram:CategoryCode"`
+	RateApplicablePercent string `xml:"ram:RateApplicablePercent"`
+}
+
+type ciiAgreement struct {
+	Seller ciiParty `xml:"ram:SellerTradeParty"`
+	Buyer  ciiParty `xml:"ram:BuyerTradeParty"`
+}
+
+type ciiParty struct {
+	Name            string              `xml:"ram:Name"`
+	Address         ciiAddress          `xml:"ram:PostalTradeAddress"`
+	TaxRegistration *ciiTaxRegistration `xml:"ram:SpecifiedTaxRegistration,omitempty"`
+}
+
+type ciiAddress struct {
+	PostcodeCode string `xml:"ram:PostcodeCode,omitempty"`
+	LineOne      string `xml:"ram:LineOne,omitempty"`
+	CityName     string `xml:"ram:CityName,omitempty"`
+	CountryID    string `xml:"ram:CountryID"`
+}
+
+type ciiTaxRegistration struct {
+	ID ciiID `xml:"ram:ID"`
+}
+
+type ciiID struct {
+	SchemeID string `xml:"schemeID,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type ciiSettlement struct {
+	PaymentReference    string               `xml:"ram:PaymentReference"`
+	InvoiceCurrencyCode string               `xml:"ram:InvoiceCurrencyCode"`
+	PaymentMeans        *ciiPaymentMeans     `xml:"ram:SpecifiedTradeSettlementPaymentMeans,omitempty"`
+	Tax                 ciiTax               `xml:"ram:ApplicableTradeTax"`
+	DueDate             ciiDate              `xml:"ram:SpecifiedTradePaymentTerms>ram:DueDateDateTime>udt:DateTimeString"`
+	Summation           ciiMonetarySummation `xml:"ram:SpecifiedTradeSettlementHeaderMonetarySummation"`
+}
+
+type ciiPaymentMeans struct {
+	TypeCode string `xml:"ram:TypeCode"`
+	IBAN     string `xml:"ram:PayeePartyCreditorFinancialAccount>ram:IBANID"`
+}
+
+type ciiMonetarySummation struct {
+	LineTotalAmount     string    `xml:"ram:LineTotalAmount"`
+	TaxBasisTotalAmount string    `xml:"ram:TaxBasisTotalAmount"`
+	TaxTotalAmount      ciiAmount `xml:"ram:TaxTotalAmount"`
+	GrandTotalAmount    string    `xml:"ram:GrandTotalAmount"`
+	DuePayableAmount    string    `xml:"ram:DuePayableAmount"`
+}
+
+type ciiAmount struct {
+	CurrencyID string `xml:"currencyID,attr"`
+	Value      string `xml:",chardata"`
+}
+
+func newCIIDate(t time.Time) ciiDate {
+	return ciiDate{Format: ciiDateFormat, Value: t.UTC().Format("20060102")}
+}
+
+func newCIIParty(p Party) ciiParty {
+	party := ciiParty{
+		Name: p.Name,
+		Address: ciiAddress{
+			PostcodeCode: p.PostalCode,
+			LineOne:      p.Street,
+			CityName:     p.City,
+			CountryID:    p.CountryCode,
+		},
+	}
+	if p.VatID != "" {
+		party.TaxRegistration = &ciiTaxRegistration{ID: ciiID{SchemeID: "VA", Value: p.VatID}}
+	}
+	return party
+}
+
+// WriteCII writes doc as a UN/CEFACT Cross Industry Invoice, it is the XML embedded in Factur-X and ZUGFeRD
+// documents using the EN 16931 profile.
+func WriteCII(w io.Writer, doc Document) error {
+	category := taxCategory(doc.TaxRate)
+	percent := formatPercent(doc.TaxRate)
+
+	invoice := ciiInvoice{
+		XmlnsRsm: "urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100",
+		XmlnsRam: "urn:un:unece:uncefact:data:standard:ReusableAggregateBusinessInformationEntity:100",
+		XmlnsUdt: "urn:un:unece:uncefact:data:standard:UnqualifiedDataType:100",
+		Context:  en16931,
+		Document: ciiDocument{
+			ID:            doc.Number,
+			TypeCode:      commercialInvoiceTypeCode,
+			IssueDateTime: newCIIDate(doc.Invoice.CreatedAt),
+		},
+		Transaction: ciiTransaction{
+			LineItem: ciiLineItem{
+				LineID:         "1",
+				ProductName:    doc.Invoice.Label,
+				NetPrice:       doc.Net.String(),
+				BilledQuantity: ciiQuantity{UnitCode: unitCodePiece, Value: "1"},
+				Settlement: ciiLineSettlement{
+					Tax:             ciiTax{TypeCode: vatScheme, CategoryCode: category, RateApplicablePercent: percent},
+					LineTotalAmount: doc.Net.String(),
+				},
+			},
+			Agreement: ciiAgreement{
+				Seller: newCIIParty(doc.Seller),
+				Buyer:  newCIIParty(doc.Buyer),
+			},
+			Settlement: ciiSettlement{
+				PaymentReference:    doc.Number,
+				InvoiceCurrencyCode: doc.Currency,
+				Tax: ciiTax{
+					CalculatedAmount:      doc.Tax.String(),
+					TypeCode:              vatScheme,
+					BasisAmount:           doc.Net.String(),
+					CategoryCode:          category,
+					RateApplicablePercent: percent,
+				},
+				DueDate: newCIIDate(doc.Invoice.DueAt),
+				Summation: ciiMonetarySummation{
+					LineTotalAmount:     doc.Net.String(),
+					TaxBasisTotalAmount: doc.Net.String(),
+					TaxTotalAmount:      ciiAmount{CurrencyID: doc.Currency, Value: doc.Tax.String()},
+					GrandTotalAmount:    doc.Invoice.Amount.String(),
+					DuePayableAmount:    doc.Invoice.Amount.String(),
+				},
+			},
+		},
+	}
+	if doc.SellerIBAN != "" {
+		invoice.Transaction.Settlement.PaymentMeans = &ciiPaymentMeans{TypeCode: creditTransferMeansCode, IBAN: doc.SellerIBAN}
+	}
+
+	return writeXML(w, invoice)
+}
@@ -6,64 +6,42 @@ import (
 	"fmt"
 	"net/http"
 
-	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/problem"
 	"github.com/emilien-puget/invoice_microservice/user"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
 type CreateInvoiceHandler struct {
-	invoiceRepository interface {
-		Create(ctx context.Context, invoice Invoice) (int64, error)
+	invoices interface {
+		Create(ctx context.Context, payload CreateInvoicePayload) (Invoice, error)
 	}
-	userRepository interface {
-		GetById(ctx context.Context, id int64) (*user.User, error)
-	}
-	validator *validator.Validate
-}
-
-func NewCreateInvoiceHandler(validate *validator.Validate, repository *Repository, userRepository *user.Repository) *CreateInvoiceHandler {
-	return &CreateInvoiceHandler{validator: validate, invoiceRepository: repository, userRepository: userRepository}
 }
 
-type createInvoicePayload struct {
-	UserID int64   `json:"user_id" validate:"required"`
-	Amount float64 `json:"amount" validate:"required"`
-	Label  string  `json:"label" validate:"required"`
+func NewCreateInvoiceHandler(invoices *Invoices) *CreateInvoiceHandler {
+	return &CreateInvoiceHandler{invoices: invoices}
 }
 
 func (h CreateInvoiceHandler) Handle(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	// Parse the request body into an createInvoicePayload struct
-	payload := new(createInvoicePayload)
+	// Parse the request body, it is validated by the invoices
+	payload := new(CreateInvoicePayload)
 	if err := c.Bind(payload); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
-	}
-
-	if err := h.validator.Struct(payload); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request payload")
 	}
 
-	_, err := h.userRepository.GetById(ctx, payload.UserID)
+	invoice, err := h.invoices.Create(ctx, *payload)
 	if err != nil {
-		if errors.Is(err, user.ErrUserNotFound) {
-			return echo.NewHTTPError(http.StatusBadRequest, "user not found")
+		var validationErrors validator.ValidationErrors
+		switch {
+		case errors.As(err, &validationErrors):
+			return problem.Validation(validationErrors)
+		case errors.Is(err, user.ErrUserNotFound):
+			return problem.New(http.StatusBadRequest, problem.CodeUserNotFound, "user not found")
 		}
-		return fmt.Errorf("userRepository.GetById: %w", err)
-	}
-
-	// Create the invoice in the repository
-	invoice := Invoice{
-		UserID: payload.UserID,
-		Amount: money.NewMoneyFromFloat(payload.Amount),
-		Label:  payload.Label,
-	}
-
-	invoiceID, err := h.invoiceRepository.Create(ctx, invoice)
-	if err != nil {
-		return fmt.Errorf("invoiceRepository.Create: %w", err)
+		return fmt.Errorf("invoices.Create: %w", err)
 	}
 
-	return c.JSON(http.StatusCreated, map[string]int64{"invoice_id": invoiceID})
+	return c.JSON(http.StatusCreated, map[string]int64{"invoice_id": invoice.ID})
 }
@@ -0,0 +1,102 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/emilien-puget/invoice_microservice/user"
+)
+
+// Party is the seller or the buyer of an invoice.
+type Party struct {
+	Name        string
+	VatID       string
+	Street      string
+	PostalCode  string
+	City        string
+	CountryCode string
+}
+
+// Billing holds the invoicing settings that are not stored with each invoice.
+type Billing struct {
+	NumberFormat string
+	Currency     string
+	// TaxRate is expressed in percent, invoice amounts are tax inclusive.
+	TaxRate float64
+	Seller  Party
+	// SellerIBAN is the account the buyer is asked to pay to, it is optional.
+	SellerIBAN string
+	// BuyerCountryCode is applied to every buyer as users do not have a postal address.
+	BuyerCountryCode string
+}
+
+// Document is an invoice along with everything needed to render or export it.
+type Document struct {
+	Invoice    Invoice
+	Number     string
+	Currency   string
+	TaxRate    float64
+	Net        money.Money
+	Tax        money.Money
+	Seller     Party
+	SellerIBAN string
+	Buyer      Party
+}
+
+func (b Billing) Number(invoice *Invoice) string {
+	return fmt.Sprintf(b.NumberFormat, invoice.Number)
+}
+
+// ForTenant returns the billing settings of the tenant of ctx, the settings it does not override are kept.
+func (b Billing) ForTenant(ctx context.Context) Billing {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return b
+	}
+
+	s := t.Settings
+	override(&b.NumberFormat, s.NumberFormat)
+	override(&b.Currency, s.Currency)
+	override(&b.TaxRate, s.TaxRate)
+	override(&b.BuyerCountryCode, s.BuyerCountry)
+	override(&b.Seller.Name, s.SellerName)
+	override(&b.Seller.VatID, s.SellerVatID)
+	override(&b.Seller.Street, s.SellerStreet)
+	override(&b.Seller.PostalCode, s.SellerPostalCode)
+	override(&b.Seller.City, s.SellerCity)
+	override(&b.Seller.CountryCode, s.SellerCountry)
+	override(&b.SellerIBAN, s.SellerIban)
+	return b
+}
+
+func override[T any](value *T, with *T) {
+	if with != nil {
+		*value = *with
+	}
+}
+
+func (b Billing) Document(invoice *Invoice, customer *user.User) Document {
+	net, tax := invoice.TaxBreakdown(b.TaxRate)
+	return Document{
+		Invoice:    *invoice,
+		Number:     b.Number(invoice),
+		Currency:   b.Currency,
+		TaxRate:    b.TaxRate,
+		Net:        net,
+		Tax:        tax,
+		Seller:     b.Seller,
+		SellerIBAN: b.SellerIBAN,
+		Buyer: Party{
+			Name:        customer.FirstName + " " + customer.LastName,
+			CountryCode: b.BuyerCountryCode,
+		},
+	}
+}
+
+// TaxBreakdown splits the tax inclusive amount of the invoice, rate is expressed in percent.
+func (i Invoice) TaxBreakdown(rate float64) (net, tax money.Money) {
+	net = money.NewMoneyFromFloat(i.Amount.ToFloat() / (1 + rate/100))
+	return net, i.Amount - net
+}
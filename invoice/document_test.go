@@ -0,0 +1,77 @@
+package invoice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDocument returns the document used by the golden files tests.
+func testDocument() Document {
+	billing := Billing{
+		NumberFormat: "INV-%06d",
+		Currency:     "EUR",
+		TaxRate:      20,
+		Seller: Party{
+			Name:        "Jump",
+			VatID:       "FR00123456789",
+			Street:      "1 rue de la Paix",
+			PostalCode:  "75002",
+			City:        "Paris",
+			CountryCode: "FR",
+		},
+		SellerIBAN:       "FR7630006000011234567890189",
+		BuyerCountryCode: "FR",
+	}
+
+	invoice := &Invoice{
+		ID:        42,
+		Number:    42,
+		UserID:    1,
+		Status:    "pending",
+		Label:     "Consulting services",
+		Amount:    12000,
+		CreatedAt: time.Date(2023, 7, 1, 10, 0, 0, 0, time.UTC),
+		DueAt:     time.Date(2023, 7, 31, 10, 0, 0, 0, time.UTC),
+	}
+	customer := &user.User{ID: 1, FirstName: "Jérôme", LastName: "Doe"}
+
+	return billing.Document(invoice, customer)
+}
+
+func TestBilling_Document(t *testing.T) {
+	doc := testDocument()
+
+	assert.Equal(t, "INV-000042", doc.Number)
+	assert.Equal(t, "Jérôme Doe", doc.Buyer.Name)
+	assert.Equal(t, "FR", doc.Buyer.CountryCode)
+	assert.Equal(t, int64(10000), int64(doc.Net))
+	assert.Equal(t, int64(2000), int64(doc.Tax))
+}
+
+func TestInvoice_TaxBreakdown(t *testing.T) {
+	net, tax := Invoice{Amount: 999}.TaxBreakdown(20)
+	assert.Equal(t, int64(833), int64(net))
+	assert.Equal(t, int64(166), int64(tax))
+}
+
+func TestBilling_ForTenant(t *testing.T) {
+	billing := Billing{NumberFormat: "INV-%06d", Currency: "EUR", TaxRate: 20, Seller: Party{Name: "Jump", CountryCode: "FR"}}
+
+	assert.Equal(t, billing, billing.ForTenant(context.Background()))
+
+	format, currency, rate, seller := "ACME-%04d", "USD", 0.0, "Acme"
+	ctx := tenant.NewContext(context.Background(), &tenant.Tenant{ID: "acme", Settings: tenant.Settings{
+		NumberFormat: &format,
+		Currency:     &currency,
+		TaxRate:      &rate,
+		SellerName:   &seller,
+	}})
+
+	assert.Equal(t, Billing{NumberFormat: "ACME-%04d", Currency: "USD", TaxRate: 0, Seller: Party{Name: "Acme", CountryCode: "FR"}}, billing.ForTenant(ctx))
+	assert.Equal(t, "ACME-0007", billing.ForTenant(ctx).Number(&Invoice{ID: 120, Number: 7}))
+}
@@ -0,0 +1,244 @@
+package invoice
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertSchemaValid validates data against the official XSD of the syntax with xmllint. The schemas are committed in
+// testdata/schemas, testdata/schemas/fetch.sh fetches them again.
+func assertSchemaValid(t *testing.T, s syntax, data []byte) {
+	t.Helper()
+
+	xmllint, err := exec.LookPath("xmllint")
+	require.NoError(t, err, "xmllint validates the documents, install libxml2-utils")
+	_, err = os.Stat(s.schema)
+	require.NoError(t, err, "the schemas are committed in testdata/schemas, run testdata/schemas/fetch.sh")
+
+	// --nonet keeps xmllint from fetching anything, the schemas import each other by relative paths.
+	cmd := exec.Command(xmllint, "--noout", "--nonet", "--schema", s.schema, "-")
+	cmd.Stdin = bytes.NewReader(data)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "the document is not valid against %s:\n%s", s.schema, output)
+}
+
+// xmlNode is a minimal DOM, enough to evaluate the business rules below, the structure is checked by the XSDs.
+type xmlNode struct {
+	namespace string
+	name      string
+	text      string
+	children  []*xmlNode
+}
+
+func parseXML(t *testing.T, data []byte) *xmlNode {
+	t.Helper()
+
+	root := &xmlNode{}
+	stack := []*xmlNode{root}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err, "document is not well-formed")
+
+		current := stack[len(stack)-1]
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			node := &xmlNode{namespace: tok.Name.Space, name: tok.Name.Local}
+			current.children = append(current.children, node)
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			current.text += strings.TrimSpace(string(tok))
+		}
+	}
+	require.Len(t, root.children, 1)
+	return root.children[0]
+}
+
+// all returns the nodes matching a slash separated path of local names.
+func (n *xmlNode) all(path string) []*xmlNode {
+	nodes := []*xmlNode{n}
+	for _, name := range strings.Split(path, "/") {
+		var next []*xmlNode
+		for _, node := range nodes {
+			for _, child := range node.children {
+				if child.name == name {
+					next = append(next, child)
+				}
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+func (n *xmlNode) value(path string) string {
+	nodes := n.all(path)
+	if len(nodes) == 0 {
+		return ""
+	}
+	return nodes[0].text
+}
+
+// syntax maps the EN 16931 business terms used by the rules to their location in a syntax.
+type syntax struct {
+	namespace string
+	// schema is the XSD of the root element of the syntax.
+	schema string
+	terms  map[string]string
+}
+
+var ublSyntax = syntax{
+	namespace: "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2",
+	schema:    "testdata/schemas/ubl-2.1/maindoc/UBL-Invoice-2.1.xsd",
+	terms: map[string]string{
+		"BT-1":   "ID",
+		"BT-2":   "IssueDate",
+		"BT-3":   "InvoiceTypeCode",
+		"BT-5":   "DocumentCurrencyCode",
+		"BT-9":   "DueDate",
+		"BT-24":  "CustomizationID",
+		"BT-27":  "AccountingSupplierParty/Party/PartyLegalEntity/RegistrationName",
+		"BT-40":  "AccountingSupplierParty/Party/PostalAddress/Country/IdentificationCode",
+		"BT-44":  "AccountingCustomerParty/Party/PartyLegalEntity/RegistrationName",
+		"BT-55":  "AccountingCustomerParty/Party/PostalAddress/Country/IdentificationCode",
+		"BT-106": "LegalMonetaryTotal/LineExtensionAmount",
+		"BT-109": "LegalMonetaryTotal/TaxExclusiveAmount",
+		"BT-110": "TaxTotal/TaxAmount",
+		"BT-112": "LegalMonetaryTotal/TaxInclusiveAmount",
+		"BT-115": "LegalMonetaryTotal/PayableAmount",
+		"BT-116": "TaxTotal/TaxSubtotal/TaxableAmount",
+		"BT-117": "TaxTotal/TaxSubtotal/TaxAmount",
+		"BT-118": "TaxTotal/TaxSubtotal/TaxCategory/ID",
+		"BT-119": "TaxTotal/TaxSubtotal/TaxCategory/Percent",
+		"BT-131": "InvoiceLine/LineExtensionAmount",
+	},
+}
+
+var ciiSyntax = syntax{
+	namespace: "urn:un:unece:uncefact:data:standard:CrossIndustryInvoice:100",
+	schema:    "testdata/schemas/cii-d16b/CrossIndustryInvoice_100pD16B.xsd",
+	terms: map[string]string{
+		"BT-1":   "ExchangedDocument/ID",
+		"BT-2":   "ExchangedDocument/IssueDateTime/DateTimeString",
+		"BT-3":   "ExchangedDocument/TypeCode",
+		"BT-5":   "SupplyChainTradeTransaction/ApplicableHeaderTradeSettlement/InvoiceCurrencyCode",
+		"BT-9":   "SupplyChainTradeTransaction/ApplicableHeaderTradeSettlement/SpecifiedTradePaymentTerms/DueDateDateTime/DateTimeString",
+		"BT-24":  "ExchangedDocumentContext/GuidelineSpecifiedDocumentContextParameter/ID",
+		"BT-27":  "SupplyChainTradeTransaction/ApplicableHeaderTradeAgreement/SellerTradeParty/Name",
+		"BT-40":  "SupplyChainTradeTransaction/ApplicableHeaderTradeAgreement/SellerTradeParty/PostalTradeAddress/CountryID",
+		"BT-44":  "SupplyChainTradeTransaction/ApplicableHeaderTradeAgreement/BuyerTradeParty/Name",
+		"BT-55":  "SupplyChainTradeTransaction/ApplicableHeaderTradeAgreement/BuyerTradeParty/PostalTradeAddress/CountryID",
+		"BT-106": "SupplyChainTradeTransaction/ApplicableHeaderTradeSettlement/SpecifiedTradeSettlementHeaderMonetarySummation/LineTotalAmount",
+		"BT-109": "SupplyChainTradeTransaction/ApplicableHeaderTradeSettlement/SpecifiedTradeSettlementHeaderMonetarySummation/TaxBasisTotalAmount",
+		"BT-110": "SupplyChainTradeTransaction/ApplicableHeaderTradeSettlement/SpecifiedTradeSettlementHeaderMonetarySummation/TaxTotalAmount",
+		"BT-112": "SupplyChainTradeTransaction/ApplicableHeaderTradeSettlement/SpecifiedTradeSettlementHeaderMonetarySummation/GrandTotalAmount",
+		"BT-115": "SupplyChainTradeTransaction/ApplicableHeaderTradeSettlement/SpecifiedTradeSettlementHeaderMonetarySummation/DuePayableAmount",
+		"BT-116": "SupplyChainTradeTransaction/ApplicableHeaderTradeSettlement/ApplicableTradeTax/BasisAmount",
+		"BT-117": "SupplyChainTradeTransaction/ApplicableHeaderTradeSettlement/ApplicableTradeTax/CalculatedAmount",
+		"BT-118": "SupplyChainTradeTransaction/ApplicableHeaderTradeSettlement/ApplicableTradeTax/CategoryCode",
+		"BT-119": "SupplyChainTradeTransaction/ApplicableHeaderTradeSettlement/ApplicableTradeTax/RateApplicablePercent",
+		"BT-131": "SupplyChainTradeTransaction/IncludedSupplyChainTradeLineItem/SpecifiedLineTradeSettlement/SpecifiedTradeSettlementLineMonetarySummation/LineTotalAmount",
+	},
+}
+
+// assertEN16931 validates data against the XSD of the syntax, then checks the subset of the EN 16931 schematron
+// business rules that applies to our invoices.
+func assertEN16931(t *testing.T, s syntax, data []byte) {
+	t.Helper()
+	assertSchemaValid(t, s, data)
+
+	root := parseXML(t, data)
+	require.Equal(t, s.namespace, root.namespace)
+
+	value := func(term string) string {
+		return root.value(s.terms[term])
+	}
+	amount := func(term string) money.Money {
+		v, err := strconv.ParseFloat(value(term), 64)
+		require.NoError(t, err, term)
+		return money.NewMoneyFromFloat(v)
+	}
+
+	mandatory := map[string]string{
+		"BR-01": "BT-24", "BR-02": "BT-1", "BR-03": "BT-2", "BR-04": "BT-3", "BR-05": "BT-5",
+		"BR-06": "BT-27", "BR-07": "BT-44", "BR-09": "BT-40", "BR-11": "BT-55",
+		"BR-12": "BT-106", "BR-13": "BT-109", "BR-14": "BT-112", "BR-15": "BT-115",
+	}
+	for rule, term := range mandatory {
+		assert.NotEmpty(t, value(term), "%s: %s is mandatory", rule, term)
+	}
+	assert.Equal(t, en16931, value("BT-24"), "BR-01")
+
+	var lines money.Money
+	lineNodes := root.all(s.terms["BT-131"])
+	assert.NotEmpty(t, lineNodes, "BR-16: an invoice shall have at least one line")
+	for _, line := range lineNodes {
+		v, err := strconv.ParseFloat(line.text, 64)
+		require.NoError(t, err)
+		lines += money.NewMoneyFromFloat(v)
+	}
+
+	assert.Equal(t, lines, amount("BT-106"), "BR-CO-10: sum of invoice line net amounts")
+	assert.Equal(t, amount("BT-106"), amount("BT-109"), "BR-CO-13: total without VAT")
+	assert.Equal(t, amount("BT-109")+amount("BT-110"), amount("BT-112"), "BR-CO-15: total with VAT")
+	assert.Equal(t, amount("BT-112"), amount("BT-115"), "BR-CO-16: amount due for payment")
+
+	assert.Equal(t, "S", value("BT-118"))
+	assert.Equal(t, lines, amount("BT-116"), "BR-S-08: VAT category taxable amount")
+	rate, err := strconv.ParseFloat(value("BT-119"), 64)
+	require.NoError(t, err)
+	expectedTax := money.NewMoneyFromFloat(amount("BT-116").ToFloat() * rate / 100)
+	// The schematron accepts a difference of one currency unit.
+	assert.InDelta(t, int64(expectedTax), int64(amount("BT-117")), 100, "BR-S-09: VAT category tax amount")
+}
+
+func TestWriteUBL(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteUBL(&buf, testDocument()))
+
+	assertEN16931(t, ublSyntax, buf.Bytes())
+	assertGolden(t, "invoice.ubl.xml", buf.Bytes())
+}
+
+func TestWriteCII(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteCII(&buf, testDocument()))
+
+	assertEN16931(t, ciiSyntax, buf.Bytes())
+	assertGolden(t, "invoice.cii.xml", buf.Bytes())
+}
+
+func TestWrite_Rounding(t *testing.T) {
+	doc := testDocument()
+	doc.Invoice.Amount = 999
+	doc.Net, doc.Tax = doc.Invoice.TaxBreakdown(doc.TaxRate)
+
+	for name, s := range map[string]struct {
+		syntax syntax
+		write  func(w io.Writer, doc Document) error
+	}{
+		"ubl": {ublSyntax, WriteUBL},
+		"cii": {ciiSyntax, WriteCII},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, s.write(&buf, doc))
+			assertEN16931(t, s.syntax, buf.Bytes())
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/logging"
+	"github.com/emilien-puget/invoice_microservice/outbox"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/emilien-puget/invoice_microservice/webhook"
+)
+
+// Event is the data of the invoice webhook events.
+type Event struct {
+	InvoiceID   int64     `json:"invoice_id"`
+	Number      int64     `json:"number"`
+	UserID      int64     `json:"user_id"`
+	Status      string    `json:"status"`
+	Label       string    `json:"label"`
+	AmountCents int64     `json:"amount_cents"`
+	DueAt       time.Time `json:"due_at"`
+	// Reference is the reference of the payment, for invoice.paid only.
+	Reference string `json:"reference,omitempty"`
+}
+
+func newEvent(invoice *Invoice) Event {
+	return Event{
+		InvoiceID:   invoice.ID,
+		Number:      invoice.Number,
+		UserID:      invoice.UserID,
+		Status:      invoice.Status,
+		Label:       invoice.Label,
+		AmountCents: int64(invoice.Amount),
+		DueAt:       invoice.DueAt,
+	}
+}
+
+// AggregateType identifies the invoices in the outbox, their events are published in order.
+const AggregateType = "invoice"
+
+type eventAppender interface {
+	Append(ctx context.Context, aggregateType, aggregateID, eventType string, data any) error
+}
+
+func appendEvent(ctx context.Context, events eventAppender, eventType string, event Event) error {
+	if err := events.Append(ctx, AggregateType, strconv.FormatInt(event.InvoiceID, 10), eventType, event); err != nil {
+		return fmt.Errorf("outbox.Append: %w", err)
+	}
+	return nil
+}
+
+// OverdueNotifier emits invoice.overdue once for every pending invoice past its due date.
+type OverdueNotifier struct {
+	invoiceRepository interface {
+		ClaimOverdue(ctx context.Context, at time.Time) ([]OverdueInvoice, error)
+	}
+	outbox     eventAppender
+	transactor interface {
+		WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	}
+	interval time.Duration
+}
+
+func NewOverdueNotifier(invoiceRepository *Repository, outboxRepository *outbox.Repository, transactor *storage.Transactor, interval time.Duration) *OverdueNotifier {
+	return &OverdueNotifier{invoiceRepository: invoiceRepository, outbox: outboxRepository, transactor: transactor, interval: interval}
+}
+
+// Run looks for overdue invoices every interval until ctx is done, a lookup in flight then is finished before
+// returning.
+func (n *OverdueNotifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := n.notify(context.WithoutCancel(ctx), now); err != nil {
+				logging.FromContext(ctx).Error("overdue notification failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// notify claims the invoices and writes their events in the same transaction, so that an invoice claimed always
+// has its event.
+func (n *OverdueNotifier) notify(ctx context.Context, now time.Time) error {
+	return n.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		invoices, err := n.invoiceRepository.ClaimOverdue(ctx, now)
+		if err != nil {
+			return fmt.Errorf("invoiceRepository.ClaimOverdue: %w", err)
+		}
+
+		for i := range invoices {
+			tenantCtx := tenant.NewContext(ctx, &tenant.Tenant{ID: invoices[i].TenantID})
+			if err := appendEvent(tenantCtx, n.outbox, webhook.EventInvoiceOverdue, newEvent(&invoices[i].Invoice)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
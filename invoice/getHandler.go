@@ -0,0 +1,94 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/labstack/echo/v4"
+)
+
+type GetHandler struct {
+	invoiceRepository interface {
+		GetByID(ctx context.Context, id int64) (*Invoice, error)
+	}
+	userRepository interface {
+		GetById(ctx context.Context, id int64) (*user.User, error)
+	}
+	billing Billing
+}
+
+func NewGetHandler(invoiceRepository *Repository, userRepository *user.Repository, billing Billing) *GetHandler {
+	return &GetHandler{invoiceRepository: invoiceRepository, userRepository: userRepository, billing: billing}
+}
+
+type GetInvoiceHandlerResponse struct {
+	InvoiceID int64     `json:"invoice_id"`
+	Number    string    `json:"number"`
+	UserID    int64     `json:"user_id"`
+	Status    string    `json:"status"`
+	Label     string    `json:"label"`
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+	DueAt     time.Time `json:"due_at"`
+}
+
+// exportFormats maps the supported values of the format query parameter to their writer.
+var exportFormats = map[string]func(w io.Writer, doc Document) error{
+	"ubl": WriteUBL,
+	"cii": WriteCII,
+}
+
+func (h GetHandler) Handle(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid invoice id")
+	}
+
+	format := c.QueryParam("format")
+	write, ok := exportFormats[format]
+	if format != "" && !ok {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid format")
+	}
+
+	invoice, err := h.invoiceRepository.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrInvoiceNotFound) {
+			return problem.New(http.StatusNotFound, problem.CodeInvoiceNotFound, "invoice not found")
+		}
+		return fmt.Errorf("invoiceRepository.GetByID: %w", err)
+	}
+
+	if format == "" {
+		return c.JSON(http.StatusOK, GetInvoiceHandlerResponse{
+			InvoiceID: invoice.ID,
+			Number:    h.billing.ForTenant(ctx).Number(invoice),
+			UserID:    invoice.UserID,
+			Status:    invoice.Status,
+			Label:     invoice.Label,
+			Amount:    invoice.Amount.ToFloat(),
+			CreatedAt: invoice.CreatedAt,
+			DueAt:     invoice.DueAt,
+		})
+	}
+
+	customer, err := h.userRepository.GetById(ctx, invoice.UserID)
+	if err != nil {
+		return fmt.Errorf("userRepository.GetById: %w", err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationXMLCharsetUTF8)
+	c.Response().WriteHeader(http.StatusOK)
+	if err := write(c.Response(), h.billing.ForTenant(ctx).Document(invoice, customer)); err != nil {
+		return fmt.Errorf("write %s: %w", format, err)
+	}
+	return nil
+}
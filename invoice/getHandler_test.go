@@ -0,0 +1,88 @@
+package invoice
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/emilien-puget/invoice_microservice/tenant/tenanttest"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type principalAuthenticator auth.Principal
+
+func (p principalAuthenticator) Authenticate(*http.Request) (*auth.Principal, error) {
+	principal := auth.Principal(p)
+	return &principal, nil
+}
+
+func TestGetHandler_CrossTenant(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	handler := NewGetHandler(NewInvoiceRepository(db), user.NewUserRepository(db), Billing{NumberFormat: "INV-%06d"})
+	query := "SELECT id, number, user_id, status, label, amount, created_at, due_at FROM jump.public.invoices WHERE id = $1 AND tenant_id = $2 AND ($3 = 0 OR user_id = $3)"
+	columns := []string{"id", "number", "user_id", "status", "label", "amount", "created_at", "due_at"}
+	createdAt := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	for name, test := range map[string]struct {
+		principal auth.Principal
+		tenant    string
+		scope     int64
+		rows      *sqlmock.Rows
+		status    int
+	}{
+		"owner": {
+			principal: auth.Principal{Role: auth.RoleCustomer, UserID: 1, TenantID: "acme"},
+			tenant:    "acme",
+			scope:     1,
+			rows:      sqlmock.NewRows(columns).AddRow(10, 3, 1, "pending", "Consulting", 1000, createdAt, createdAt),
+			status:    http.StatusOK,
+		},
+		"other customer": {
+			principal: auth.Principal{Role: auth.RoleCustomer, UserID: 2, TenantID: "acme"},
+			tenant:    "acme",
+			scope:     2,
+			rows:      sqlmock.NewRows(columns),
+			status:    http.StatusNotFound,
+		},
+		"other tenant": {
+			principal: auth.Principal{Role: auth.RoleFinance, TenantID: "globex"},
+			tenant:    "globex",
+			scope:     0,
+			rows:      sqlmock.NewRows(columns),
+			status:    http.StatusNotFound,
+		},
+		"finance": {
+			principal: auth.Principal{Role: auth.RoleFinance, TenantID: "acme"},
+			tenant:    "acme",
+			scope:     0,
+			rows:      sqlmock.NewRows(columns).AddRow(10, 3, 1, "pending", "Consulting", 1000, createdAt, createdAt),
+			status:    http.StatusOK,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// The invoice 10 belongs to user 1 of acme, the query is scoped by the tenant and the principal
+			mock.ExpectQuery(query).WithArgs(10, test.tenant, test.scope).WillReturnRows(test.rows)
+
+			e := echo.New()
+			e.HTTPErrorHandler = problem.ErrorHandler
+			e.GET("/invoices/:id", handler.Handle,
+				auth.Middleware(principalAuthenticator(test.principal)), tenanttest.Middleware, auth.RequirePermission(auth.ScopeInvoicesRead))
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/invoices/10", nil))
+
+			assert.Equal(t, test.status, rec.Code)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package invoice
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/labstack/echo/v4"
+)
+
+type GetPDFHandler struct {
+	invoiceRepository interface {
+		GetByID(ctx context.Context, id int64) (*Invoice, error)
+	}
+	userRepository interface {
+		GetById(ctx context.Context, id int64) (*user.User, error)
+	}
+	billing  Billing
+	renderer *PDFRenderer
+}
+
+func NewGetPDFHandler(invoiceRepository *Repository, userRepository *user.Repository, billing Billing, renderer *PDFRenderer) *GetPDFHandler {
+	return &GetPDFHandler{invoiceRepository: invoiceRepository, userRepository: userRepository, billing: billing, renderer: renderer}
+}
+
+func (h GetPDFHandler) Handle(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid invoice id")
+	}
+
+	invoice, err := h.invoiceRepository.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrInvoiceNotFound) {
+			return problem.New(http.StatusNotFound, problem.CodeInvoiceNotFound, "invoice not found")
+		}
+		return fmt.Errorf("invoiceRepository.GetByID: %w", err)
+	}
+
+	customer, err := h.userRepository.GetById(ctx, invoice.UserID)
+	if err != nil {
+		return fmt.Errorf("userRepository.GetById: %w", err)
+	}
+
+	doc := h.billing.ForTenant(ctx).Document(invoice, customer)
+	var buf bytes.Buffer
+	if err := h.renderer.Render(&buf, doc); err != nil {
+		return fmt.Errorf("renderer.Render: %w", err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", doc.Number+".pdf"))
+	return c.Blob(http.StatusOK, "application/pdf", buf.Bytes())
+}
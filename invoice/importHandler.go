@@ -0,0 +1,298 @@
+package invoice
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/logging"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	importModeAtomic     = "atomic"
+	importModeBestEffort = "best_effort"
+	// maxImportRows bounds the memory used by a single import.
+	maxImportRows = 10000
+)
+
+type ImportHandler struct {
+	invoices interface {
+		Create(ctx context.Context, payload CreateInvoicePayload) (Invoice, error)
+		CreateMany(ctx context.Context, payloads []CreateInvoicePayload) ([]Invoice, error)
+	}
+	userRepository interface {
+		GetById(ctx context.Context, id int64) (*user.User, error)
+	}
+	validator *validator.Validate
+}
+
+func NewImportHandler(validate *validator.Validate, invoices *Invoices, userRepository *user.Repository) *ImportHandler {
+	return &ImportHandler{validator: validate, invoices: invoices, userRepository: userRepository}
+}
+
+type ImportRowReport struct {
+	Row       int      `json:"row"`
+	InvoiceID int64    `json:"invoice_id,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+type ImportHandlerResponse struct {
+	Mode     string            `json:"mode"`
+	Imported int               `json:"imported"`
+	Failed   int               `json:"failed"`
+	Rows     []ImportRowReport `json:"rows"`
+}
+
+// importRow is a parsed row, err is set when the row could not be parsed into a payload.
+type importRow struct {
+	row     int
+	payload CreateInvoicePayload
+	err     error
+}
+
+var (
+	ErrTooManyRows      = fmt.Errorf("more than %d rows", maxImportRows)
+	ErrMissingColumn    = errors.New("missing column")
+	ErrInvalidDueAt     = errors.New("invalid due_at, expected RFC 3339 or YYYY-MM-DD")
+	errUnsupportedMedia = errors.New("unsupported media type")
+)
+
+func (h ImportHandler) Handle(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	mode := c.QueryParam("mode")
+	if mode == "" {
+		mode = importModeAtomic
+	}
+	if mode != importModeAtomic && mode != importModeBestEffort {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid mode")
+	}
+
+	rows, err := parseImport(c.Request().Header.Get(echo.HeaderContentType), c.Request().Body)
+	if err != nil {
+		if errors.Is(err, errUnsupportedMedia) {
+			return problem.New(http.StatusUnsupportedMediaType, problem.CodeUnsupportedMediaType, "Expected text/csv or application/x-ndjson")
+		}
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, err.Error())
+	}
+
+	response := ImportHandlerResponse{Mode: mode, Rows: make([]ImportRowReport, len(rows))}
+	users := map[int64]error{}
+	for i, row := range rows {
+		response.Rows[i] = ImportRowReport{Row: row.row, Errors: h.check(ctx, row, users)}
+		if len(response.Rows[i].Errors) > 0 {
+			response.Failed++
+		}
+	}
+
+	if mode == importModeAtomic {
+		if response.Failed > 0 {
+			return c.JSON(http.StatusUnprocessableEntity, response)
+		}
+
+		payloads := make([]CreateInvoicePayload, len(rows))
+		for i, row := range rows {
+			payloads[i] = row.payload
+		}
+		invoices, err := h.invoices.CreateMany(ctx, payloads)
+		if err != nil {
+			return fmt.Errorf("invoices.CreateMany: %w", err)
+		}
+		for i := range invoices {
+			response.Rows[i].InvoiceID = invoices[i].ID
+		}
+		response.Imported = len(invoices)
+		return c.JSON(http.StatusCreated, response)
+	}
+
+	for i, row := range rows {
+		if len(response.Rows[i].Errors) > 0 {
+			continue
+		}
+		invoice, err := h.invoices.Create(ctx, row.payload)
+		if err != nil {
+			logging.FromContext(ctx).Error("import row failed", slog.Int("row", row.row), slog.Any("error", err))
+			response.Rows[i].Errors = []string{"invoice could not be created"}
+			response.Failed++
+			continue
+		}
+		response.Rows[i].InvoiceID = invoice.ID
+		response.Imported++
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// check returns the reasons why row cannot be imported, users caches the lookups already made.
+func (h ImportHandler) check(ctx context.Context, row importRow, users map[int64]error) []string {
+	if row.err != nil {
+		return []string{row.err.Error()}
+	}
+
+	if err := h.validator.Struct(row.payload); err != nil {
+		var validationErrors validator.ValidationErrors
+		if !errors.As(err, &validationErrors) {
+			return []string{err.Error()}
+		}
+		messages := make([]string, len(validationErrors))
+		for i, fieldError := range validationErrors {
+			messages[i] = fieldError.Error()
+		}
+		return messages
+	}
+
+	err, ok := users[row.payload.UserID]
+	if !ok {
+		_, err = h.userRepository.GetById(ctx, row.payload.UserID)
+		users[row.payload.UserID] = err
+	}
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return []string{"user not found"}
+		}
+		return []string{"user could not be checked"}
+	}
+
+	return nil
+}
+
+func parseImport(contentType string, body io.Reader) ([]importRow, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errUnsupportedMedia
+	}
+
+	switch mediaType {
+	case "text/csv":
+		return parseCSV(body)
+	case "application/x-ndjson", "application/jsonl", "application/x-jsonlines":
+		return parseJSONL(body)
+	default:
+		return nil, errUnsupportedMedia
+	}
+}
+
+// parseCSV reads rows with a header naming the user_id, amount, label and optional due_at columns.
+func parseCSV(body io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	// Trailing optional columns can be left out.
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"user_id", "amount", "label"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMissingColumn, name)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []importRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if len(rows) == maxImportRows {
+			return nil, ErrTooManyRows
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, importRow{row: parseErr.StartLine, err: err})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read row: %w", err)
+		}
+
+		row := importRow{}
+		row.row, _ = reader.FieldPos(0)
+		row.payload, row.err = csvPayload(field(record, "user_id"), field(record, "amount"), field(record, "label"), field(record, "due_at"))
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func csvPayload(userID, amount, label, dueAt string) (CreateInvoicePayload, error) {
+	payload := CreateInvoicePayload{Label: label}
+
+	var err error
+	if userID != "" {
+		payload.UserID, err = strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			return payload, fmt.Errorf("invalid user_id: %w", err)
+		}
+	}
+	if amount != "" {
+		payload.Amount, err = strconv.ParseFloat(amount, 64)
+		if err != nil {
+			return payload, fmt.Errorf("invalid amount: %w", err)
+		}
+	}
+	if dueAt != "" {
+		t, err := time.Parse(time.RFC3339, dueAt)
+		if err != nil {
+			t, err = time.Parse(time.DateOnly, dueAt)
+		}
+		if err != nil {
+			return payload, ErrInvalidDueAt
+		}
+		payload.DueAt = &t
+	}
+
+	return payload, nil
+}
+
+// parseJSONL reads one CreateInvoicePayload per line, blank lines are skipped.
+func parseJSONL(body io.Reader) ([]importRow, error) {
+	var rows []importRow
+	scanner := bufio.NewScanner(body)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		if len(rows) == maxImportRows {
+			return nil, ErrTooManyRows
+		}
+
+		row := importRow{row: line}
+		if err := json.Unmarshal(scanner.Bytes(), &row.payload); err != nil {
+			row.err = fmt.Errorf("invalid JSON: %w", err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+
+	return rows, nil
+}
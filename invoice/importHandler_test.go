@@ -0,0 +1,308 @@
+package invoice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeInvoices creates the invoices in memory, from the id 100, except the ones labelled failing.
+type fakeInvoices struct {
+	created []CreateInvoicePayload
+}
+
+func (f *fakeInvoices) Create(_ context.Context, payload CreateInvoicePayload) (Invoice, error) {
+	if payload.Label == "failing" {
+		return Invoice{}, errConnectionLost
+	}
+	f.created = append(f.created, payload)
+	return Invoice{ID: int64(99 + len(f.created))}, nil
+}
+
+func (f *fakeInvoices) CreateMany(ctx context.Context, payloads []CreateInvoicePayload) ([]Invoice, error) {
+	invoices := make([]Invoice, len(payloads))
+	for i, payload := range payloads {
+		invoice, err := f.Create(ctx, payload)
+		if err != nil {
+			return nil, err
+		}
+		invoices[i] = invoice
+	}
+	return invoices, nil
+}
+
+// fakeUserRepository knows the users 1 and 2.
+type fakeUserRepository struct{}
+
+func (fakeUserRepository) GetById(_ context.Context, id int64) (*user.User, error) {
+	if id != 1 && id != 2 {
+		return nil, user.ErrUserNotFound
+	}
+	return &user.User{ID: id}, nil
+}
+
+func serveImport(t *testing.T, invoices *fakeInvoices, mode, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	handler := ImportHandler{invoices: invoices, userRepository: fakeUserRepository{}, validator: validator.New()}
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.ErrorHandler
+	e.POST("/invoices/import", handler.Handle)
+	target := "/invoices/import"
+	if mode != "" {
+		target += "?mode=" + mode
+	}
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestImportHandler_Import(t *testing.T) {
+	dueAt := time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)
+
+	for name, test := range map[string]struct {
+		contentType string
+		body        string
+	}{
+		"csv": {
+			contentType: "text/csv",
+			body:        "user_id,amount,label,due_at\n1,10.5,Consulting,2023-07-31\n2,20,\"Support, July\",2023-07-31T00:00:00Z\n",
+		},
+		"csv without the optional column": {
+			contentType: "text/csv; charset=utf-8",
+			body:        "Label, User_ID, Amount\nConsulting,1,10.5\n\"Support, July\",2,20\n",
+		},
+		"jsonl": {
+			contentType: "application/x-ndjson",
+			body:        `{"user_id":1,"amount":10.5,"label":"Consulting","due_at":"2023-07-31T00:00:00Z"}` + "\n\n" + `{"user_id":2,"amount":20,"label":"Support, July"}` + "\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			invoices := &fakeInvoices{}
+			rec := serveImport(t, invoices, "", test.contentType, test.body)
+
+			require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+			var response ImportHandlerResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			assert.Equal(t, importModeAtomic, response.Mode, "atomic by default")
+			assert.Equal(t, 2, response.Imported)
+			assert.Zero(t, response.Failed)
+			require.Len(t, response.Rows, 2)
+			assert.Equal(t, []int64{100, 101}, []int64{response.Rows[0].InvoiceID, response.Rows[1].InvoiceID})
+
+			require.Len(t, invoices.created, 2)
+			assert.Equal(t, int64(1), invoices.created[0].UserID)
+			assert.Equal(t, 10.5, invoices.created[0].Amount)
+			assert.Equal(t, "Consulting", invoices.created[0].Label)
+			assert.Equal(t, "Support, July", invoices.created[1].Label)
+			if invoices.created[0].DueAt != nil {
+				assert.True(t, dueAt.Equal(*invoices.created[0].DueAt))
+			}
+		})
+	}
+}
+
+func TestImportHandler_RowErrors(t *testing.T) {
+	for name, test := range map[string]struct {
+		contentType string
+		body        string
+		// rows are the lines of the rows and the error expected on each, empty for a valid row
+		rows map[int]string
+	}{
+		"csv": {
+			contentType: "text/csv",
+			body: "user_id,amount,label,due_at\n" +
+				"1,10,Valid,\n" +
+				"x,10,Bad user id,\n" +
+				"1,ten,Bad amount,\n" +
+				"1,10,Bad due date,31/07/2023\n" +
+				"1,10,\n" +
+				"3,10,Unknown user,\n" +
+				"1,10,\"unterminated\n",
+			rows: map[int]string{
+				2: "",
+				3: "invalid user_id",
+				4: "invalid amount",
+				5: ErrInvalidDueAt.Error(),
+				6: "'Label' failed on the 'required' tag",
+				7: "user not found",
+				8: "extraneous or missing \" in quoted-field",
+			},
+		},
+		"jsonl": {
+			contentType: "application/x-ndjson",
+			body: `{"user_id":1,"amount":10,"label":"Valid"}` + "\n" +
+				`{"user_id":1,"amount":10,` + "\n" +
+				`{"user_id":1,"label":"No amount"}` + "\n" +
+				`{"user_id":3,"amount":10,"label":"Unknown user"}` + "\n",
+			rows: map[int]string{
+				1: "",
+				2: "invalid JSON",
+				3: "'Amount' failed on the 'required' tag",
+				4: "user not found",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Run("atomic", func(t *testing.T) {
+				invoices := &fakeInvoices{}
+				rec := serveImport(t, invoices, importModeAtomic, test.contentType, test.body)
+
+				require.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+				var response ImportHandlerResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assertRowErrors(t, test.rows, response)
+				assert.Zero(t, response.Imported)
+				assert.Empty(t, invoices.created, "nothing is written when a row fails")
+			})
+
+			t.Run("best effort", func(t *testing.T) {
+				invoices := &fakeInvoices{}
+				rec := serveImport(t, invoices, importModeBestEffort, test.contentType, test.body)
+
+				require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+				var response ImportHandlerResponse
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assertRowErrors(t, test.rows, response)
+				assert.Equal(t, 1, response.Imported)
+				require.Len(t, invoices.created, 1, "the valid rows are written")
+				assert.Equal(t, "Valid", invoices.created[0].Label)
+			})
+		})
+	}
+}
+
+func assertRowErrors(t *testing.T, rows map[int]string, response ImportHandlerResponse) {
+	t.Helper()
+	require.Len(t, response.Rows, len(rows))
+	failed := 0
+	for _, report := range response.Rows {
+		want, ok := rows[report.Row]
+		if !assert.True(t, ok, "unexpected row %d", report.Row) {
+			continue
+		}
+		if want == "" {
+			assert.Empty(t, report.Errors, "row %d", report.Row)
+			continue
+		}
+		failed++
+		assert.Zero(t, report.InvoiceID, "row %d", report.Row)
+		if assert.Len(t, report.Errors, 1, "row %d", report.Row) {
+			assert.Contains(t, report.Errors[0], want, "row %d", report.Row)
+		}
+	}
+	assert.Equal(t, failed, response.Failed)
+}
+
+func TestImportHandler_BestEffortCreateFails(t *testing.T) {
+	invoices := &fakeInvoices{}
+	rec := serveImport(t, invoices, importModeBestEffort, "text/csv", "user_id,amount,label\n1,10,First\n1,10,failing\n2,20,Third\n")
+
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var response ImportHandlerResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, ImportHandlerResponse{
+		Mode:     importModeBestEffort,
+		Imported: 2,
+		Failed:   1,
+		Rows: []ImportRowReport{
+			{Row: 2, InvoiceID: 100},
+			{Row: 3, Errors: []string{"invoice could not be created"}},
+			{Row: 4, InvoiceID: 101},
+		},
+	}, response)
+}
+
+func TestImportHandler_Rejected(t *testing.T) {
+	var tooMany strings.Builder
+	tooMany.WriteString("user_id,amount,label\n")
+	for i := 0; i <= maxImportRows; i++ {
+		fmt.Fprintf(&tooMany, "1,10,Row %d\n", i)
+	}
+
+	for name, test := range map[string]struct {
+		mode        string
+		contentType string
+		body        string
+		status      int
+		detail      string
+	}{
+		"unknown mode": {
+			mode:        "eventually",
+			contentType: "text/csv",
+			body:        "user_id,amount,label\n1,10,Consulting\n",
+			status:      http.StatusBadRequest,
+			detail:      "Invalid mode",
+		},
+		"unsupported content type": {
+			contentType: echo.MIMEApplicationJSON,
+			body:        `[{"user_id":1,"amount":10,"label":"Consulting"}]`,
+			status:      http.StatusUnsupportedMediaType,
+			detail:      "Expected text/csv or application/x-ndjson",
+		},
+		"no content type": {
+			body:   "user_id,amount,label\n1,10,Consulting\n",
+			status: http.StatusUnsupportedMediaType,
+			detail: "Expected text/csv or application/x-ndjson",
+		},
+		"missing header": {
+			contentType: "text/csv",
+			body:        "",
+			status:      http.StatusBadRequest,
+			detail:      "read header: EOF",
+		},
+		"missing column": {
+			contentType: "text/csv",
+			body:        "user_id,label\n1,Consulting\n",
+			status:      http.StatusBadRequest,
+			detail:      "missing column: amount",
+		},
+		"too many csv rows": {
+			contentType: "text/csv",
+			body:        tooMany.String(),
+			status:      http.StatusBadRequest,
+			detail:      ErrTooManyRows.Error(),
+		},
+		"too many jsonl rows": {
+			contentType: "application/x-ndjson",
+			body:        strings.Repeat(`{"user_id":1,"amount":10,"label":"Consulting"}`+"\n", maxImportRows+1),
+			status:      http.StatusBadRequest,
+			detail:      ErrTooManyRows.Error(),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			invoices := &fakeInvoices{}
+			rec := serveImport(t, invoices, test.mode, test.contentType, test.body)
+
+			assert.Equal(t, test.status, rec.Code)
+			assert.Equal(t, "application/problem+json", rec.Header().Get(echo.HeaderContentType))
+			var p problem.Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &p))
+			assert.Equal(t, test.detail, p.Detail)
+			assert.Empty(t, invoices.created)
+		})
+	}
+}
+
+func TestImportHandler_MaxRows(t *testing.T) {
+	invoices := &fakeInvoices{}
+	rec := serveImport(t, invoices, importModeAtomic, "application/x-ndjson",
+		strings.Repeat(`{"user_id":1,"amount":10,"label":"Consulting"}`+"\n", maxImportRows))
+
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Len(t, invoices.created, maxImportRows, "an import of exactly maxImportRows rows is accepted")
+}
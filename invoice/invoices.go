@@ -0,0 +1,135 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/outbox"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/emilien-puget/invoice_microservice/webhook"
+	"github.com/go-playground/validator/v10"
+)
+
+// Invoices issues and reads the invoices, whether for the REST or the gRPC API.
+type Invoices struct {
+	invoiceRepository interface {
+		Create(ctx context.Context, invoice Invoice) (Issued, error)
+		CreateMany(ctx context.Context, invoices []Invoice) ([]Issued, error)
+		GetByID(ctx context.Context, id int64) (*Invoice, error)
+	}
+	userRepository interface {
+		GetById(ctx context.Context, id int64) (*user.User, error)
+	}
+	outbox     eventAppender
+	transactor interface {
+		WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	}
+	validator *validator.Validate
+	metrics   *Metrics
+}
+
+func NewInvoices(validate *validator.Validate, repository *Repository, userRepository *user.Repository, outboxRepository *outbox.Repository, transactor *storage.Transactor, metrics *Metrics) *Invoices {
+	return &Invoices{validator: validate, invoiceRepository: repository, userRepository: userRepository, outbox: outboxRepository, transactor: transactor, metrics: metrics}
+}
+
+// defaultPaymentTerm is applied when the payload does not set a due date.
+const defaultPaymentTerm = 30 * 24 * time.Hour
+
+type CreateInvoicePayload struct {
+	UserID int64      `json:"user_id" validate:"required"`
+	Amount float64    `json:"amount" validate:"required"`
+	Label  string     `json:"label" validate:"required"`
+	DueAt  *time.Time `json:"due_at"`
+}
+
+func (p CreateInvoicePayload) invoice() Invoice {
+	dueAt := time.Now().Add(defaultPaymentTerm)
+	if p.DueAt != nil {
+		dueAt = *p.DueAt
+	}
+
+	return Invoice{
+		UserID: p.UserID,
+		Amount: money.NewMoneyFromFloat(p.Amount),
+		Status: "pending",
+		Label:  p.Label,
+		DueAt:  dueAt,
+	}
+}
+
+// Create issues the invoice of the payload along with its event. It returns validator.ValidationErrors for an
+// invalid payload and user.ErrUserNotFound.
+func (s *Invoices) Create(ctx context.Context, payload CreateInvoicePayload) (Invoice, error) {
+	if err := s.validator.Struct(payload); err != nil {
+		return Invoice{}, err
+	}
+
+	_, err := s.userRepository.GetById(ctx, payload.UserID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return Invoice{}, err
+		}
+		return Invoice{}, fmt.Errorf("userRepository.GetById: %w", err)
+	}
+
+	invoice := payload.invoice()
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		issued, err := s.invoiceRepository.Create(ctx, invoice)
+		if err != nil {
+			return fmt.Errorf("invoiceRepository.Create: %w", err)
+		}
+		invoice.ID, invoice.Number = issued.ID, issued.Number
+		return appendEvent(ctx, s.outbox, webhook.EventInvoiceCreated, newEvent(&invoice))
+	})
+	if err != nil {
+		return Invoice{}, err
+	}
+	s.metrics.created(ctx, invoice)
+
+	return invoice, nil
+}
+
+// CreateMany issues the invoices of the payloads along with their events, either all of them are created or none is.
+// The payloads are not checked, the import checks them first to report the errors of every row.
+func (s *Invoices) CreateMany(ctx context.Context, payloads []CreateInvoicePayload) ([]Invoice, error) {
+	invoices := make([]Invoice, len(payloads))
+	for i, payload := range payloads {
+		invoices[i] = payload.invoice()
+	}
+
+	err := s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		issued, err := s.invoiceRepository.CreateMany(ctx, invoices)
+		if err != nil {
+			return fmt.Errorf("invoiceRepository.CreateMany: %w", err)
+		}
+		for i := range issued {
+			invoices[i].ID, invoices[i].Number = issued[i].ID, issued[i].Number
+			if err := appendEvent(ctx, s.outbox, webhook.EventInvoiceCreated, newEvent(&invoices[i])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.metrics.created(ctx, invoices...)
+
+	return invoices, nil
+}
+
+// Get returns ErrInvoiceNotFound for the invoices the principal of ctx may not read.
+func (s *Invoices) Get(ctx context.Context, id int64) (*Invoice, error) {
+	invoice, err := s.invoiceRepository.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrInvoiceNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invoiceRepository.GetByID: %w", err)
+	}
+	return invoice, nil
+}
@@ -0,0 +1,114 @@
+package invoice
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/outbox"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant/tenanttest"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoices_Create(t *testing.T) {
+	const (
+		userQuery   = "SELECT id, first_name, last_name, balance FROM jump.public.users WHERE id = $1 AND tenant_id = $2 AND ($3 = 0 OR id = $3)"
+		outboxQuery = "INSERT INTO jump.public.outbox (tenant_id, aggregate_type, aggregate_id, event_type, payload) VALUES ($1, $2, $3, $4, $5)"
+	)
+	dueAt := time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)
+
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	invoices := NewInvoices(validator.New(), NewInvoiceRepository(db), user.NewUserRepository(db), outbox.NewOutboxRepository(db),
+		storage.NewTransactor(db), NewMetrics(prometheus.NewRegistry(), "test", Billing{Currency: "EUR"}))
+
+	mock.ExpectPrepare(userQuery)
+	mock.ExpectQuery(userQuery).WithArgs(1, "acme", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "balance"}).AddRow(1, "John", "Doe", 0))
+	mock.ExpectBegin()
+	mock.ExpectPrepare(expectedCreateQuery).ExpectQuery().
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number"}).AddRow(10, 4))
+	var payload []byte
+	mock.ExpectExec(outboxQuery).WithArgs("acme", AggregateType, "10", "invoice.created", argCapture{&payload}).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	invoice, err := invoices.Create(tenanttest.Context(), CreateInvoicePayload{UserID: 1, Amount: 10, Label: "Consulting", DueAt: &dueAt})
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), invoice.ID)
+	assert.Equal(t, int64(4), invoice.Number)
+
+	// The subscribers get the number of the invoice
+	var event Event
+	require.NoError(t, json.Unmarshal(payload, &event))
+	assert.Equal(t, Event{InvoiceID: 10, Number: 4, UserID: 1, Status: "pending", Label: "Consulting", AmountCents: 1000, DueAt: dueAt}, event)
+
+	// Ensure all expectations were met
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInvoices_CreateMany(t *testing.T) {
+	const outboxQuery = "INSERT INTO jump.public.outbox (tenant_id, aggregate_type, aggregate_id, event_type, payload) VALUES ($1, $2, $3, $4, $5)"
+	dueAt := time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)
+
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	invoices := NewInvoices(validator.New(), NewInvoiceRepository(db), user.NewUserRepository(db), outbox.NewOutboxRepository(db),
+		storage.NewTransactor(db), NewMetrics(prometheus.NewRegistry(), "test", Billing{Currency: "EUR"}))
+
+	// The invoices and their events are written in a single transaction
+	mock.ExpectBegin()
+	mock.ExpectQuery(expectedCreateManyQuery).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number"}).AddRow(12, 8).AddRow(11, 7))
+	payloads := make([][]byte, 2)
+	for i, id := range []string{"11", "12"} {
+		mock.ExpectExec(outboxQuery).WithArgs("acme", AggregateType, id, "invoice.created", argCapture{&payloads[i]}).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	created, err := invoices.CreateMany(tenanttest.Context(), []CreateInvoicePayload{
+		{UserID: 1, Amount: 10, Label: "First", DueAt: &dueAt},
+		{UserID: 2, Amount: 20, Label: "Second", DueAt: &dueAt},
+	})
+	require.NoError(t, err)
+	require.Len(t, created, 2)
+
+	for i, want := range []Event{
+		{InvoiceID: 11, Number: 7, UserID: 1, Status: "pending", Label: "First", AmountCents: 1000, DueAt: dueAt},
+		{InvoiceID: 12, Number: 8, UserID: 2, Status: "pending", Label: "Second", AmountCents: 2000, DueAt: dueAt},
+	} {
+		assert.Equal(t, want.InvoiceID, created[i].ID)
+		assert.Equal(t, want.Number, created[i].Number)
+		var event Event
+		require.NoError(t, json.Unmarshal(payloads[i], &event))
+		assert.Equal(t, want, event)
+	}
+
+	// Ensure all expectations were met
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// argCapture matches any argument and keeps it.
+type argCapture struct {
+	value *[]byte
+}
+
+func (a argCapture) Match(v driver.Value) bool {
+	b, ok := v.([]byte)
+	*a.value = b
+	return ok
+}
@@ -0,0 +1,116 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The outcomes of the payments in the duration histogram.
+const (
+	paymentApplied  = "applied"
+	paymentRejected = "rejected"
+	paymentFailed   = "failed"
+)
+
+// Metrics counts the invoices issued and the payments applied, the amounts are totalled in the currency of the tenant.
+type Metrics struct {
+	billing          Billing
+	invoicesCreated  *prometheus.CounterVec
+	invoicedAmount   *prometheus.CounterVec
+	paymentsApplied  *prometheus.CounterVec
+	paidAmount       *prometheus.CounterVec
+	paymentsRejected *prometheus.CounterVec
+	paymentDuration  *prometheus.HistogramVec
+}
+
+// NewMetrics registers the metrics on registerer, their names are prefixed with namespace.
+func NewMetrics(registerer prometheus.Registerer, namespace string, billing Billing) *Metrics {
+	factory := promauto.With(registerer)
+	return &Metrics{
+		billing: billing,
+		invoicesCreated: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "invoices_created_total",
+			Help:      "Number of invoices created, through the API or an import.",
+		}, []string{"currency"}),
+		invoicedAmount: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "invoiced_amount_total",
+			Help:      "Total amount of the invoices created, tax included.",
+		}, []string{"currency"}),
+		paymentsApplied: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "payments_applied_total",
+			Help:      "Number of payments applied to an invoice.",
+		}, []string{"currency"}),
+		paidAmount: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "paid_amount_total",
+			Help:      "Total amount of the payments applied.",
+		}, []string{"currency"}),
+		paymentsRejected: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "payments_rejected_total",
+			Help:      "Number of payments rejected, by reason: invalid_payload, not_found, wrong_amount or already_paid.",
+		}, []string{"reason"}),
+		paymentDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "payment_duration_seconds",
+			Help:      "Time taken to apply a payment, by outcome: applied, rejected or failed.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"outcome"}),
+	}
+}
+
+// created counts the invoices created for the tenant of ctx.
+func (m *Metrics) created(ctx context.Context, invoices ...Invoice) {
+	currency := m.billing.ForTenant(ctx).Currency
+	for _, invoice := range invoices {
+		m.invoicesCreated.WithLabelValues(currency).Inc()
+		// A counter cannot go down, a negative amount would panic
+		if invoice.Amount > 0 {
+			m.invoicedAmount.WithLabelValues(currency).Add(invoice.Amount.ToFloat())
+		}
+	}
+}
+
+// paid observes a payment that took since start, invoice is nil when it was not found.
+func (m *Metrics) paid(ctx context.Context, invoice *Invoice, start time.Time, err error) {
+	outcome := paymentApplied
+	switch reason := rejectionReason(err); {
+	case err == nil:
+		currency := m.billing.ForTenant(ctx).Currency
+		m.paymentsApplied.WithLabelValues(currency).Inc()
+		if invoice.Amount > 0 {
+			m.paidAmount.WithLabelValues(currency).Add(invoice.Amount.ToFloat())
+		}
+	case reason != "":
+		outcome = paymentRejected
+		m.paymentsRejected.WithLabelValues(reason).Inc()
+	default:
+		outcome = paymentFailed
+	}
+	m.paymentDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+// rejectionReason returns why the payment was rejected, an empty string when err does not come from the payment.
+func rejectionReason(err error) string {
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.As(err, &validationErrors):
+		return "invalid_payload"
+	case errors.Is(err, ErrInvoiceNotFound):
+		return "not_found"
+	case errors.Is(err, ErrInvalidAmount):
+		return "wrong_amount"
+	case errors.Is(err, ErrInvoiceAlreadyPaid):
+		return "already_paid"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,116 @@
+package invoice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Paid(t *testing.T) {
+	validationErr := validator.New().Struct(TransactionPayload{})
+	require.Error(t, validationErr)
+	paid := &Invoice{ID: 10, Amount: 1050}
+
+	for name, test := range map[string]struct {
+		invoice  *Invoice
+		err      error
+		counters string
+		outcome  string
+	}{
+		"applied": {
+			invoice: paid,
+			counters: `
+				# HELP test_paid_amount_total Total amount of the payments applied.
+				# TYPE test_paid_amount_total counter
+				test_paid_amount_total{currency="USD"} 10.5
+				# HELP test_payments_applied_total Number of payments applied to an invoice.
+				# TYPE test_payments_applied_total counter
+				test_payments_applied_total{currency="USD"} 1
+			`,
+			outcome: paymentApplied,
+		},
+		"invalid payload": {
+			err:      validationErr,
+			counters: rejected("invalid_payload"),
+			outcome:  paymentRejected,
+		},
+		"not found": {
+			err:      ErrInvoiceNotFound,
+			counters: rejected("not_found"),
+			outcome:  paymentRejected,
+		},
+		"wrong amount": {
+			invoice:  paid,
+			err:      ErrInvalidAmount,
+			counters: rejected("wrong_amount"),
+			outcome:  paymentRejected,
+		},
+		"already paid": {
+			invoice:  paid,
+			err:      fmt.Errorf("transactor.WithinTx: %w", ErrInvoiceAlreadyPaid),
+			counters: rejected("already_paid"),
+			outcome:  paymentRejected,
+		},
+		"failed": {
+			invoice: paid,
+			err:     errConnectionLost,
+			outcome: paymentFailed,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			metrics := NewMetrics(registry, "test", Billing{Currency: "EUR"})
+			currency := "USD"
+			ctx := tenant.NewContext(context.Background(), &tenant.Tenant{ID: "acme", Settings: tenant.Settings{Currency: &currency}})
+
+			metrics.paid(ctx, test.invoice, time.Now(), test.err)
+
+			require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(test.counters),
+				"test_paid_amount_total", "test_payments_applied_total", "test_payments_rejected_total"))
+			assert.Equal(t, 1, testutil.CollectAndCount(metrics.paymentDuration))
+			assert.Equal(t, uint64(1), sampleCount(t, metrics.paymentDuration.WithLabelValues(test.outcome).(prometheus.Histogram)))
+		})
+	}
+}
+
+func TestMetrics_Created(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics(registry, "test", Billing{Currency: "EUR"})
+
+	metrics.created(context.Background(), Invoice{Amount: 1000}, Invoice{Amount: 250})
+
+	expected := `
+		# HELP test_invoiced_amount_total Total amount of the invoices created, tax included.
+		# TYPE test_invoiced_amount_total counter
+		test_invoiced_amount_total{currency="EUR"} 12.5
+		# HELP test_invoices_created_total Number of invoices created, through the API or an import.
+		# TYPE test_invoices_created_total counter
+		test_invoices_created_total{currency="EUR"} 2
+	`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "test_invoiced_amount_total", "test_invoices_created_total"))
+}
+
+func rejected(reason string) string {
+	return `
+		# HELP test_payments_rejected_total Number of payments rejected, by reason: invalid_payload, not_found, wrong_amount or already_paid.
+		# TYPE test_payments_rejected_total counter
+		test_payments_rejected_total{reason="` + reason + `"} 1
+	`
+}
+
+func sampleCount(t *testing.T, histogram prometheus.Histogram) uint64 {
+	t.Helper()
+	metric := &dto.Metric{}
+	require.NoError(t, histogram.Write(metric))
+	return metric.GetHistogram().GetSampleCount()
+}
@@ -0,0 +1,114 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/outbox"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/emilien-puget/invoice_microservice/webhook"
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	ErrInvalidAmount      = errors.New("invalid amount")
+	ErrInvoiceAlreadyPaid = errors.New("invoice already paid")
+)
+
+// Payments applies the payments of invoices, whether they come from the API or from the broker.
+type Payments struct {
+	invoiceRepository interface {
+		GetByID(ctx context.Context, id int64) (*Invoice, error)
+		MarkAsPaid(ctx context.Context, id int64) error
+	}
+	userRepository interface {
+		ModifyBalance(ctx context.Context, userID int64, amount money.Money) error
+	}
+	transactionRepository interface {
+		Create(ctx context.Context, transaction Transaction) (int64, error)
+	}
+	outbox     eventAppender
+	transactor interface {
+		WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	}
+	validator *validator.Validate
+	metrics   *Metrics
+}
+
+func NewPayments(invoiceRepository *Repository, userRepository *user.Repository, transactionRepository *TransactionRepository, outboxRepository *outbox.Repository, transactor *storage.Transactor, validate *validator.Validate, metrics *Metrics) *Payments {
+	return &Payments{invoiceRepository: invoiceRepository, userRepository: userRepository, transactionRepository: transactionRepository, outbox: outboxRepository, transactor: transactor, validator: validate, metrics: metrics}
+}
+
+type TransactionPayload struct {
+	InvoiceID int64   `json:"invoice_id" validate:"required"`
+	Amount    float64 `json:"amount" validate:"required"`
+	Reference string  `json:"reference" validate:"required"`
+}
+
+// Apply pays the invoice of the payload. It returns validator.ValidationErrors for an invalid payload,
+// ErrInvoiceNotFound, ErrInvalidAmount when the amount is not the one of the invoice and ErrInvoiceAlreadyPaid.
+func (p *Payments) Apply(ctx context.Context, payload TransactionPayload) error {
+	start := time.Now()
+	invoice, err := p.apply(ctx, payload)
+	p.metrics.paid(ctx, invoice, start, err)
+	return err
+}
+
+// apply returns the invoice paid, nil when it could not be read.
+func (p *Payments) apply(ctx context.Context, payload TransactionPayload) (*Invoice, error) {
+	if err := p.validator.Struct(payload); err != nil {
+		return nil, err
+	}
+
+	// Fetch the invoice by ID
+	invoice, err := p.invoiceRepository.GetByID(ctx, payload.InvoiceID)
+	if err != nil {
+		if errors.Is(err, ErrInvoiceNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("invoiceRepository.GetByID: %w", err)
+	}
+
+	if invoice.Amount != money.NewMoneyFromFloat(payload.Amount) {
+		return invoice, ErrInvalidAmount
+	}
+	if invoice.Status == "paid" {
+		return invoice, ErrInvoiceAlreadyPaid
+	}
+
+	// The invoice is marked paid first, so that it is locked until the payment is recorded along with its event
+	return invoice, p.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		err := p.invoiceRepository.MarkAsPaid(ctx, invoice.ID)
+		if err != nil {
+			// The invoice was read above, a concurrent payment marked it paid first
+			if errors.Is(err, ErrInvoiceNotFound) {
+				return ErrInvoiceAlreadyPaid
+			}
+			return fmt.Errorf("invoiceRepository.MarkAsPaid: %w", err)
+		}
+
+		err = p.userRepository.ModifyBalance(ctx, invoice.UserID, invoice.Amount)
+		if err != nil {
+			return fmt.Errorf("userRepository.ModifyBalance: %w", err)
+		}
+
+		_, err = p.transactionRepository.Create(ctx, Transaction{
+			InvoiceID: invoice.ID,
+			UserID:    invoice.UserID,
+			Amount:    invoice.Amount,
+			Reference: payload.Reference,
+		})
+		if err != nil {
+			return fmt.Errorf("transactionRepository.Create: %w", err)
+		}
+
+		invoice.Status = "paid"
+		event := newEvent(invoice)
+		event.Reference = payload.Reference
+		return appendEvent(ctx, p.outbox, webhook.EventInvoicePaid, event)
+	})
+}
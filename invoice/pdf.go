@@ -0,0 +1,199 @@
+package invoice
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/go-pdf/fpdf"
+)
+
+const dateLayout = "2006-01-02"
+
+// PDFTemplate holds the parts of the rendered invoice that only exist on paper.
+type PDFTemplate struct {
+	// PaymentInstructions is a text/template executed with a PaymentInstructionsData.
+	PaymentInstructions string
+	Footer              string
+	// Logo is an optional PNG or JPEG image printed in the header.
+	Logo []byte
+}
+
+// PaymentInstructionsData is the data available to PDFTemplate.PaymentInstructions.
+type PaymentInstructionsData struct {
+	Number   string
+	Total    string
+	Currency string
+	DueDate  string
+	IBAN     string
+}
+
+// PDFRenderer renders invoices as PDF documents.
+// The output only depends on its inputs, the same invoice always renders to the same bytes.
+type PDFRenderer struct {
+	template            PDFTemplate
+	paymentInstructions *template.Template
+	logoType            string
+}
+
+var ErrUnsupportedLogo = errors.New("unsupported logo format")
+
+func NewPDFRenderer(tpl PDFTemplate) (*PDFRenderer, error) {
+	paymentInstructions, err := template.New("payment_instructions").Parse(tpl.PaymentInstructions)
+	if err != nil {
+		return nil, fmt.Errorf("parse payment instructions: %w", err)
+	}
+
+	r := &PDFRenderer{template: tpl, paymentInstructions: paymentInstructions}
+	if len(tpl.Logo) > 0 {
+		switch contentType := http.DetectContentType(tpl.Logo); contentType {
+		case "image/png":
+			r.logoType = "PNG"
+		case "image/jpeg":
+			r.logoType = "JPG"
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedLogo, contentType)
+		}
+	}
+
+	return r, nil
+}
+
+func (r *PDFRenderer) Render(w io.Writer, doc Document) error {
+	invoice := doc.Invoice
+
+	var instructions bytes.Buffer
+	err := r.paymentInstructions.Execute(&instructions, PaymentInstructionsData{
+		Number:   doc.Number,
+		Total:    invoice.Amount.String(),
+		Currency: doc.Currency,
+		DueDate:  formatDate(invoice.DueAt),
+		IBAN:     doc.SellerIBAN,
+	})
+	if err != nil {
+		return fmt.Errorf("execute payment instructions: %w", err)
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	// Pin every source of variation so that the output is byte for byte reproducible.
+	pdf.SetCreationDate(invoice.CreatedAt.UTC())
+	pdf.SetModificationDate(invoice.CreatedAt.UTC())
+	pdf.SetCatalogSort(true)
+	pdf.SetCompression(false)
+	pdf.SetTitle(doc.Number, true)
+	pdf.SetAuthor(doc.Seller.Name, true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	// Header: logo on the left, seller on the right.
+	if r.logoType != "" {
+		pdf.RegisterImageOptionsReader("logo", fpdf.ImageOptions{ImageType: r.logoType}, bytes.NewReader(r.template.Logo))
+		pdf.ImageOptions("logo", 10, 10, 0, 20, false, fpdf.ImageOptions{ImageType: r.logoType}, 0, "")
+	}
+	pdf.SetXY(110, 10)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(90, 6, tr(doc.Seller.Name), "", 2, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range partyLines(doc.Seller) {
+		pdf.CellFormat(90, 5, tr(line), "", 2, "R", false, 0, "")
+	}
+
+	pdf.SetXY(10, 40)
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, tr("Invoice "+doc.Number), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 5, "Issue date: "+formatDate(invoice.CreatedAt), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, "Due date: "+formatDate(invoice.DueAt), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, tr("Status: "+invoice.Status), "", 1, "L", false, 0, "")
+	pdf.Ln(5)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, 5, "Bill to", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 5, tr(doc.Buyer.Name), "", 1, "L", false, 0, "")
+	pdf.Ln(8)
+
+	// Line items, an invoice currently carries a single line.
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	pdf.CellFormat(100, 7, "Description", "1", 0, "L", true, 0, "")
+	pdf.CellFormat(20, 7, "Qty", "1", 0, "R", true, 0, "")
+	pdf.CellFormat(35, 7, "Unit price", "1", 0, "R", true, 0, "")
+	pdf.CellFormat(35, 7, "Amount", "1", 1, "R", true, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(100, 7, tr(invoice.Label), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(20, 7, "1", "1", 0, "R", false, 0, "")
+	pdf.CellFormat(35, 7, doc.Net.String(), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(35, 7, doc.Net.String(), "1", 1, "R", false, 0, "")
+	pdf.Ln(8)
+
+	// Tax breakdown.
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(40, 7, "Tax rate", "1", 0, "L", true, 0, "")
+	pdf.CellFormat(40, 7, "Taxable amount", "1", 0, "R", true, 0, "")
+	pdf.CellFormat(40, 7, "Tax", "1", 1, "R", true, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(40, 7, fmt.Sprintf("%.2f %%", doc.TaxRate), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(40, 7, doc.Net.String(), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(40, 7, doc.Tax.String(), "1", 1, "R", false, 0, "")
+	pdf.Ln(8)
+
+	// Totals.
+	totals := []struct {
+		label  string
+		amount money.Money
+	}{
+		{"Total excluding tax", doc.Net},
+		{"Tax", doc.Tax},
+		{"Total", invoice.Amount},
+	}
+	for i, total := range totals {
+		if i == len(totals)-1 {
+			pdf.SetFont("Helvetica", "B", 11)
+		}
+		pdf.SetX(120)
+		pdf.CellFormat(45, 7, total.label, "", 0, "L", false, 0, "")
+		pdf.CellFormat(35, 7, total.amount.String()+" "+doc.Currency, "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(10)
+
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, 6, "Payment instructions", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.MultiCell(0, 5, tr(instructions.String()), "", "L", false)
+
+	if r.template.Footer != "" {
+		pdf.SetY(-25)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.MultiCell(0, 4, tr(r.template.Footer), "", "C", false)
+	}
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("pdf.Output: %w", err)
+	}
+	return nil
+}
+
+// partyLines returns the non-empty address lines of a party.
+func partyLines(p Party) []string {
+	lines := make([]string, 0, 4)
+	for _, line := range []string{p.Street, strings.TrimSpace(p.PostalCode + " " + p.City), p.CountryCode} {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if p.VatID != "" {
+		lines = append(lines, "VAT: "+p.VatID)
+	}
+	return lines
+}
+
+// formatDate keeps the rendered dates independent of the server timezone.
+func formatDate(t time.Time) string {
+	return t.UTC().Format(dateLayout)
+}
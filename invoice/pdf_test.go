@@ -0,0 +1,52 @@
+package invoice
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// assertGolden compares actual with the named golden file in testdata, rewriting it when -update is set.
+func assertGolden(t *testing.T, name string, actual []byte) {
+	t.Helper()
+
+	golden := filepath.Join("testdata", name)
+	if *update {
+		require.NoError(t, os.WriteFile(golden, actual, 0o644))
+	}
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestPDFRenderer_Render(t *testing.T) {
+	logo, err := os.ReadFile(filepath.Join("testdata", "logo.png"))
+	require.NoError(t, err)
+
+	renderer, err := NewPDFRenderer(PDFTemplate{
+		PaymentInstructions: "Please pay {{.Total}} {{.Currency}} before {{.DueDate}} to {{.IBAN}} using the reference {{.Number}}.",
+		Footer:              "Jump SAS - VAT FR00123456789",
+		Logo:                logo,
+	})
+	require.NoError(t, err)
+
+	// Render twice to make sure the output does not depend on anything but the inputs
+	var first, second bytes.Buffer
+	require.NoError(t, renderer.Render(&first, testDocument()))
+	require.NoError(t, renderer.Render(&second, testDocument()))
+	assert.Equal(t, first.Bytes(), second.Bytes())
+
+	assertGolden(t, "invoice.pdf.golden", first.Bytes())
+}
+
+func TestNewPDFRenderer_UnsupportedLogo(t *testing.T) {
+	_, err := NewPDFRenderer(PDFTemplate{Logo: []byte("GIF89a")})
+	require.ErrorIs(t, err, ErrUnsupportedLogo)
+}
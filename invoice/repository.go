@@ -5,60 +5,242 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
+	"time"
 
+	"github.com/emilien-puget/invoice_microservice/auth"
 	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant"
 )
 
 type Invoice struct {
-	ID     int64
-	UserID int64
-	Status string
-	Label  string
-	Amount money.Money
+	ID int64
+	// Number is sequential per tenant, it is assigned on creation.
+	Number    int64
+	UserID    int64
+	Status    string
+	Label     string
+	Amount    money.Money
+	CreatedAt time.Time
+	DueAt     time.Time
 }
 
 type Repository struct {
 	db *sql.DB
+	// sqlite is set for the databases opened with storage.DriverSQLite, which cannot update the tenant in the statement
+	// inserting the invoices.
+	sqlite bool
 }
 
 func NewInvoiceRepository(db *sql.DB) *Repository {
 	return &Repository{
-		db: db,
+		db:     db,
+		sqlite: storage.IsSQLite(db),
 	}
 }
 
-func (r *Repository) Create(ctx context.Context, invoice Invoice) (int64, error) {
-	query := `
-		INSERT INTO jump.public.invoices (user_id, label, amount)
-		VALUES ($1, $2, $3)
-		RETURNING id
-	`
+// createQuery takes the next number of the tenant, the tenant row stays locked until the end of the transaction so
+// that numbers have no gaps.
+const createQuery = `
+	WITH sequence AS (
+		UPDATE jump.public.tenants
+		SET invoice_sequence = invoice_sequence + 1
+		WHERE id = $1
+		RETURNING invoice_sequence
+	)
+	INSERT INTO jump.public.invoices (tenant_id, number, user_id, label, amount, due_at)
+	SELECT $1, invoice_sequence, $2, $3, $4, $5
+	FROM sequence
+	RETURNING id, number
+`
+
+// Issued is what the database assigns to an invoice it creates.
+type Issued struct {
+	ID     int64
+	Number int64
+}
+
+func (r *Repository) Create(ctx context.Context, invoice Invoice) (_ Issued, err error) {
+	ctx, span := storage.StartSpan(ctx, "invoice.Repository.Create", storage.OperationInsert, "invoices")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return Issued{}, err
+	}
+	if r.sqlite {
+		issued, err := r.createSQLite(ctx, tenantID, []Invoice{invoice})
+		if err != nil {
+			return Issued{}, fmt.Errorf("failed to create invoice: %w", err)
+		}
+		return issued[0], nil
+	}
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := storage.Conn(ctx, r.db).PrepareContext(ctx, createQuery)
 	if err != nil {
-		return 0, fmt.Errorf("failed to prepare statement: %w", err)
+		return Issued{}, fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	var invoiceId int64
-	row := stmt.QueryRowContext(ctx, invoice.UserID, invoice.Label, invoice.Amount)
-	if err := row.Scan(&invoiceId); err != nil {
-		return 0, fmt.Errorf("failed to create invoice: %w", err)
+	var issued Issued
+	row := stmt.QueryRowContext(ctx, tenantID, invoice.UserID, invoice.Label, invoice.Amount, invoice.DueAt)
+	if err := row.Scan(&issued.ID, &issued.Number); err != nil {
+		return Issued{}, fmt.Errorf("failed to create invoice: %w", err)
+	}
+
+	return issued, nil
+}
+
+// createManyQuery takes as many numbers of the tenant as there are invoices and inserts them in a single statement,
+// the invoices are sent as arrays. The numbers are returned along with the ids since RETURNING does not keep the order
+// of the arrays.
+const createManyQuery = `
+	WITH sequence AS (
+		UPDATE jump.public.tenants
+		SET invoice_sequence = invoice_sequence + cardinality($2::bigint[])
+		WHERE id = $1
+		RETURNING invoice_sequence - cardinality($2::bigint[]) AS previous
+	)
+	INSERT INTO jump.public.invoices (tenant_id, number, user_id, label, amount, due_at)
+	SELECT $1, previous + batch.position, batch.user_id, batch.label, batch.amount, batch.due_at
+	FROM sequence, unnest($2::bigint[], $3::varchar[], $4::bigint[], $5::timestamptz[])
+		WITH ORDINALITY AS batch (user_id, label, amount, due_at, position)
+	RETURNING id, number
+`
+
+// CreateMany inserts the invoices in a single statement, in a single transaction on SQLite, either all of them are
+// created or none is. The invoices are issued in order.
+func (r *Repository) CreateMany(ctx context.Context, invoices []Invoice) (_ []Issued, err error) {
+	ctx, span := storage.StartSpan(ctx, "invoice.Repository.CreateMany", storage.OperationInsert, "invoices")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(invoices) == 0 {
+		return []Issued{}, nil
+	}
+	if r.sqlite {
+		issued, err := r.createSQLite(ctx, tenantID, invoices)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create invoices: %w", err)
+		}
+		return issued, nil
+	}
+
+	userIDs := make([]int64, len(invoices))
+	labels := make([]string, len(invoices))
+	amounts := make([]int64, len(invoices))
+	dueAts := make([]string, len(invoices))
+	for i, invoice := range invoices {
+		userIDs[i] = invoice.UserID
+		labels[i] = invoice.Label
+		amounts[i] = int64(invoice.Amount)
+		dueAts[i] = invoice.DueAt.Format(time.RFC3339Nano)
+	}
+
+	rows, err := storage.Conn(ctx, r.db).QueryContext(ctx, createManyQuery, tenantID,
+		storage.Array(r.db, userIDs), storage.Array(r.db, labels), storage.Array(r.db, amounts), storage.Array(r.db, dueAts))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invoices: %w", err)
+	}
+	defer rows.Close()
+
+	idsByNumber := make(map[int64]int64, len(invoices))
+	first := int64(math.MaxInt64)
+	for rows.Next() {
+		var id, number int64
+		if err := rows.Scan(&id, &number); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		idsByNumber[number] = id
+		first = min(first, number)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to create invoices: %w", err)
+	}
+	if len(idsByNumber) != len(invoices) {
+		return nil, fmt.Errorf("failed to create invoices: %w", sql.ErrNoRows)
+	}
+
+	issued := make([]Issued, len(invoices))
+	for number, id := range idsByNumber {
+		issued[number-first] = Issued{ID: id, Number: number}
 	}
+	return issued, nil
+}
+
+// takeNumbersQuery takes count numbers of the tenant and returns the last one. SQLite locks the database when the
+// transaction begins, the numbers have no gaps either.
+const takeNumbersQuery = `
+	UPDATE tenants
+	SET invoice_sequence = invoice_sequence + $2
+	WHERE id = $1
+	RETURNING invoice_sequence
+`
+
+const insertQuery = `
+	INSERT INTO invoices (tenant_id, number, user_id, label, amount, due_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id
+`
+
+// createSQLite takes the numbers of the invoices then inserts them one by one in a transaction, SQLite has neither
+// statements updating a table in a WITH clause nor arrays.
+func (r *Repository) createSQLite(ctx context.Context, tenantID string, invoices []Invoice) ([]Issued, error) {
+	issued := make([]Issued, len(invoices))
+	err := storage.WithinTx(ctx, r.db, func(ctx context.Context) error {
+		conn := storage.Conn(ctx, r.db)
+
+		var last int64
+		if err := conn.QueryRowContext(ctx, takeNumbersQuery, tenantID, len(invoices)).Scan(&last); err != nil {
+			return fmt.Errorf("failed to take numbers: %w", err)
+		}
 
-	return invoiceId, nil
+		stmt, err := conn.PrepareContext(ctx, insertQuery)
+		if err != nil {
+			return fmt.Errorf("failed to prepare statement: %w", err)
+		}
+		defer stmt.Close()
+
+		first := last - int64(len(invoices)) + 1
+		for i, invoice := range invoices {
+			issued[i].Number = first + int64(i)
+			row := stmt.QueryRowContext(ctx, tenantID, issued[i].Number, invoice.UserID, invoice.Label, invoice.Amount, invoice.DueAt)
+			if err := row.Scan(&issued[i].ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
 }
 
 var ErrInvoiceNotFound = errors.New("invoice not found")
 
-func (r *Repository) MarkAsPaid(ctx context.Context, id int64) error {
+// MarkAsPaid returns ErrInvoiceNotFound for an invoice already paid, so that concurrent payments of an invoice only
+// mark it paid once.
+func (r *Repository) MarkAsPaid(ctx context.Context, id int64) (err error) {
+	ctx, span := storage.StartSpan(ctx, "invoice.Repository.MarkAsPaid", storage.OperationUpdate, "invoices")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE jump.public.invoices
 		SET status = 'paid'
-		WHERE id = $1
+		WHERE id = $1 AND tenant_id = $2 AND status <> 'paid'
 	`
 
-	result, err := r.db.ExecContext(ctx, query, id)
+	result, err := storage.Conn(ctx, r.db).ExecContext(ctx, storage.Dialect(r.db, query), id, tenantID)
 	if err != nil {
 		return fmt.Errorf("failed to mark invoice as paid: %w", err)
 	}
@@ -74,17 +256,26 @@ func (r *Repository) MarkAsPaid(ctx context.Context, id int64) error {
 	return nil
 }
 
-func (r *Repository) GetByID(ctx context.Context, id int64) (*Invoice, error) {
+// GetByID returns ErrInvoiceNotFound for the invoices the principal of ctx may not read.
+func (r *Repository) GetByID(ctx context.Context, id int64) (_ *Invoice, err error) {
+	ctx, span := storage.StartSpan(ctx, "invoice.Repository.GetByID", storage.OperationSelect, "invoices")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, user_id, status, label, amount
+		SELECT id, number, user_id, status, label, amount, created_at, due_at
 		FROM jump.public.invoices
-		WHERE id = $1
+		WHERE id = $1 AND tenant_id = $2 AND ($3 = 0 OR user_id = $3)
 	`
 
-	row := r.db.QueryRowContext(ctx, query, id)
+	row := storage.Conn(ctx, r.db).QueryRowContext(ctx, storage.Dialect(r.db, query), id, tenantID, auth.ScopedUserID(ctx))
 
 	var invoice Invoice
-	if err := row.Scan(&invoice.ID, &invoice.UserID, &invoice.Status, &invoice.Label, &invoice.Amount); err != nil {
+	if err := row.Scan(&invoice.ID, &invoice.Number, &invoice.UserID, &invoice.Status, &invoice.Label, &invoice.Amount, &invoice.CreatedAt, &invoice.DueAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrInvoiceNotFound
 		}
@@ -93,3 +284,92 @@ func (r *Repository) GetByID(ctx context.Context, id int64) (*Invoice, error) {
 
 	return &invoice, nil
 }
+
+// Export calls fn for every invoice created in [from, to), rows are handed over as they are read.
+func (r *Repository) Export(ctx context.Context, from, to time.Time, fn func(*Invoice) error) (err error) {
+	ctx, span := storage.StartSpan(ctx, "invoice.Repository.Export", storage.OperationSelect, "invoices")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT id, number, user_id, status, label, amount, created_at, due_at
+		FROM jump.public.invoices
+		WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY id
+	`
+
+	rows, err := storage.Conn(ctx, r.db).QueryContext(ctx, storage.Dialect(r.db, query), tenantID, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to query invoices: %w", err)
+	}
+	defer rows.Close()
+
+	var invoice Invoice
+	for rows.Next() {
+		if err := rows.Scan(&invoice.ID, &invoice.Number, &invoice.UserID, &invoice.Status, &invoice.Label, &invoice.Amount, &invoice.CreatedAt, &invoice.DueAt); err != nil {
+			return fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		if err := fn(&invoice); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read invoices: %w", err)
+	}
+
+	return nil
+}
+
+// OverdueInvoice is an invoice found by ClaimOverdue, with the tenant it belongs to.
+type OverdueInvoice struct {
+	Invoice
+	TenantID string
+}
+
+// ClaimOverdue marks the pending invoices due before at as notified and returns them, across every tenant. An
+// invoice is only returned once.
+func (r *Repository) ClaimOverdue(ctx context.Context, at time.Time) (_ []OverdueInvoice, err error) {
+	ctx, span := storage.StartSpan(ctx, "invoice.Repository.ClaimOverdue", storage.OperationUpdate, "invoices")
+	defer storage.EndSpan(span, &err)
+
+	query := `
+		UPDATE jump.public.invoices
+		SET overdue_notified_at = now()
+		WHERE status = 'pending' AND due_at < $1 AND overdue_notified_at IS NULL
+		RETURNING tenant_id, id, number, user_id, status, label, amount, created_at, due_at
+	`
+	if r.sqlite {
+		query = `
+			UPDATE invoices
+			SET overdue_notified_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+			WHERE status = 'pending' AND due_at < $1 AND overdue_notified_at IS NULL
+			RETURNING tenant_id, id, number, user_id, status, label, amount, created_at, due_at
+		`
+	}
+
+	rows, err := storage.Conn(ctx, r.db).QueryContext(ctx, query, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim overdue invoices: %w", err)
+	}
+	defer rows.Close()
+
+	var invoices []OverdueInvoice
+	for rows.Next() {
+		var i OverdueInvoice
+		if err := rows.Scan(&i.TenantID, &i.ID, &i.Number, &i.UserID, &i.Status, &i.Label, &i.Amount, &i.CreatedAt, &i.DueAt); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		invoices = append(invoices, i)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read invoices: %w", err)
+	}
+
+	return invoices, nil
+}
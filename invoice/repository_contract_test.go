@@ -0,0 +1,91 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/storage/storagetest"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoiceRepository_Contract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, ctx context.Context, db *sql.DB) {
+		conn := storage.Conn(ctx, db)
+		_, err := conn.ExecContext(ctx, `INSERT INTO tenants (id, name) VALUES ('storagetest', 'Storage test')`)
+		require.NoError(t, err)
+		var userID int64
+		err = conn.QueryRowContext(ctx, `INSERT INTO users (first_name, last_name, tenant_id) VALUES ('Ada', 'Lovelace', 'storagetest') RETURNING id`).Scan(&userID)
+		require.NoError(t, err)
+		ctx = tenant.NewContext(ctx, &tenant.Tenant{ID: "storagetest"})
+		repo := NewInvoiceRepository(db)
+
+		dueAt := time.Date(2023, 7, 31, 12, 30, 0, 123456000, time.UTC)
+		issued, err := repo.CreateMany(ctx, []Invoice{
+			{UserID: userID, Label: "First", Amount: 1000, DueAt: dueAt},
+			{UserID: userID, Label: "Second", Amount: 2000, DueAt: dueAt.Add(24 * time.Hour)},
+		})
+		require.NoError(t, err)
+		require.Len(t, issued, 2)
+		third, err := repo.Create(ctx, Invoice{UserID: userID, Label: "Third", Amount: 3000, DueAt: dueAt})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1, 2, 3}, []int64{issued[0].Number, issued[1].Number, third.Number}, "the numbers are returned")
+		ids := []int64{issued[0].ID, issued[1].ID}
+		id := third.ID
+
+		for i, want := range []Invoice{
+			{ID: ids[0], Number: 1, UserID: userID, Status: "pending", Label: "First", Amount: 1000, DueAt: dueAt},
+			{ID: ids[1], Number: 2, UserID: userID, Status: "pending", Label: "Second", Amount: 2000, DueAt: dueAt.Add(24 * time.Hour)},
+			{ID: id, Number: 3, UserID: userID, Status: "pending", Label: "Third", Amount: 3000, DueAt: dueAt},
+		} {
+			got, err := repo.GetByID(ctx, want.ID)
+			require.NoError(t, err, i)
+			assert.True(t, want.DueAt.Equal(got.DueAt), "the timestamp keeps its microseconds, got %s", got.DueAt)
+			assert.False(t, got.CreatedAt.IsZero())
+			want.DueAt, want.CreatedAt = got.DueAt, got.CreatedAt
+			assert.Equal(t, &want, got)
+		}
+
+		require.NoError(t, repo.MarkAsPaid(ctx, ids[1]))
+		paid, err := repo.GetByID(ctx, ids[1])
+		require.NoError(t, err)
+		assert.Equal(t, "paid", paid.Status)
+		assert.ErrorIs(t, repo.MarkAsPaid(ctx, ids[1]), ErrInvoiceNotFound, "an invoice is paid once")
+
+		var exported []int64
+		err = repo.Export(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), func(i *Invoice) error {
+			exported = append(exported, i.ID)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{ids[0], ids[1], id}, exported)
+		exported = nil
+		require.NoError(t, repo.Export(ctx, time.Now().Add(time.Hour), time.Now().Add(2*time.Hour), func(i *Invoice) error {
+			exported = append(exported, i.ID)
+			return nil
+		}))
+		assert.Empty(t, exported)
+
+		claimed := claimOverdue(t, ctx, repo, dueAt.Add(time.Hour))
+		assert.ElementsMatch(t, []int64{ids[0], id}, claimed, "the paid invoice is not overdue")
+		assert.Empty(t, claimOverdue(t, ctx, repo, dueAt.Add(time.Hour)), "an invoice is claimed once")
+	})
+}
+
+// claimOverdue returns the ids of the invoices of the test tenant claimed by ClaimOverdue, which claims across tenants.
+func claimOverdue(t *testing.T, ctx context.Context, repo *Repository, at time.Time) []int64 {
+	t.Helper()
+	overdue, err := repo.ClaimOverdue(ctx, at)
+	require.NoError(t, err)
+	var ids []int64
+	for _, i := range overdue {
+		if i.TenantID == "storagetest" {
+			ids = append(ids, i.ID)
+		}
+	}
+	return ids
+}
@@ -1,14 +1,23 @@
 package invoice
 
 import (
-	"context"
+	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/tenant/tenanttest"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+const expectedCreateQuery = `WITH sequence AS (
+	UPDATE jump.public.tenants SET invoice_sequence = invoice_sequence + 1 WHERE id = $1 RETURNING invoice_sequence
+)
+INSERT INTO jump.public.invoices (tenant_id, number, user_id, label, amount, due_at)
+SELECT $1, invoice_sequence, $2, $3, $4, $5 FROM sequence RETURNING id, number`
+
 func TestInvoiceRepository_Create(t *testing.T) {
 	// Create a new mock database connection
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -16,31 +25,114 @@ func TestInvoiceRepository_Create(t *testing.T) {
 	defer db.Close()
 
 	repo := NewInvoiceRepository(db)
-	ctx := context.Background()
+	ctx := tenanttest.Context()
 
 	// Create a test invoice
 	invoice := Invoice{
 		UserID: 1,
 		Label:  "Test Invoice",
 		Amount: 1000,
+		DueAt:  time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC),
 	}
 
 	// Mock the expected query and result
-	mock.ExpectPrepare("INSERT INTO jump.public.invoices (user_id, label, amount) VALUES ($1, $2, $3) RETURNING id").
+	mock.ExpectPrepare(expectedCreateQuery).
 		ExpectQuery().
-		WithArgs(invoice.UserID, invoice.Label, invoice.Amount).
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		WithArgs("acme", invoice.UserID, invoice.Label, invoice.Amount, invoice.DueAt).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number"}).AddRow(1, 4))
 
 	// Call the Create method
-	invoiceID, err := repo.Create(ctx, invoice)
+	issued, err := repo.Create(ctx, invoice)
 	require.NoError(t, err)
-	assert.Equal(t, int64(1), invoiceID)
+	assert.Equal(t, Issued{ID: 1, Number: 4}, issued)
 
 	// Ensure all expectations were met
 	err = mock.ExpectationsWereMet()
 	require.NoError(t, err)
 }
 
+const expectedCreateManyQuery = `WITH sequence AS (
+	UPDATE jump.public.tenants SET invoice_sequence = invoice_sequence + cardinality($2::bigint[]) WHERE id = $1
+	RETURNING invoice_sequence - cardinality($2::bigint[]) AS previous
+)
+INSERT INTO jump.public.invoices (tenant_id, number, user_id, label, amount, due_at)
+SELECT $1, previous + batch.position, batch.user_id, batch.label, batch.amount, batch.due_at
+FROM sequence, unnest($2::bigint[], $3::varchar[], $4::bigint[], $5::timestamptz[]) WITH ORDINALITY AS batch (user_id, label, amount, due_at, position)
+RETURNING id, number`
+
+func TestInvoiceRepository_CreateMany(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewInvoiceRepository(db)
+	ctx := tenanttest.Context()
+
+	// Create test invoices
+	dueAt := time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)
+	invoices := []Invoice{
+		{UserID: 1, Label: "First Invoice", Amount: 1000, DueAt: dueAt},
+		{UserID: 2, Label: "Second Invoice", Amount: 2000, DueAt: dueAt},
+	}
+
+	// Mock the expected batch, the rows are returned out of order
+	mock.ExpectQuery(expectedCreateManyQuery).
+		WithArgs(
+			"acme",
+			pq.Array([]int64{1, 2}),
+			pq.Array([]string{"First Invoice", "Second Invoice"}),
+			pq.Array([]int64{1000, 2000}),
+			pq.Array([]string{"2023-07-31T00:00:00Z", "2023-07-31T00:00:00Z"}),
+		).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number"}).AddRow(12, 8).AddRow(11, 7))
+
+	// Call the CreateMany method
+	issued, err := repo.CreateMany(ctx, invoices)
+	require.NoError(t, err)
+	assert.Equal(t, []Issued{{ID: 11, Number: 7}, {ID: 12, Number: 8}}, issued)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestInvoiceRepository_CreateMany_Failure(t *testing.T) {
+	invoice := Invoice{UserID: 1, Label: "Test Invoice", Amount: 1000, DueAt: time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC)}
+
+	tests := map[string]struct {
+		expect  func(query *sqlmock.ExpectedQuery)
+		wantErr error
+	}{
+		"query failed": {
+			expect:  func(query *sqlmock.ExpectedQuery) { query.WillReturnError(sql.ErrConnDone) },
+			wantErr: sql.ErrConnDone,
+		},
+		"tenant not found": {
+			expect:  func(query *sqlmock.ExpectedQuery) { query.WillReturnRows(sqlmock.NewRows([]string{"id", "number"})) },
+			wantErr: sql.ErrNoRows,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Create a new mock database connection
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer db.Close()
+
+			tt.expect(mock.ExpectQuery(expectedCreateManyQuery).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()))
+
+			// Call the CreateMany method
+			_, err = NewInvoiceRepository(db).CreateMany(tenanttest.Context(), []Invoice{invoice})
+			require.ErrorIs(t, err, tt.wantErr)
+
+			// Ensure all expectations were met
+			err = mock.ExpectationsWereMet()
+			require.NoError(t, err)
+		})
+	}
+}
+
 func TestInvoiceRepository_GetByID(t *testing.T) {
 	// Create a new mock database connection
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -48,22 +140,25 @@ func TestInvoiceRepository_GetByID(t *testing.T) {
 	defer db.Close()
 
 	repo := NewInvoiceRepository(db)
-	ctx := context.Background()
+	ctx := tenanttest.Context()
 
 	// Create a test invoice
 	invoice := &Invoice{
-		ID:     1,
-		UserID: 1,
-		Status: "pending",
-		Label:  "Test Invoice",
-		Amount: 1000,
+		ID:        1,
+		Number:    7,
+		UserID:    1,
+		Status:    "pending",
+		Label:     "Test Invoice",
+		Amount:    1000,
+		CreatedAt: time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC),
+		DueAt:     time.Date(2023, 7, 31, 0, 0, 0, 0, time.UTC),
 	}
 
 	// Mock the expected query and result
-	mock.ExpectQuery("SELECT id, user_id, status, label, amount FROM jump.public.invoices WHERE id = $1").
-		WithArgs(invoice.ID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "label", "amount"}).
-			AddRow(invoice.ID, invoice.UserID, invoice.Status, invoice.Label, invoice.Amount))
+	mock.ExpectQuery("SELECT id, number, user_id, status, label, amount, created_at, due_at FROM jump.public.invoices WHERE id = $1 AND tenant_id = $2 AND ($3 = 0 OR user_id = $3)").
+		WithArgs(invoice.ID, "acme", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number", "user_id", "status", "label", "amount", "created_at", "due_at"}).
+			AddRow(invoice.ID, invoice.Number, invoice.UserID, invoice.Status, invoice.Label, invoice.Amount, invoice.CreatedAt, invoice.DueAt))
 
 	// Call the GetByID method
 	result, err := repo.GetByID(ctx, invoice.ID)
@@ -74,3 +169,38 @@ func TestInvoiceRepository_GetByID(t *testing.T) {
 	err = mock.ExpectationsWereMet()
 	require.NoError(t, err)
 }
+
+func TestInvoiceRepository_Export(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewInvoiceRepository(db)
+	ctx := tenanttest.Context()
+
+	from := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC)
+	dueAt := time.Date(2023, 8, 2, 0, 0, 0, 0, time.UTC)
+
+	// Mock the expected query and result
+	mock.ExpectQuery("SELECT id, number, user_id, status, label, amount, created_at, due_at FROM jump.public.invoices WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3 ORDER BY id").
+		WithArgs("acme", from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number", "user_id", "status", "label", "amount", "created_at", "due_at"}).
+			AddRow(1, 1, 1, "pending", "First Invoice", 1000, createdAt, dueAt).
+			AddRow(2, 2, 1, "paid", "Second Invoice", 2000, createdAt, dueAt))
+
+	// Call the Export method
+	var ids []int64
+	err = repo.Export(ctx, from, to, func(invoice *Invoice) error {
+		ids = append(ids, invoice.ID)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, ids)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
@@ -0,0 +1,30 @@
+#!/usr/bin/env bash
+# Fetches the official XSDs the UBL and CII exports are validated against by the tests of the invoice package:
+#   ubl-2.1   the UBL 2.1 schemas published by OASIS
+#   cii-d16b  the UN/CEFACT CII D16B schemas, as distributed with the EN 16931 validation artefacts
+# Commit the result, the tests then validate against them on every run.
+set -euo pipefail
+
+UBL_URL=${UBL_URL:-https://docs.oasis-open.org/ubl/os-UBL-2.1/UBL-2.1.zip}
+EN16931_URL=${EN16931_URL:-https://github.com/ConnectingEurope/eInvoicing-EN16931/archive/refs/heads/master.tar.gz}
+
+dir=$(cd "$(dirname "$0")" && pwd)
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+curl -fsSL -o "$tmp/ubl.zip" "$UBL_URL"
+unzip -q "$tmp/ubl.zip" -d "$tmp/ubl"
+invoice=$(find "$tmp/ubl" -path '*/xsd/maindoc/UBL-Invoice-2.1.xsd' | head -n 1)
+[ -n "$invoice" ] || { echo "UBL-Invoice-2.1.xsd not found in $UBL_URL" >&2; exit 1; }
+rm -rf "$dir/ubl-2.1"
+cp -R "$(dirname "$(dirname "$invoice")")" "$dir/ubl-2.1"
+
+mkdir "$tmp/en16931"
+curl -fsSL "$EN16931_URL" | tar -xz -C "$tmp/en16931"
+cii=$(find "$tmp/en16931" -name 'CrossIndustryInvoice_100pD16B.xsd' | head -n 1)
+[ -n "$cii" ] || { echo "CrossIndustryInvoice_100pD16B.xsd not found in $EN16931_URL" >&2; exit 1; }
+rm -rf "$dir/cii-d16b"
+mkdir "$dir/cii-d16b"
+cp "$(dirname "$cii")"/*.xsd "$dir/cii-d16b/"
+
+echo "schemas written to $dir"
@@ -6,70 +6,44 @@ import (
 	"fmt"
 	"net/http"
 
-	"github.com/emilien-puget/invoice_microservice/money"
-	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/emilien-puget/invoice_microservice/problem"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
 type DoTransactionHandler struct {
-	invoiceRepository interface {
-		GetByID(ctx context.Context, id int64) (*Invoice, error)
-		MarkAsPaid(ctx context.Context, id int64) error
+	payments interface {
+		Apply(ctx context.Context, payload TransactionPayload) error
 	}
-	userRepository interface {
-		ModifyBalance(ctx context.Context, userID int64, amount money.Money) error
-	}
-	validator *validator.Validate
-}
-
-func NewDoTransactionHandler(invoiceRepository *Repository, userRepository *user.Repository, validate *validator.Validate) *DoTransactionHandler {
-	return &DoTransactionHandler{invoiceRepository: invoiceRepository, userRepository: userRepository, validator: validate}
 }
 
-type TransactionPayload struct {
-	InvoiceID int64   `json:"invoice_id" validate:"required"`
-	Amount    float64 `json:"amount" validate:"required"`
-	Reference string  `json:"reference" validate:"required"`
+func NewDoTransactionHandler(payments *Payments) *DoTransactionHandler {
+	return &DoTransactionHandler{payments: payments}
 }
 
 func (d DoTransactionHandler) Handle(c echo.Context) error {
 	ctx := c.Request().Context()
 
-	// Parse and validate the JSON payload
+	// Parse the JSON payload, it is validated by the payments
 	var payload TransactionPayload
 	if err := c.Bind(&payload); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request payload")
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request payload")
 	}
 
-	if err := d.validator.Struct(payload); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
-
-	// Fetch the invoice by ID
-	invoice, err := d.invoiceRepository.GetByID(ctx, payload.InvoiceID)
+	err := d.payments.Apply(ctx, payload)
 	if err != nil {
-		if errors.Is(err, ErrInvoiceNotFound) {
-			return echo.NewHTTPError(http.StatusNotFound, "invoice not found")
+		var validationErrors validator.ValidationErrors
+		switch {
+		case errors.As(err, &validationErrors):
+			return problem.Validation(validationErrors)
+		case errors.Is(err, ErrInvoiceNotFound):
+			return problem.New(http.StatusNotFound, problem.CodeInvoiceNotFound, "invoice not found")
+		case errors.Is(err, ErrInvalidAmount):
+			return problem.New(http.StatusBadRequest, problem.CodeInvalidAmount, "Invalid amount")
+		case errors.Is(err, ErrInvoiceAlreadyPaid):
+			return problem.New(http.StatusUnprocessableEntity, problem.CodeInvoiceAlreadyPaid, "invoice already paid")
 		}
-		return fmt.Errorf("invoiceRepository.GetByID: %w", err)
-	}
-
-	if invoice.Amount != money.NewMoneyFromFloat(payload.Amount) {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid amount")
-	}
-	if invoice.Status == "paid" {
-		return echo.NewHTTPError(http.StatusUnprocessableEntity, "invoice already paid")
-	}
-
-	err = d.userRepository.ModifyBalance(ctx, invoice.UserID, invoice.Amount)
-	if err != nil {
-		return fmt.Errorf("userRepository.ModifyBalance: %w", err)
-	}
-
-	err = d.invoiceRepository.MarkAsPaid(ctx, invoice.ID)
-	if err != nil {
-		return fmt.Errorf("invoiceRepository.MarkAsPaid: %w", err)
+		return fmt.Errorf("payments.Apply: %w", err)
 	}
 
 	return c.NoContent(http.StatusNoContent)
@@ -0,0 +1,123 @@
+package invoice
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/outbox"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant/tenanttest"
+	"github.com/emilien-puget/invoice_microservice/tracing/tracingtest"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+)
+
+var errConnectionLost = errors.New("connection lost")
+
+func TestDoTransactionHandler_Atomic(t *testing.T) {
+	const (
+		getQuery         = "SELECT id, number, user_id, status, label, amount, created_at, due_at FROM jump.public.invoices WHERE id = $1 AND tenant_id = $2 AND ($3 = 0 OR user_id = $3)"
+		markQuery        = "UPDATE jump.public.invoices SET status = 'paid' WHERE id = $1 AND tenant_id = $2 AND status <> 'paid'"
+		balanceQuery     = "UPDATE jump.public.users SET balance = balance + $1 WHERE id = $2 AND tenant_id = $3"
+		transactionQuery = "INSERT INTO jump.public.transactions (tenant_id, invoice_id, user_id, amount, reference) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+		outboxQuery      = "INSERT INTO jump.public.outbox (tenant_id, aggregate_type, aggregate_id, event_type, payload) VALUES ($1, $2, $3, $4, $5)"
+	)
+	columns := []string{"id", "number", "user_id", "status", "label", "amount", "created_at", "due_at"}
+	createdAt := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	for name, test := range map[string]struct {
+		expect func(mock sqlmock.Sqlmock)
+		status int
+		spans  []string
+	}{
+		"committed with its event": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(markQuery).WithArgs(10, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectPrepare(balanceQuery)
+				mock.ExpectExec(balanceQuery).WithArgs(int64(1000), 1, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(transactionQuery).WithArgs("acme", 10, 1, int64(1000), "bank-42").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				mock.ExpectExec(outboxQuery).WithArgs("acme", AggregateType, "10", "invoice.paid", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			status: http.StatusNoContent,
+			spans: []string{
+				"invoice.Repository.GetByID", "invoice.Repository.MarkAsPaid", "user.Repository.ModifyBalance",
+				"invoice.TransactionRepository.Create", "outbox.Repository.Append", "/transaction",
+			},
+		},
+		"rolled back when the balance fails": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(markQuery).WithArgs(10, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectPrepare(balanceQuery)
+				mock.ExpectExec(balanceQuery).WithArgs(int64(1000), 1, "acme").WillReturnError(errConnectionLost)
+				mock.ExpectRollback()
+			},
+			status: http.StatusInternalServerError,
+			spans:  []string{"invoice.Repository.GetByID", "invoice.Repository.MarkAsPaid", "user.Repository.ModifyBalance", "/transaction"},
+		},
+		"paid concurrently": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(markQuery).WithArgs(10, "acme").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			status: http.StatusUnprocessableEntity,
+			spans:  []string{"invoice.Repository.GetByID", "invoice.Repository.MarkAsPaid", "/transaction"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Create a new mock database connection
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer db.Close()
+
+			handler := NewDoTransactionHandler(NewPayments(NewInvoiceRepository(db), user.NewUserRepository(db), NewTransactionRepository(db),
+				outbox.NewOutboxRepository(db), storage.NewTransactor(db), validator.New(), NewMetrics(prometheus.NewRegistry(), "test", Billing{Currency: "EUR"})))
+
+			// The invoice is read outside of the transaction, the changes are all made within it
+			mock.ExpectQuery(getQuery).WithArgs(10, "acme", 0).
+				WillReturnRows(sqlmock.NewRows(columns).AddRow(10, 3, 1, "pending", "Consulting", 1000, createdAt, createdAt))
+			test.expect(mock)
+			recorder := tracingtest.Record(t)
+
+			e := echo.New()
+			e.HTTPErrorHandler = problem.ErrorHandler
+			e.Use(otelecho.Middleware("invoice_microservice"))
+			e.POST("/transaction", handler.Handle,
+				auth.Middleware(principalAuthenticator(auth.Principal{Role: auth.RolePaymentProcessor, TenantID: "acme"})), tenanttest.Middleware)
+			req := httptest.NewRequest(http.MethodPost, "/transaction", strings.NewReader(`{"invoice_id":10,"amount":10,"reference":"bank-42"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.status, rec.Code)
+			// The spans of the repositories join the trace of the caller
+			var spans []string
+			for _, span := range recorder.Ended() {
+				spans = append(spans, span.Name())
+				assert.Equal(t, traceID, span.SpanContext().TraceID().String())
+			}
+			assert.Equal(t, test.spans, spans)
+			// Ensure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
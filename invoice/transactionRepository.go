@@ -0,0 +1,121 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+)
+
+// Transaction is a payment applied to an invoice.
+type Transaction struct {
+	ID        int64
+	InvoiceID int64
+	UserID    int64
+	Amount    money.Money
+	Reference string
+	CreatedAt time.Time
+}
+
+type TransactionRepository struct {
+	db *sql.DB
+}
+
+func NewTransactionRepository(db *sql.DB) *TransactionRepository {
+	return &TransactionRepository{db: db}
+}
+
+func (r *TransactionRepository) Create(ctx context.Context, transaction Transaction) (_ int64, err error) {
+	ctx, span := storage.StartSpan(ctx, "invoice.TransactionRepository.Create", storage.OperationInsert, "transactions")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO jump.public.transactions (tenant_id, invoice_id, user_id, amount, reference)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	var transactionID int64
+	row := storage.Conn(ctx, r.db).QueryRowContext(ctx, storage.Dialect(r.db, query), tenantID, transaction.InvoiceID, transaction.UserID, transaction.Amount, transaction.Reference)
+	if err := row.Scan(&transactionID); err != nil {
+		return 0, fmt.Errorf("failed to create transaction: %w", err)
+	}
+
+	return transactionID, nil
+}
+
+// Exists reports whether a payment with reference was recorded for the invoice, so that a payment delivered twice is
+// told apart from a second payment of the same invoice.
+func (r *TransactionRepository) Exists(ctx context.Context, invoiceID int64, reference string) (_ bool, err error) {
+	ctx, span := storage.StartSpan(ctx, "invoice.TransactionRepository.Exists", storage.OperationSelect, "transactions")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM jump.public.transactions
+			WHERE tenant_id = $1 AND invoice_id = $2 AND reference = $3
+		)
+	`
+
+	var exists bool
+	if err := storage.Conn(ctx, r.db).QueryRowContext(ctx, storage.Dialect(r.db, query), tenantID, invoiceID, reference).Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check transaction: %w", err)
+	}
+
+	return exists, nil
+}
+
+// Export calls fn for every transaction created in [from, to), rows are handed over as they are read.
+func (r *TransactionRepository) Export(ctx context.Context, from, to time.Time, fn func(*Transaction) error) (err error) {
+	ctx, span := storage.StartSpan(ctx, "invoice.TransactionRepository.Export", storage.OperationSelect, "transactions")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT id, invoice_id, user_id, amount, reference, created_at
+		FROM jump.public.transactions
+		WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY id
+	`
+
+	rows, err := storage.Conn(ctx, r.db).QueryContext(ctx, storage.Dialect(r.db, query), tenantID, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to query transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var transaction Transaction
+	for rows.Next() {
+		err := rows.Scan(&transaction.ID, &transaction.InvoiceID, &transaction.UserID, &transaction.Amount, &transaction.Reference, &transaction.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		if err := fn(&transaction); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read transactions: %w", err)
+	}
+
+	return nil
+}
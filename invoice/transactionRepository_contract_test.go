@@ -0,0 +1,50 @@
+package invoice
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/storage/storagetest"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionRepository_Contract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, ctx context.Context, db *sql.DB) {
+		conn := storage.Conn(ctx, db)
+		_, err := conn.ExecContext(ctx, `INSERT INTO tenants (id, name) VALUES ('storagetest', 'Storage test')`)
+		require.NoError(t, err)
+		var userID int64
+		err = conn.QueryRowContext(ctx, `INSERT INTO users (first_name, last_name, tenant_id) VALUES ('Ada', 'Lovelace', 'storagetest') RETURNING id`).Scan(&userID)
+		require.NoError(t, err)
+		ctx = tenant.NewContext(ctx, &tenant.Tenant{ID: "storagetest"})
+		issued, err := NewInvoiceRepository(db).Create(ctx, Invoice{UserID: userID, Label: "First", Amount: 1000, DueAt: time.Now()})
+		require.NoError(t, err)
+		repo := NewTransactionRepository(db)
+
+		id, err := repo.Create(ctx, Transaction{InvoiceID: issued.ID, UserID: userID, Amount: 1000, Reference: "PAY-1"})
+		require.NoError(t, err)
+
+		exists, err := repo.Exists(ctx, issued.ID, "PAY-1")
+		require.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = repo.Exists(ctx, issued.ID, "PAY-2")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		var exported []Transaction
+		err = repo.Export(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), func(transaction *Transaction) error {
+			exported = append(exported, *transaction)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, exported, 1)
+		assert.False(t, exported[0].CreatedAt.IsZero())
+		exported[0].CreatedAt = time.Time{}
+		assert.Equal(t, Transaction{ID: id, InvoiceID: issued.ID, UserID: userID, Amount: 1000, Reference: "PAY-1"}, exported[0])
+	})
+}
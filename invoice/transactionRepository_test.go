@@ -0,0 +1,76 @@
+package invoice
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/tenant/tenanttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionRepository_Create(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewTransactionRepository(db)
+	ctx := tenanttest.Context()
+
+	// Create a test transaction
+	transaction := Transaction{
+		InvoiceID: 1,
+		UserID:    2,
+		Amount:    1000,
+		Reference: "REF-1",
+	}
+
+	// Mock the expected query and result
+	mock.ExpectQuery("INSERT INTO jump.public.transactions (tenant_id, invoice_id, user_id, amount, reference) VALUES ($1, $2, $3, $4, $5) RETURNING id").
+		WithArgs("acme", transaction.InvoiceID, transaction.UserID, transaction.Amount, transaction.Reference).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	// Call the Create method
+	transactionID, err := repo.Create(ctx, transaction)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), transactionID)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestTransactionRepository_Export(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewTransactionRepository(db)
+	ctx := tenanttest.Context()
+
+	from := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC)
+
+	// Mock the expected query and result
+	mock.ExpectQuery("SELECT id, invoice_id, user_id, amount, reference, created_at FROM jump.public.transactions WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3 ORDER BY id").
+		WithArgs("acme", from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "invoice_id", "user_id", "amount", "reference", "created_at"}).
+			AddRow(1, 1, 2, 1000, "REF-1", createdAt))
+
+	// Call the Export method
+	var transactions []Transaction
+	err = repo.Export(ctx, from, to, func(transaction *Transaction) error {
+		transactions = append(transactions, *transaction)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []Transaction{{ID: 1, InvoiceID: 1, UserID: 2, Amount: 1000, Reference: "REF-1", CreatedAt: createdAt}}, transactions)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
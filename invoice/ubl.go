@@ -0,0 +1,213 @@
+package invoice
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+)
+
+// en16931 is the specification identifier shared by the UBL and CII exports.
+const en16931 = "urn:cen.eu:en16931:2017"
+
+const (
+	commercialInvoiceTypeCode = "380"
+	creditTransferMeansCode   = "30"
+	unitCodePiece             = "C62"
+	vatScheme                 = "VAT"
+)
+
+// taxCategory returns the UNCL5305 code of the tax category matching rate.
+func taxCategory(rate float64) string {
+	if rate == 0 {
+		return "Z"
+	}
+	return "S"
+}
+
+func formatPercent(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+type ublInvoice struct {
+	XMLName                 xml.Name              `xml:"Invoice"`
+	Xmlns                   string                `xml:"xmlns,attr"`
+	XmlnsCac                string                `xml:"xmlns:cac,attr"`
+	XmlnsCbc                string                `xml:"xmlns:cbc,attr"`
+	CustomizationID         string                `xml:"cbc:CustomizationID"`
+	ID                      string                `xml:"cbc:ID"`
+	IssueDate               string                `xml:"cbc:IssueDate"`
+	DueDate                 string                `xml:"cbc:DueDate"`
+	InvoiceTypeCode         string                `xml:"cbc:InvoiceTypeCode"`
+	DocumentCurrencyCode    string                `xml:"cbc:DocumentCurrencyCode"`
+	AccountingSupplierParty ublPartyWrapper       `xml:"cac:AccountingSupplierParty"`
+	AccountingCustomerParty ublPartyWrapper       `xml:"cac:AccountingCustomerParty"`
+	PaymentMeans            *ublPaymentMeans      `xml:"cac:PaymentMeans,omitempty"`
+	TaxTotal                ublTaxTotal           `xml:"cac:TaxTotal"`
+	LegalMonetaryTotal      ublLegalMonetaryTotal `xml:"cac:LegalMonetaryTotal"`
+	InvoiceLine             ublInvoiceLine        `xml:"cac:InvoiceLine"`
+}
+
+type ublPartyWrapper struct {
+	Party ublParty `xml:"cac:Party"`
+}
+
+type ublParty struct {
+	PostalAddress    ublAddress         `xml:"cac:PostalAddress"`
+	PartyTaxScheme   *ublPartyTaxScheme `xml:"cac:PartyTaxScheme,omitempty"`
+	PartyLegalEntity ublLegalEntity     `xml:"cac:PartyLegalEntity"`
+}
+
+type ublAddress struct {
+	StreetName string `xml:"cbc:StreetName,omitempty"`
+	CityName   string `xml:"cbc:CityName,omitempty"`
+	PostalZone string `xml:"cbc:PostalZone,omitempty"`
+	Country    string `xml:"cac:Country>cbc:IdentificationCode"`
+}
+
+type ublPartyTaxScheme struct {
+	CompanyID string `xml:"cbc:CompanyID"`
+	TaxScheme string `xml:"cac:TaxScheme>cbc:ID"`
+}
+
+type ublLegalEntity struct {
+	RegistrationName string `xml:"cbc:RegistrationName"`
+}
+
+type ublPaymentMeans struct {
+	PaymentMeansCode      string `xml:"cbc:PaymentMeansCode"`
+	PaymentID             string `xml:"cbc:PaymentID"`
+	PayeeFinancialAccount string `xml:"cac:PayeeFinancialAccount>cbc:ID"`
+}
+
+type ublAmount struct {
+	CurrencyID string `xml:"currencyID,attr"`
+	Value      string `xml:",chardata"`
+}
+
+type ublTaxCategory struct {
+	ID        string `xml:"cbc:ID"`
+	Percent   string `xml:"cbc:Percent"`
+	TaxScheme string `xml:"cac:TaxScheme>cbc:ID"`
+}
+
+type ublTaxTotal struct {
+	TaxAmount   ublAmount      `xml:"cbc:TaxAmount"`
+	TaxSubtotal ublTaxSubtotal `xml:"cac:TaxSubtotal"`
+}
+
+type ublTaxSubtotal struct {
+	TaxableAmount ublAmount      `xml:"cbc:TaxableAmount"`
+	TaxAmount     ublAmount      `xml:"cbc:TaxAmount"`
+	TaxCategory   ublTaxCategory `xml:"cac:TaxCategory"`
+}
+
+type ublLegalMonetaryTotal struct {
+	LineExtensionAmount ublAmount `xml:"cbc:LineExtensionAmount"`
+	TaxExclusiveAmount  ublAmount `xml:"cbc:TaxExclusiveAmount"`
+	TaxInclusiveAmount  ublAmount `xml:"cbc:TaxInclusiveAmount"`
+	PayableAmount       ublAmount `xml:"cbc:PayableAmount"`
+}
+
+type ublInvoiceLine struct {
+	ID                  string      `xml:"cbc:ID"`
+	InvoicedQuantity    ublQuantity `xml:"cbc:InvoicedQuantity"`
+	LineExtensionAmount ublAmount   `xml:"cbc:LineExtensionAmount"`
+	Item                ublItem     `xml:"cac:Item"`
+	Price               ublAmount   `xml:"cac:Price>cbc:PriceAmount"`
+}
+
+type ublQuantity struct {
+	UnitCode string `xml:"unitCode,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type ublItem struct {
+	Name                  string         `xml:"cbc:Name"`
+	ClassifiedTaxCategory ublTaxCategory `xml:"cac:ClassifiedTaxCategory"`
+}
+
+func newUBLParty(p Party) ublParty {
+	party := ublParty{
+		PostalAddress: ublAddress{
+			StreetName: p.Street,
+			CityName:   p.City,
+			PostalZone: p.PostalCode,
+			Country:    p.CountryCode,
+		},
+		PartyLegalEntity: ublLegalEntity{RegistrationName: p.Name},
+	}
+	if p.VatID != "" {
+		party.PartyTaxScheme = &ublPartyTaxScheme{CompanyID: p.VatID, TaxScheme: vatScheme}
+	}
+	return party
+}
+
+// WriteUBL writes doc as an OASIS UBL 2.1 invoice following the EN 16931 core invoice model.
+func WriteUBL(w io.Writer, doc Document) error {
+	amount := func(m money.Money) ublAmount {
+		return ublAmount{CurrencyID: doc.Currency, Value: m.String()}
+	}
+	category := ublTaxCategory{ID: taxCategory(doc.TaxRate), Percent: formatPercent(doc.TaxRate), TaxScheme: vatScheme}
+
+	invoice := ublInvoice{
+		Xmlns:                   "urn:oasis:names:specification:ubl:schema:xsd:Invoice-2",
+		XmlnsCac:                "urn:oasis:names:specification:ubl:schema:xsd:CommonAggregateComponents-2",
+		XmlnsCbc:                "urn:oasis:names:specification:ubl:schema:xsd:CommonBasicComponents-2",
+		CustomizationID:         en16931,
+		ID:                      doc.Number,
+		IssueDate:               formatDate(doc.Invoice.CreatedAt),
+		DueDate:                 formatDate(doc.Invoice.DueAt),
+		InvoiceTypeCode:         commercialInvoiceTypeCode,
+		DocumentCurrencyCode:    doc.Currency,
+		AccountingSupplierParty: ublPartyWrapper{Party: newUBLParty(doc.Seller)},
+		AccountingCustomerParty: ublPartyWrapper{Party: newUBLParty(doc.Buyer)},
+		TaxTotal: ublTaxTotal{
+			TaxAmount: amount(doc.Tax),
+			TaxSubtotal: ublTaxSubtotal{
+				TaxableAmount: amount(doc.Net),
+				TaxAmount:     amount(doc.Tax),
+				TaxCategory:   category,
+			},
+		},
+		LegalMonetaryTotal: ublLegalMonetaryTotal{
+			LineExtensionAmount: amount(doc.Net),
+			TaxExclusiveAmount:  amount(doc.Net),
+			TaxInclusiveAmount:  amount(doc.Invoice.Amount),
+			PayableAmount:       amount(doc.Invoice.Amount),
+		},
+		InvoiceLine: ublInvoiceLine{
+			ID:                  "1",
+			InvoicedQuantity:    ublQuantity{UnitCode: unitCodePiece, Value: "1"},
+			LineExtensionAmount: amount(doc.Net),
+			Item:                ublItem{Name: doc.Invoice.Label, ClassifiedTaxCategory: category},
+			Price:               amount(doc.Net),
+		},
+	}
+	if doc.SellerIBAN != "" {
+		invoice.PaymentMeans = &ublPaymentMeans{
+			PaymentMeansCode:      creditTransferMeansCode,
+			PaymentID:             doc.Number,
+			PayeeFinancialAccount: doc.SellerIBAN,
+		}
+	}
+
+	return writeXML(w, invoice)
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("write trailing newline: %w", err)
+	}
+	return nil
+}
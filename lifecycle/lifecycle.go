@@ -0,0 +1,190 @@
+// Package lifecycle starts the components of the application in order and stops them in reverse order.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/logging"
+)
+
+var ErrPanic = errors.New("panic")
+
+// Component is a part of the application, every function is optional.
+type Component struct {
+	Name string
+	// Start prepares the component, e.g. binds its listener. A component is only started once the previous ones are.
+	Start func(ctx context.Context) error
+	// Run works in its own goroutine until ctx is done or Stop is called, it returns nil then. An error returned
+	// before makes the manager stop the application.
+	Run func(ctx context.Context) error
+	// Stop ends the work of the component, waiting for the work in flight until ctx is done.
+	Stop func(ctx context.Context) error
+}
+
+// Manager runs the components added to it.
+type Manager struct {
+	timeout    time.Duration
+	components []Component
+}
+
+// NewManager returns a manager giving every component timeout to stop.
+func NewManager(timeout time.Duration) *Manager {
+	return &Manager{timeout: timeout}
+}
+
+// Add appends components, they are started after the ones already added and stopped before them.
+func (m *Manager) Add(components ...Component) {
+	m.components = append(m.components, components...)
+}
+
+type running struct {
+	component Component
+	cancel    context.CancelFunc
+	stopping  atomic.Bool
+	// done is closed once Run returned, err is what it returned.
+	done chan struct{}
+	err  error
+}
+
+// Run starts the components, waits until ctx is done or a component fails, then stops the components started in
+// reverse order. The error returned names the component that failed to start or run and every component that failed
+// to stop.
+func (m *Manager) Run(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
+	failures := make(chan error, len(m.components))
+
+	var started []*running
+	var cause error
+	for _, component := range m.components {
+		if component.Start != nil {
+			if err := safely(func() error { return component.Start(ctx) }); err != nil {
+				cause = fmt.Errorf("start %s: %w", component.Name, err)
+				break
+			}
+		}
+		started = append(started, m.run(ctx, component, failures))
+		logger.Info("component started", slog.String("component", component.Name))
+	}
+
+	if cause == nil {
+		select {
+		case <-ctx.Done():
+		case cause = <-failures:
+		}
+	}
+	if cause != nil {
+		logger.Error("stopping on failure", slog.Any("error", cause))
+	}
+
+	errs := []error{cause}
+	for i := len(started) - 1; i >= 0; i-- {
+		if err := m.stop(ctx, started[i]); err != nil {
+			logger.Error("component not stopped", slog.String("component", started[i].component.Name), slog.Any("error", err))
+			errs = append(errs, err)
+			continue
+		}
+		logger.Info("component stopped", slog.String("component", started[i].component.Name))
+	}
+	return errors.Join(errs...)
+}
+
+// run calls the Run of component in its own goroutine. Its context keeps the values of ctx but is only cancelled when
+// the component is stopped, so that the components stop in order.
+func (m *Manager) run(ctx context.Context, component Component, failures chan<- error) *running {
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	r := &running{component: component, cancel: cancel, done: make(chan struct{})}
+	if component.Run == nil {
+		close(r.done)
+		return r
+	}
+
+	go func() {
+		defer close(r.done)
+		r.err = safely(func() error { return component.Run(runCtx) })
+		if r.err != nil && !r.stopping.Load() {
+			failures <- fmt.Errorf("run %s: %w", component.Name, r.err)
+		}
+	}()
+	return r
+}
+
+// stop calls the Stop of the component then waits for its Run to return, both within the timeout.
+func (m *Manager) stop(ctx context.Context, r *running) error {
+	stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), m.timeout)
+	defer cancel()
+	r.stopping.Store(true)
+
+	var errs []error
+	if r.component.Stop != nil {
+		if err := safely(func() error { return r.component.Stop(stopCtx) }); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.cancel()
+
+	select {
+	case <-r.done:
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+	case <-stopCtx.Done():
+		errs = append(errs, fmt.Errorf("still running after %s: %w", m.timeout, stopCtx.Err()))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("stop %s: %w", r.component.Name, errors.Join(errs...))
+	}
+	return nil
+}
+
+// safely turns a panic of fn into an error, so that the other components are still stopped.
+func safely(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v\n%s", ErrPanic, r, debug.Stack())
+		}
+	}()
+	return fn()
+}
+
+// Worker returns a component calling run until it is stopped, run returns once ctx is done.
+func Worker(name string, run func(ctx context.Context)) Component {
+	return Component{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			run(ctx)
+			return nil
+		},
+	}
+}
+
+// Server returns a component serving on addr, the listener is bound when the component starts so that a port in use
+// fails the start. Serve may return http.ErrServerClosed once shut down.
+func Server(name, addr string, serve func(lis net.Listener) error, shutdown func(ctx context.Context) error) Component {
+	var lis net.Listener
+	return Component{
+		Name: name,
+		Start: func(ctx context.Context) error {
+			var err error
+			lis, err = (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+			if err != nil {
+				return fmt.Errorf("listen: %w", err)
+			}
+			return nil
+		},
+		Run: func(context.Context) error {
+			if err := serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+		Stop: shutdown,
+	}
+}
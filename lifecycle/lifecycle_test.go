@@ -0,0 +1,173 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errBoom = errors.New("boom")
+
+// journal records the calls made to the components, in order.
+type journal struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (j *journal) record(call string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.calls = append(j.calls, call)
+}
+
+func (j *journal) get() []string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]string(nil), j.calls...)
+}
+
+func (j *journal) component(name string) Component {
+	return Component{
+		Name: name,
+		Start: func(context.Context) error {
+			j.record("start " + name)
+			return nil
+		},
+		Stop: func(context.Context) error {
+			j.record("stop " + name)
+			return nil
+		},
+	}
+}
+
+func TestManager_Run(t *testing.T) {
+	tests := map[string]struct {
+		components func(j *journal) []Component
+		cancel     bool
+		wantCalls  []string
+		wantErr    []string
+	}{
+		"stopped in reverse order": {
+			components: func(j *journal) []Component {
+				return []Component{j.component("a"), j.component("b"), j.component("c")}
+			},
+			cancel:    true,
+			wantCalls: []string{"start a", "start b", "start c", "stop c", "stop b", "stop a"},
+		},
+		"start failure stops the started ones": {
+			components: func(j *journal) []Component {
+				failing := j.component("b")
+				failing.Start = func(context.Context) error { return errBoom }
+				return []Component{j.component("a"), failing, j.component("c")}
+			},
+			wantCalls: []string{"start a", "stop a"},
+			wantErr:   []string{"start b: boom"},
+		},
+		"run failure stops everything": {
+			components: func(j *journal) []Component {
+				failing := j.component("b")
+				failing.Run = func(context.Context) error { return errBoom }
+				return []Component{j.component("a"), failing}
+			},
+			wantCalls: []string{"start a", "start b", "stop b", "stop a"},
+			wantErr:   []string{"run b: boom", "stop b: boom"},
+		},
+		"stop failure names the component": {
+			components: func(j *journal) []Component {
+				failing := j.component("b")
+				failing.Stop = func(context.Context) error {
+					j.record("stop b")
+					return errBoom
+				}
+				return []Component{j.component("a"), failing}
+			},
+			cancel:    true,
+			wantCalls: []string{"start a", "start b", "stop b", "stop a"},
+			wantErr:   []string{"stop b: boom"},
+		},
+		"stop timeout names the component": {
+			components: func(j *journal) []Component {
+				stuck := j.component("b")
+				stuck.Run = func(context.Context) error {
+					select {}
+				}
+				return []Component{j.component("a"), stuck}
+			},
+			cancel:    true,
+			wantCalls: []string{"start a", "start b", "stop b", "stop a"},
+			wantErr:   []string{"stop b: still running after 50ms"},
+		},
+		"panic recovered": {
+			components: func(j *journal) []Component {
+				panicking := j.component("b")
+				panicking.Run = func(context.Context) error { panic("bug") }
+				return []Component{j.component("a"), panicking}
+			},
+			wantCalls: []string{"start a", "start b", "stop b", "stop a"},
+			wantErr:   []string{"run b: panic: bug"},
+		},
+		"run context cancelled once stopped": {
+			components: func(j *journal) []Component {
+				return []Component{j.component("a"), Worker("worker", func(ctx context.Context) {
+					<-ctx.Done()
+					j.record("worker done")
+				})}
+			},
+			cancel:    true,
+			wantCalls: []string{"start a", "worker done", "stop a"},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			j := &journal{}
+			m := NewManager(50 * time.Millisecond)
+			m.Add(tt.components(j)...)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				time.AfterFunc(20*time.Millisecond, cancel)
+			}
+
+			err := m.Run(ctx)
+			assert.Equal(t, tt.wantCalls, j.get())
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestServer(t *testing.T) {
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})}
+	component := Server("http", "127.0.0.1:0", server.Serve, server.Shutdown)
+
+	require.NoError(t, component.Start(context.Background()))
+	done := make(chan error)
+	go func() { done <- component.Run(context.Background()) }()
+	require.NoError(t, component.Stop(context.Background()))
+	assert.NoError(t, <-done, "http.ErrServerClosed is not a failure")
+}
+
+func TestServer_PortInUse(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+
+	component := Server("http", lis.Addr().String(), nil, nil)
+	assert.ErrorContains(t, component.Start(context.Background()), "listen")
+}
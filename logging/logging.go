@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Redacted replaces the values of the sensitive attributes.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are the parts of the keys whose values are never logged, the keys are lowercased and their hyphens
+// replaced by underscores before matching so that header names are covered.
+var sensitiveKeys = []string{"password", "secret", "token", "authorization", "api_key", "apikey", "cookie", "iban"}
+
+// New returns a logger writing JSON records of level and above to w, with the sensitive attributes redacted.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: redact}))
+}
+
+// ParseLevel parses debug, info, warn or error, in any case.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(s))
+	return level, err
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ReplaceAll(strings.ToLower(a.Key), "-", "_")
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+	return a
+}
+
+type loggerKey struct{}
+
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger of ctx, which carries the attributes of the request, the default logger when there
+// is none.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew_Redacts(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	logger.Info("connect", slog.String("user", "jump"), slog.String("password", "s3cr3t"),
+		slog.Group("request", slog.String("Authorization", "Bearer abc"), slog.String("X-Api-Key", "ik_abc")))
+	logger.Debug("not logged")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "jump", record["user"])
+	assert.Equal(t, Redacted, record["password"])
+	assert.Equal(t, map[string]any{"Authorization": Redacted, "X-Api-Key": Redacted}, record["request"])
+	assert.NotContains(t, buf.String(), "s3cr3t")
+	assert.Equal(t, 1, strings.Count(buf.String(), "\n"))
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("warn")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	for name, test := range map[string]struct {
+		requestID string
+		expected  func(t *testing.T, id string)
+	}{
+		"propagated": {
+			requestID: "req-1",
+			expected:  func(t *testing.T, id string) { assert.Equal(t, "req-1", id) },
+		},
+		"generated": {
+			expected: func(t *testing.T, id string) { assert.Len(t, id, 32) },
+		},
+		"too long": {
+			requestID: strings.Repeat("a", maxRequestIDLength+1),
+			expected:  func(t *testing.T, id string) { assert.Len(t, id, 32) },
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := echo.New()
+			e.Use(Middleware(New(&buf, slog.LevelInfo)))
+			e.GET("/invoices/:id", func(c echo.Context) error {
+				FromContext(c.Request().Context()).Info("handled")
+				return c.NoContent(http.StatusNoContent)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/invoices/10?token=abc", nil)
+			if test.requestID != "" {
+				req.Header.Set(echo.HeaderXRequestID, test.requestID)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(echo.HeaderXRequestID)
+			test.expected(t, id)
+
+			// Both the record of the handler and the one of the request carry the request ID
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			require.Len(t, lines, 2)
+			var handled, request map[string]any
+			require.NoError(t, json.Unmarshal([]byte(lines[0]), &handled))
+			require.NoError(t, json.Unmarshal([]byte(lines[1]), &request))
+			assert.Equal(t, id, handled["request_id"])
+			assert.Equal(t, id, request["request_id"])
+			assert.Equal(t, "/invoices/:id", request["route"])
+			assert.Equal(t, "/invoices/10", request["path"])
+			assert.Equal(t, float64(http.StatusNoContent), request["status"])
+		})
+	}
+}
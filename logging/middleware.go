@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxRequestIDLength bounds the request IDs accepted from the clients, longer ones are replaced.
+const maxRequestIDLength = 128
+
+// Middleware propagates the X-Request-ID header of the request, or a new ID, to the response and to the logger of
+// the request context, then logs the request once it is handled.
+func Middleware(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			id := RequestID(c.Request().Header.Get(echo.HeaderXRequestID))
+			c.Response().Header().Set(echo.HeaderXRequestID, id)
+			requestLogger := ForRequest(c.Request().Context(), logger, id)
+			ctx := NewContext(c.Request().Context(), requestLogger)
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			// The error is handled here so that the status logged is the one of the response
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			requestLogger.LogAttrs(ctx, level, "request",
+				slog.String("method", c.Request().Method),
+				slog.String("route", c.Path()),
+				slog.String("path", c.Request().URL.Path),
+				slog.Int("status", status),
+				slog.Duration("latency", time.Since(start)),
+				slog.Int64("bytes_out", c.Response().Size),
+				slog.String("remote_ip", c.RealIP()),
+			)
+			return nil
+		}
+	}
+}
+
+// ForRequest returns logger with the ID of the request and, when ctx carries a span, the IDs of the trace and of the
+// span so that the records can be found from the trace.
+func ForRequest(ctx context.Context, logger *slog.Logger, id string) *slog.Logger {
+	logger = logger.With(slog.String("request_id", id))
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		logger = logger.With(slog.String("trace_id", spanContext.TraceID().String()), slog.String("span_id", spanContext.SpanID().String()))
+	}
+	return logger
+}
+
+// RequestID returns id when it can be used as the ID of a request, a new ID otherwise.
+func RequestID(id string) string {
+	if id == "" || len(id) > maxRequestIDLength {
+		return NewRequestID()
+	}
+	return id
+}
+
+// NewRequestID returns a random ID for a request that came without one.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    id         SERIAL PRIMARY KEY,
+    first_name VARCHAR NOT NULL,
+    last_name  VARCHAR NOT NULL,
+    balance    BIGINT  NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS invoices
+(
+    id      SERIAL PRIMARY KEY,
+    user_id INT     NOT NULL REFERENCES users (id),
+    status  VARCHAR NOT NULL DEFAULT 'pending',
+    label   VARCHAR NOT NULL,
+    amount  BIGINT  NOT NULL
+);
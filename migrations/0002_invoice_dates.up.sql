@@ -0,0 +1,3 @@
+ALTER TABLE invoices
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    ADD COLUMN IF NOT EXISTS due_at     TIMESTAMPTZ NOT NULL DEFAULT now() + INTERVAL '30 days';
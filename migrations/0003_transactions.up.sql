@@ -0,0 +1,15 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+CREATE TABLE IF NOT EXISTS transactions
+(
+    id         SERIAL PRIMARY KEY,
+    invoice_id INT         NOT NULL REFERENCES invoices (id),
+    user_id    INT         NOT NULL REFERENCES users (id),
+    amount     BIGINT      NOT NULL,
+    reference  VARCHAR     NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS invoices_created_at_idx ON invoices (created_at);
+CREATE INDEX IF NOT EXISTS transactions_created_at_idx ON transactions (created_at);
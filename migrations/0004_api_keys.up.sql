@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS api_keys
+(
+    id         SERIAL PRIMARY KEY,
+    name       VARCHAR     NOT NULL,
+    key_hash   CHAR(64)    NOT NULL UNIQUE,
+    scopes     TEXT[]      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at TIMESTAMPTZ
+);
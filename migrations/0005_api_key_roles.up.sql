@@ -0,0 +1,12 @@
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS role    VARCHAR NOT NULL DEFAULT 'finance',
+    ADD COLUMN IF NOT EXISTS user_id INT REFERENCES users (id);
+
+-- Only the payment processor may record transactions.
+UPDATE api_keys
+SET role = 'payment_processor'
+WHERE 'transactions:write' = ANY (scopes);
+
+ALTER TABLE api_keys
+    ALTER COLUMN role DROP DEFAULT,
+    ADD CONSTRAINT api_keys_customer_user CHECK (role <> 'customer' OR user_id IS NOT NULL);
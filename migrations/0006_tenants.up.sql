@@ -0,0 +1,68 @@
+-- Tenants isolate the organizations sharing a deployment, their settings override the configuration when set.
+CREATE TABLE IF NOT EXISTS tenants
+(
+    id                 VARCHAR PRIMARY KEY,
+    name               VARCHAR          NOT NULL,
+    invoice_sequence   BIGINT           NOT NULL DEFAULT 0,
+    number_format      VARCHAR,
+    currency           VARCHAR,
+    tax_rate           DOUBLE PRECISION,
+    buyer_country      VARCHAR,
+    seller_name        VARCHAR,
+    seller_vat_id      VARCHAR,
+    seller_street      VARCHAR,
+    seller_postal_code VARCHAR,
+    seller_city        VARCHAR,
+    seller_country     VARCHAR,
+    seller_iban        VARCHAR,
+    created_at         TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+INSERT INTO tenants (id, name)
+VALUES ('default', 'Default')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants (id);
+ALTER TABLE invoices
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants (id),
+    ADD COLUMN IF NOT EXISTS number    BIGINT;
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR NOT NULL DEFAULT 'default' REFERENCES tenants (id);
+-- A NULL tenant lets an API key select any tenant with the X-Tenant-ID header.
+ALTER TABLE api_keys
+    ADD COLUMN IF NOT EXISTS tenant_id VARCHAR REFERENCES tenants (id);
+UPDATE api_keys
+SET tenant_id = 'default'
+WHERE tenant_id IS NULL;
+
+-- Invoices were numbered after their id so far, each tenant now has its own sequence.
+UPDATE invoices
+SET number = id
+WHERE number IS NULL;
+UPDATE tenants
+SET invoice_sequence = (SELECT COALESCE(MAX(number), 0) FROM invoices WHERE tenant_id = 'default')
+WHERE id = 'default';
+
+ALTER TABLE users
+    ALTER COLUMN tenant_id DROP DEFAULT;
+ALTER TABLE invoices
+    ALTER COLUMN tenant_id DROP DEFAULT,
+    ALTER COLUMN number SET NOT NULL,
+    ADD CONSTRAINT invoices_tenant_number UNIQUE (tenant_id, number);
+ALTER TABLE transactions
+    ALTER COLUMN tenant_id DROP DEFAULT;
+
+CREATE INDEX IF NOT EXISTS users_tenant_id_idx ON users (tenant_id, id);
+CREATE INDEX IF NOT EXISTS invoices_tenant_created_at_idx ON invoices (tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS transactions_tenant_created_at_idx ON transactions (tenant_id, created_at);
+
+-- Rows can only reference rows of their own tenant.
+ALTER TABLE users
+    ADD CONSTRAINT users_tenant_id_id UNIQUE (tenant_id, id);
+ALTER TABLE invoices
+    ADD CONSTRAINT invoices_tenant_id_id UNIQUE (tenant_id, id),
+    ADD CONSTRAINT invoices_tenant_user_fk FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id);
+ALTER TABLE transactions
+    ADD CONSTRAINT transactions_tenant_invoice_fk FOREIGN KEY (tenant_id, invoice_id) REFERENCES invoices (tenant_id, id),
+    ADD CONSTRAINT transactions_tenant_user_fk FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id);
@@ -0,0 +1,42 @@
+-- Subscriptions register the URL receiving the events of a tenant, the secret signs every delivery.
+CREATE TABLE IF NOT EXISTS webhook_subscriptions
+(
+    id         BIGSERIAL PRIMARY KEY,
+    tenant_id  VARCHAR     NOT NULL REFERENCES tenants (id),
+    url        VARCHAR     NOT NULL,
+    secret     VARCHAR     NOT NULL,
+    events     TEXT[]      NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    deleted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_tenant_idx ON webhook_subscriptions (tenant_id) WHERE deleted_at IS NULL;
+
+-- Deliveries are the events queued for a subscription, they end up delivered or dead once the attempts are exhausted.
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              BIGSERIAL PRIMARY KEY,
+    tenant_id       VARCHAR     NOT NULL REFERENCES tenants (id),
+    subscription_id BIGINT      NOT NULL REFERENCES webhook_subscriptions (id),
+    event_id        VARCHAR     NOT NULL,
+    event_type      VARCHAR     NOT NULL,
+    payload         JSONB       NOT NULL,
+    status          VARCHAR     NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts        INT         NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error      VARCHAR,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_tenant_idx ON webhook_deliveries (tenant_id, status);
+
+-- invoices.overdue_notified_at records that invoice.overdue was emitted, so that it is emitted once per invoice.
+ALTER TABLE invoices
+    ADD COLUMN IF NOT EXISTS overdue_notified_at TIMESTAMPTZ;
+
+-- The invoices already overdue are not notified, so that enabling webhooks does not flood the subscribers.
+UPDATE invoices
+SET overdue_notified_at = now()
+WHERE status = 'pending' AND due_at < now();
@@ -0,0 +1,18 @@
+-- The outbox holds the domain events written in the transaction of the change they describe, the relay publishes
+-- them in id order and marks them published.
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id             BIGSERIAL PRIMARY KEY,
+    tenant_id      VARCHAR     NOT NULL REFERENCES tenants (id),
+    aggregate_type VARCHAR     NOT NULL,
+    aggregate_id   VARCHAR     NOT NULL,
+    event_type     VARCHAR     NOT NULL,
+    payload        JSONB       NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    published_at   TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
+
+-- An event published twice by the relay is only delivered once per subscription.
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event_idx ON webhook_deliveries (subscription_id, event_id);
@@ -0,0 +1,27 @@
+-- The statuses and the roles are enums, so that a value unknown to the service cannot be stored.
+CREATE TYPE invoice_status AS ENUM ('pending', 'paid');
+
+ALTER TABLE invoices
+    ALTER COLUMN status DROP DEFAULT,
+    ALTER COLUMN status TYPE invoice_status USING status::invoice_status,
+    ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE TYPE webhook_delivery_status AS ENUM ('pending', 'delivered', 'dead');
+
+-- The index and the check compare the status to text, they are replaced by ones on the enum.
+DROP INDEX IF EXISTS webhook_deliveries_due_idx;
+
+ALTER TABLE webhook_deliveries
+    DROP CONSTRAINT IF EXISTS webhook_deliveries_status_check,
+    ALTER COLUMN status DROP DEFAULT,
+    ALTER COLUMN status TYPE webhook_delivery_status USING status::webhook_delivery_status,
+    ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE TYPE api_key_role AS ENUM ('customer', 'finance', 'payment_processor');
+
+ALTER TABLE api_keys
+    DROP CONSTRAINT IF EXISTS api_keys_customer_user,
+    ALTER COLUMN role TYPE api_key_role USING role::api_key_role,
+    ADD CONSTRAINT api_keys_customer_user CHECK (role <> 'customer' OR user_id IS NOT NULL);
@@ -0,0 +1,8 @@
+-- The number format of a tenant renders its invoice numbers on legal documents, it must have exactly one integer verb
+-- as checked by tenant.CheckNumberFormat. The formats already invalid are dropped, the configured one applies instead.
+UPDATE tenants
+SET number_format = NULL
+WHERE number_format !~ '^([^%]|%%)*%[-+ #0]*[0-9]*(\.[0-9]*)?[dxXo]([^%]|%%)*$';
+
+ALTER TABLE tenants
+    ADD CONSTRAINT tenants_number_format CHECK (number_format ~ '^([^%]|%%)*%[-+ #0]*[0-9]*(\.[0-9]*)?[dxXo]([^%]|%%)*$');
@@ -0,0 +1,7 @@
+-- The invoices paid before the payments were recorded have no transaction, they would still be owed on the statements
+-- and the reports. Their payment is backfilled, on the date of the invoice since the date it was paid on is unknown.
+INSERT INTO transactions (tenant_id, invoice_id, user_id, amount, reference, created_at)
+SELECT i.tenant_id, i.id, i.user_id, i.amount, 'backfill', i.created_at
+FROM invoices i
+WHERE i.status = 'paid'
+  AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.invoice_id = i.id);
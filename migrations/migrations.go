@@ -0,0 +1,118 @@
+// Package migrations holds the SQL migrations of the database, they are applied with golang-migrate. SQLite runs the
+// same migrations translated to its dialect, the sqlite directory only holds the versions it cannot run translated.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.up.sql sqlite/*.up.sql
+var files embed.FS
+
+var (
+	ErrInvalidName    = errors.New("invalid migration name")
+	ErrUnknownVersion = errors.New("sqlite migration of a version PostgreSQL does not have")
+)
+
+// Migration is the up script of a version.
+type Migration struct {
+	Version uint
+	SQL     string
+}
+
+// All returns the migrations of PostgreSQL in version order.
+func All() ([]Migration, error) {
+	return read(".")
+}
+
+// SQLite returns the migrations of SQLite in version order, those of All translated with sqliteDialect. The sqlite
+// directory replaces the versions SQLite cannot run translated: it alters a table by rebuilding it and has neither
+// enums nor regular expressions.
+func SQLite() ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	rewritten, err := read("sqlite")
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make(map[uint]int, len(all))
+	for i := range all {
+		indexes[all[i].Version] = i
+		all[i].SQL = sqliteDialect.Replace(all[i].SQL)
+	}
+	for _, migration := range rewritten {
+		i, ok := indexes[migration.Version]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, migration.Version)
+		}
+		all[i].SQL = migration.SQL
+	}
+	return all, nil
+}
+
+// sqliteNow is the current time in the format the SQLite driver writes the times in, UTC text that compares in order.
+const sqliteNow = `strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')`
+
+// sqliteDialect translates the types and the functions of PostgreSQL that SQLite does not know, it understands the
+// others as they are. The arrays are stored in their PostgreSQL text format and the JSON as text.
+var sqliteDialect = strings.NewReplacer(
+	"BIGSERIAL PRIMARY KEY", "INTEGER PRIMARY KEY",
+	"SERIAL PRIMARY KEY", "INTEGER PRIMARY KEY",
+	"TIMESTAMPTZ", "TIMESTAMP",
+	"TEXT[]", "TEXT",
+	"JSONB", "TEXT",
+	"DEFAULT now()", "DEFAULT ("+sqliteNow+")",
+	"now()", sqliteNow,
+	"ADD COLUMN IF NOT EXISTS", "ADD COLUMN",
+)
+
+func read(dir string) ([]Migration, error) {
+	sub, err := fs.Sub(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("fs.Sub: %w", err)
+	}
+	names, err := fs.Glob(sub, "*.up.sql")
+	if err != nil {
+		return nil, fmt.Errorf("fs.Glob: %w", err)
+	}
+
+	all := make([]Migration, 0, len(names))
+	for _, name := range names {
+		prefix, _, found := strings.Cut(name, "_")
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidName, name)
+		}
+		version, err := strconv.ParseUint(prefix, 10, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidName, name)
+		}
+		content, err := fs.ReadFile(sub, name)
+		if err != nil {
+			return nil, fmt.Errorf("fs.ReadFile: %w", err)
+		}
+		all = append(all, Migration{Version: uint(version), SQL: string(content)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
+
+// Latest returns the version of the last migration, the one the database is at once every migration is applied.
+func Latest() (uint, error) {
+	all, err := All()
+	if err != nil {
+		return 0, err
+	}
+	if len(all) == 0 {
+		return 0, nil
+	}
+	return all[len(all)-1].Version, nil
+}
@@ -0,0 +1,104 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatest(t *testing.T) {
+	latest, err := Latest()
+	require.NoError(t, err)
+	assert.Equal(t, uint(11), latest)
+}
+
+func TestAll(t *testing.T) {
+	all, err := All()
+	require.NoError(t, err)
+	require.Len(t, all, 11)
+	for i, migration := range all {
+		assert.Equal(t, uint(i+1), migration.Version, "in version order")
+		assert.NotEmpty(t, migration.SQL)
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	all, err := All()
+	require.NoError(t, err)
+	sqlite, err := SQLite()
+	require.NoError(t, err)
+	require.Len(t, sqlite, len(all), "every version has its SQLite counterpart")
+	for i, migration := range sqlite {
+		assert.Equal(t, all[i].Version, migration.Version)
+		assert.NotEmpty(t, migration.SQL)
+	}
+}
+
+func TestUp(t *testing.T) {
+	ctx := context.Background()
+	db, err := storage.Open(storage.DriverSQLite, filepath.Join(t.TempDir(), "jump.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	all, err := SQLite()
+	require.NoError(t, err)
+	require.NoError(t, Up(ctx, conn, all[:3]))
+	// The database is migrated from the version it is at
+	require.NoError(t, Up(ctx, conn, all))
+	require.NoError(t, Up(ctx, conn, all), "a database up to date is left as is")
+
+	var version uint
+	var dirty bool
+	require.NoError(t, conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations`).Scan(&version, &dirty))
+	assert.Equal(t, all[len(all)-1].Version, version)
+	assert.False(t, dirty)
+}
+
+func TestBackfillTransactions(t *testing.T) {
+	ctx := context.Background()
+	db, err := storage.Open(storage.DriverSQLite, filepath.Join(t.TempDir(), "jump.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	all, err := SQLite()
+	require.NoError(t, err)
+	require.NoError(t, Up(ctx, conn, all[:2]))
+	// Paid before the payments were recorded
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO users (id, first_name, last_name) VALUES (1, 'Ada', 'Lovelace');
+		INSERT INTO invoices (id, user_id, status, label, amount) VALUES (1, 1, 'paid', 'Paid', 1000), (2, 1, 'pending', 'Pending', 2000);
+	`)
+	require.NoError(t, err)
+	require.NoError(t, Up(ctx, conn, all[:10]))
+	// Paid once they were, its payment is already there
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO invoices (id, tenant_id, number, user_id, status, label, amount) VALUES (3, 'default', 3, 1, 'paid', 'Recorded', 3000);
+		INSERT INTO transactions (tenant_id, invoice_id, user_id, amount, reference) VALUES ('default', 3, 1, 3000, 'PAY-3');
+	`)
+	require.NoError(t, err)
+	require.NoError(t, Up(ctx, conn, all))
+
+	rows, err := conn.QueryContext(ctx, `SELECT tenant_id, invoice_id, amount, reference FROM transactions ORDER BY invoice_id`)
+	require.NoError(t, err)
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var tenantID, reference string
+		var invoiceID, amount int64
+		require.NoError(t, rows.Scan(&tenantID, &invoiceID, &amount, &reference))
+		got = append(got, fmt.Sprintf("%s %d %d %s", tenantID, invoiceID, amount, reference))
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"default 1 1000 backfill", "default 3 3000 PAY-3"}, got)
+}
@@ -0,0 +1,27 @@
+-- SQLite cannot add a column defaulting to the current time, the table is rebuilt. The times are stored as UTC text in
+-- the format the driver writes them, so that they compare in order. The rows are copied aside and back into the table
+-- recreated under its name, the deferred foreign keys of the rows referencing it are only satisfied by rows inserted
+-- after it was dropped.
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TABLE invoices_old AS
+SELECT *
+FROM invoices;
+DROP TABLE invoices;
+
+CREATE TABLE invoices
+(
+    id         INTEGER PRIMARY KEY,
+    user_id    INTEGER   NOT NULL REFERENCES users (id),
+    status     TEXT      NOT NULL DEFAULT 'pending',
+    label      TEXT      NOT NULL,
+    amount     INTEGER   NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    due_at     TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now', '+30 days'))
+);
+
+INSERT INTO invoices (id, user_id, status, label, amount)
+SELECT id, user_id, status, label, amount
+FROM invoices_old;
+
+DROP TABLE invoices_old;
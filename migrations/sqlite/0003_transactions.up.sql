@@ -0,0 +1,34 @@
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TABLE users_old AS
+SELECT *
+FROM users;
+DROP TABLE users;
+
+CREATE TABLE users
+(
+    id         INTEGER PRIMARY KEY,
+    first_name TEXT      NOT NULL,
+    last_name  TEXT      NOT NULL,
+    balance    INTEGER   NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+INSERT INTO users (id, first_name, last_name, balance)
+SELECT id, first_name, last_name, balance
+FROM users_old;
+
+DROP TABLE users_old;
+
+CREATE TABLE IF NOT EXISTS transactions
+(
+    id         INTEGER PRIMARY KEY,
+    invoice_id INTEGER   NOT NULL REFERENCES invoices (id),
+    user_id    INTEGER   NOT NULL REFERENCES users (id),
+    amount     INTEGER   NOT NULL,
+    reference  TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS invoices_created_at_idx ON invoices (created_at);
+CREATE INDEX IF NOT EXISTS transactions_created_at_idx ON transactions (created_at);
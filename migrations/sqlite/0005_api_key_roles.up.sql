@@ -0,0 +1,28 @@
+-- SQLite cannot add a check to a table, the table is rebuilt.
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TABLE api_keys_old AS
+SELECT *
+FROM api_keys;
+DROP TABLE api_keys;
+
+CREATE TABLE api_keys
+(
+    id         INTEGER PRIMARY KEY,
+    name       TEXT      NOT NULL,
+    key_hash   TEXT      NOT NULL UNIQUE,
+    scopes     TEXT      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    revoked_at TIMESTAMP,
+    role       TEXT      NOT NULL,
+    user_id    INTEGER REFERENCES users (id),
+    CONSTRAINT api_keys_customer_user CHECK (role <> 'customer' OR user_id IS NOT NULL)
+);
+
+-- Only the payment processor may record transactions.
+INSERT INTO api_keys (id, name, key_hash, scopes, created_at, revoked_at, role)
+SELECT id, name, key_hash, scopes, created_at, revoked_at,
+       CASE WHEN instr(scopes, 'transactions:write') > 0 THEN 'payment_processor' ELSE 'finance' END
+FROM api_keys_old;
+
+DROP TABLE api_keys_old;
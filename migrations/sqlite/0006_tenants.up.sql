@@ -0,0 +1,119 @@
+-- Tenants isolate the organizations sharing a deployment, their settings override the configuration when set.
+CREATE TABLE IF NOT EXISTS tenants
+(
+    id                 TEXT PRIMARY KEY,
+    name               TEXT      NOT NULL,
+    invoice_sequence   INTEGER   NOT NULL DEFAULT 0,
+    number_format      TEXT,
+    currency           TEXT,
+    tax_rate           REAL,
+    buyer_country      TEXT,
+    seller_name        TEXT,
+    seller_vat_id      TEXT,
+    seller_street      TEXT,
+    seller_postal_code TEXT,
+    seller_city        TEXT,
+    seller_country     TEXT,
+    seller_iban        TEXT,
+    created_at         TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+INSERT INTO tenants (id, name)
+VALUES ('default', 'Default')
+ON CONFLICT DO NOTHING;
+
+-- SQLite cannot add constraints to a table, the tables are rebuilt with the rows of the default tenant. Rows can only
+-- reference rows of their own tenant.
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TABLE users_old AS
+SELECT *
+FROM users;
+DROP TABLE users;
+
+CREATE TABLE users
+(
+    id         INTEGER PRIMARY KEY,
+    first_name TEXT      NOT NULL,
+    last_name  TEXT      NOT NULL,
+    balance    INTEGER   NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    tenant_id  TEXT      NOT NULL REFERENCES tenants (id),
+    CONSTRAINT users_tenant_id_id UNIQUE (tenant_id, id)
+);
+
+INSERT INTO users (id, first_name, last_name, balance, created_at, tenant_id)
+SELECT id, first_name, last_name, balance, created_at, 'default'
+FROM users_old;
+
+DROP TABLE users_old;
+
+-- Invoices were numbered after their id so far, each tenant now has its own sequence.
+CREATE TABLE invoices_old AS
+SELECT *
+FROM invoices;
+DROP TABLE invoices;
+
+CREATE TABLE invoices
+(
+    id         INTEGER PRIMARY KEY,
+    user_id    INTEGER   NOT NULL REFERENCES users (id),
+    status     TEXT      NOT NULL DEFAULT 'pending',
+    label      TEXT      NOT NULL,
+    amount     INTEGER   NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    due_at     TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now', '+30 days')),
+    tenant_id  TEXT      NOT NULL REFERENCES tenants (id),
+    number     INTEGER   NOT NULL,
+    CONSTRAINT invoices_tenant_number UNIQUE (tenant_id, number),
+    CONSTRAINT invoices_tenant_id_id UNIQUE (tenant_id, id),
+    CONSTRAINT invoices_tenant_user_fk FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id)
+);
+
+INSERT INTO invoices (id, user_id, status, label, amount, created_at, due_at, tenant_id, number)
+SELECT id, user_id, status, label, amount, created_at, due_at, 'default', id
+FROM invoices_old;
+
+DROP TABLE invoices_old;
+
+UPDATE tenants
+SET invoice_sequence = (SELECT COALESCE(MAX(number), 0) FROM invoices WHERE tenant_id = 'default')
+WHERE id = 'default';
+
+CREATE TABLE transactions_old AS
+SELECT *
+FROM transactions;
+DROP TABLE transactions;
+
+CREATE TABLE transactions
+(
+    id         INTEGER PRIMARY KEY,
+    invoice_id INTEGER   NOT NULL REFERENCES invoices (id),
+    user_id    INTEGER   NOT NULL REFERENCES users (id),
+    amount     INTEGER   NOT NULL,
+    reference  TEXT      NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    tenant_id  TEXT      NOT NULL REFERENCES tenants (id),
+    CONSTRAINT transactions_tenant_invoice_fk FOREIGN KEY (tenant_id, invoice_id) REFERENCES invoices (tenant_id, id),
+    CONSTRAINT transactions_tenant_user_fk FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id)
+);
+
+INSERT INTO transactions (id, invoice_id, user_id, amount, reference, created_at, tenant_id)
+SELECT id, invoice_id, user_id, amount, reference, created_at, 'default'
+FROM transactions_old;
+
+DROP TABLE transactions_old;
+
+-- A NULL tenant lets an API key select any tenant with the X-Tenant-ID header.
+ALTER TABLE api_keys
+    ADD COLUMN tenant_id TEXT REFERENCES tenants (id);
+UPDATE api_keys
+SET tenant_id = 'default'
+WHERE tenant_id IS NULL;
+
+-- The indexes of the rebuilt tables were dropped along with them.
+CREATE INDEX IF NOT EXISTS invoices_created_at_idx ON invoices (created_at);
+CREATE INDEX IF NOT EXISTS transactions_created_at_idx ON transactions (created_at);
+CREATE INDEX IF NOT EXISTS users_tenant_id_idx ON users (tenant_id, id);
+CREATE INDEX IF NOT EXISTS invoices_tenant_created_at_idx ON invoices (tenant_id, created_at);
+CREATE INDEX IF NOT EXISTS transactions_tenant_created_at_idx ON transactions (tenant_id, created_at);
@@ -0,0 +1,59 @@
+-- SQLite has no enums, the statuses and the roles are checked instead so that a value unknown to the service cannot
+-- be stored. The deliveries already check their status, the invoices and the API keys are rebuilt.
+PRAGMA defer_foreign_keys = ON;
+
+CREATE TABLE invoices_old AS
+SELECT *
+FROM invoices;
+DROP TABLE invoices;
+
+CREATE TABLE invoices
+(
+    id                  INTEGER PRIMARY KEY,
+    user_id             INTEGER   NOT NULL REFERENCES users (id),
+    status              TEXT      NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'paid')),
+    label               TEXT      NOT NULL,
+    amount              INTEGER   NOT NULL,
+    created_at          TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    due_at              TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now', '+30 days')),
+    tenant_id           TEXT      NOT NULL REFERENCES tenants (id),
+    number              INTEGER   NOT NULL,
+    overdue_notified_at TIMESTAMP,
+    CONSTRAINT invoices_tenant_number UNIQUE (tenant_id, number),
+    CONSTRAINT invoices_tenant_id_id UNIQUE (tenant_id, id),
+    CONSTRAINT invoices_tenant_user_fk FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id)
+);
+
+INSERT INTO invoices (id, user_id, status, label, amount, created_at, due_at, tenant_id, number, overdue_notified_at)
+SELECT id, user_id, status, label, amount, created_at, due_at, tenant_id, number, overdue_notified_at
+FROM invoices_old;
+
+DROP TABLE invoices_old;
+
+CREATE INDEX IF NOT EXISTS invoices_created_at_idx ON invoices (created_at);
+CREATE INDEX IF NOT EXISTS invoices_tenant_created_at_idx ON invoices (tenant_id, created_at);
+
+CREATE TABLE api_keys_old AS
+SELECT *
+FROM api_keys;
+DROP TABLE api_keys;
+
+CREATE TABLE api_keys
+(
+    id         INTEGER PRIMARY KEY,
+    name       TEXT      NOT NULL,
+    key_hash   TEXT      NOT NULL UNIQUE,
+    scopes     TEXT      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    revoked_at TIMESTAMP,
+    role       TEXT      NOT NULL CHECK (role IN ('customer', 'finance', 'payment_processor')),
+    user_id    INTEGER REFERENCES users (id),
+    tenant_id  TEXT REFERENCES tenants (id),
+    CONSTRAINT api_keys_customer_user CHECK (role <> 'customer' OR user_id IS NOT NULL)
+);
+
+INSERT INTO api_keys (id, name, key_hash, scopes, created_at, revoked_at, role, user_id, tenant_id)
+SELECT id, name, key_hash, scopes, created_at, revoked_at, role, user_id, tenant_id
+FROM api_keys_old;
+
+DROP TABLE api_keys_old;
@@ -0,0 +1,36 @@
+-- The number format of a tenant renders its invoice numbers on legal documents, it must have exactly one integer verb
+-- as checked by tenant.CheckNumberFormat. The formats already invalid are dropped, the configured one applies instead.
+-- SQLite has no regular expressions and cannot add a check to a table every other one references, the triggers check
+-- the format step by step: once the literal percent signs are removed, a single one is left, followed by the flags,
+-- the width, the precision and the verb.
+CREATE VIEW tenants_invalid_number_format AS
+SELECT id
+FROM (SELECT id, replace(number_format, '%%', '') AS format FROM tenants)
+WHERE format IS NOT NULL
+  AND (length(format) - length(replace(format, '%', '')) <> 1
+    OR NOT (
+        CASE
+            WHEN ltrim(ltrim(substr(format, instr(format, '%') + 1), '-+ #0'), '0123456789') GLOB '.*'
+                THEN ltrim(substr(ltrim(ltrim(substr(format, instr(format, '%') + 1), '-+ #0'), '0123456789'), 2), '0123456789')
+            ELSE ltrim(ltrim(substr(format, instr(format, '%') + 1), '-+ #0'), '0123456789')
+        END GLOB '[dxXo]*'));
+
+UPDATE tenants
+SET number_format = NULL
+WHERE id IN (SELECT id FROM tenants_invalid_number_format);
+
+CREATE TRIGGER tenants_number_format_insert
+    AFTER INSERT
+    ON tenants
+    WHEN NEW.id IN (SELECT id FROM tenants_invalid_number_format)
+BEGIN
+    SELECT RAISE(ABORT, 'CHECK constraint failed: tenants_number_format');
+END;
+
+CREATE TRIGGER tenants_number_format_update
+    AFTER UPDATE OF number_format
+    ON tenants
+    WHEN NEW.id IN (SELECT id FROM tenants_invalid_number_format)
+BEGIN
+    SELECT RAISE(ABORT, 'CHECK constraint failed: tenants_number_format');
+END;
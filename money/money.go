@@ -1,6 +1,9 @@
 package money
 
-import "math"
+import (
+	"fmt"
+	"math"
+)
 
 type Money int64
 
@@ -8,6 +11,17 @@ func (m Money) ToFloat() float64 {
 	return float64(m) / 100 // Assuming the balance is represented in cents
 }
 
+// String formats the amount with two decimals, without going through a float.
+func (m Money) String() string {
+	sign := ""
+	cents := int64(m)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
 func NewMoneyFromFloat(value float64) Money {
 	amount := int64(math.Round(value * 100))
 	return Money(amount)
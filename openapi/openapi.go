@@ -0,0 +1,44 @@
+package openapi
+
+import (
+	_ "embed"
+	"fmt"
+	"html"
+	"net/http"
+)
+
+// Spec is the OpenAPI document of the REST API, cmd checks that it describes every route registered.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Handler serves Spec.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(Spec)
+	})
+}
+
+// docsPage renders the document served at the URL it is formatted with.
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>invoice_microservice API</title>
+</head>
+<body>
+  <redoc spec-url="%s"></redoc>
+  <script src="https://cdn.redocly.com/redoc/v2.1.5/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// DocsHandler serves a page rendering the document served at specURL.
+func DocsHandler(specURL string) http.Handler {
+	page := fmt.Sprintf(docsPage, html.EscapeString(specURL))
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(page))
+	})
+}
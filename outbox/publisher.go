@@ -0,0 +1,72 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/emilien-puget/invoice_microservice/logging"
+)
+
+// Publisher hands a message over to the outside world, the relay calls it at least once per message and in order
+// for the messages of an aggregate.
+type Publisher interface {
+	Publish(ctx context.Context, message Message) error
+}
+
+// Publishers publishes every message to each publisher in turn, the first error stops it.
+type Publishers []Publisher
+
+func (p Publishers) Publish(ctx context.Context, message Message) error {
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LogPublisher logs the messages.
+type LogPublisher struct{}
+
+func NewLogPublisher() *LogPublisher {
+	return &LogPublisher{}
+}
+
+func (LogPublisher) Publish(ctx context.Context, message Message) error {
+	logging.FromContext(ctx).Info("outbox message",
+		slog.Int64("id", message.ID),
+		slog.String("type", message.Type),
+		slog.String("aggregate_type", message.AggregateType),
+		slog.String("aggregate_id", message.AggregateID),
+		slog.String("tenant_id", message.TenantID),
+		slog.String("payload", string(message.Payload)),
+	)
+	return nil
+}
+
+// MemoryPublisher keeps the messages in memory, for the tests.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(_ context.Context, message Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+// Messages returns a copy of the messages published so far.
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Message(nil), p.messages...)
+}
@@ -0,0 +1,76 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/logging"
+)
+
+// Relay publishes the messages of the outbox.
+type Relay struct {
+	repository interface {
+		Drain(ctx context.Context, limit int, publish func(ctx context.Context, messages []Message) ([]int64, error)) (int, error)
+	}
+	publisher Publisher
+	interval  time.Duration
+	batchSize int
+}
+
+func NewRelay(repository *Repository, publisher Publisher, interval time.Duration, batchSize int) *Relay {
+	return &Relay{repository: repository, publisher: publisher, interval: interval, batchSize: batchSize}
+}
+
+// Run drains the outbox every interval until ctx is done, the batch in flight then is published before returning.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			workCtx := context.WithoutCancel(ctx)
+			for ctx.Err() == nil {
+				n, err := r.repository.Drain(workCtx, r.batchSize, r.publish)
+				if err != nil {
+					logging.FromContext(ctx).Error("outbox relay failed", slog.Any("error", err))
+				}
+				if err != nil || n < r.batchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+type aggregate struct {
+	typ string
+	id  string
+}
+
+// publish publishes the messages in order and returns the ids of the ones published. Once a message of an aggregate
+// fails the following messages of that aggregate are held back, they are retried with it on the next drain.
+func (r *Relay) publish(ctx context.Context, messages []Message) ([]int64, error) {
+	var published []int64
+	var errs []error
+	failed := map[aggregate]bool{}
+	for _, message := range messages {
+		key := aggregate{typ: message.AggregateType, id: message.AggregateID}
+		if failed[key] {
+			continue
+		}
+		if err := r.publisher.Publish(ctx, message); err != nil {
+			failed[key] = true
+			errs = append(errs, fmt.Errorf("message %d: %w", message.ID, err))
+			continue
+		}
+		published = append(published, message.ID)
+	}
+
+	return published, errors.Join(errs...)
+}
@@ -0,0 +1,53 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errBrokerDown = errors.New("broker down")
+
+// failingPublisher fails the messages listed and hands the others over to the memory publisher.
+type failingPublisher struct {
+	*MemoryPublisher
+	fail map[int64]bool
+}
+
+func (p failingPublisher) Publish(ctx context.Context, message Message) error {
+	if p.fail[message.ID] {
+		return errBrokerDown
+	}
+	return p.MemoryPublisher.Publish(ctx, message)
+}
+
+func TestRelay_Publish(t *testing.T) {
+	messages := []Message{
+		{ID: 1, AggregateType: "invoice", AggregateID: "10", Type: "invoice.created"},
+		{ID: 2, AggregateType: "invoice", AggregateID: "11", Type: "invoice.created"},
+		{ID: 3, AggregateType: "invoice", AggregateID: "10", Type: "invoice.paid"},
+		{ID: 4, AggregateType: "invoice", AggregateID: "11", Type: "invoice.paid"},
+	}
+
+	t.Run("in order", func(t *testing.T) {
+		memory := NewMemoryPublisher()
+		published, err := (&Relay{publisher: memory}).publish(context.Background(), messages)
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1, 2, 3, 4}, published)
+		assert.Equal(t, messages, memory.Messages())
+	})
+
+	t.Run("an aggregate failing holds its next messages back", func(t *testing.T) {
+		memory := NewMemoryPublisher()
+		relay := &Relay{publisher: failingPublisher{MemoryPublisher: memory, fail: map[int64]bool{1: true}}}
+		published, err := relay.publish(context.Background(), messages)
+		require.ErrorIs(t, err, errBrokerDown)
+
+		// The invoice 10 is retried from its first message, the invoice 11 is not held back
+		assert.Equal(t, []int64{2, 4}, published)
+		assert.Equal(t, []Message{messages[1], messages[3]}, memory.Messages())
+	})
+}
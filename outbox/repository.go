@@ -0,0 +1,163 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+)
+
+// Message is a domain event of the outbox, its ID increases with the order the events were written in.
+type Message struct {
+	ID            int64
+	TenantID      string
+	AggregateType string
+	AggregateID   string
+	Type          string
+	Payload       json.RawMessage
+	CreatedAt     time.Time
+}
+
+// relayLockKey is the advisory lock held while relaying, a single replica publishes at a time so that the order of
+// the messages is kept.
+const relayLockKey = 7_036_037
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Append writes an event about the aggregate, call it within the transaction of the change so that the event is
+// written if and only if the change is.
+func (r *Repository) Append(ctx context.Context, aggregateType, aggregateID, eventType string, data any) (err error) {
+	ctx, span := storage.StartSpan(ctx, "outbox.Repository.Append", storage.OperationInsert, "outbox")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	query := `
+		INSERT INTO jump.public.outbox (tenant_id, aggregate_type, aggregate_id, event_type, payload)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err = storage.Conn(ctx, r.db).ExecContext(ctx, storage.Dialect(r.db, query), tenantID, aggregateType, aggregateID, eventType, payload)
+	if err != nil {
+		return fmt.Errorf("failed to append to outbox: %w", err)
+	}
+	return nil
+}
+
+// Drain hands the oldest unpublished messages over to publish, in id order, and marks the ones it returns as
+// published. It does nothing when another replica is draining. It needs PostgreSQL, the messages are only appended on
+// SQLite.
+func (r *Repository) Drain(ctx context.Context, limit int, publish func(ctx context.Context, messages []Message) ([]int64, error)) (_ int, err error) {
+	ctx, span := storage.StartSpan(ctx, "outbox.Repository.Drain", storage.OperationUpdate, "outbox")
+	defer storage.EndSpan(span, &err)
+
+	var published []int64
+	var publishErr error
+	err = storage.WithinTx(ctx, r.db, func(ctx context.Context) error {
+		conn := storage.Conn(ctx, r.db)
+
+		var locked bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", relayLockKey).Scan(&locked); err != nil {
+			return fmt.Errorf("failed to lock outbox: %w", err)
+		}
+		if !locked {
+			return nil
+		}
+
+		messages, err := r.pending(ctx, conn, limit)
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		// The messages published are marked even when others failed, publishErr is returned once committed
+		published, publishErr = publish(ctx, messages)
+		if len(published) == 0 {
+			return nil
+		}
+
+		query := `
+			UPDATE jump.public.outbox
+			SET published_at = now()
+			WHERE id = ANY($1)
+		`
+
+		if _, err := conn.ExecContext(ctx, query, storage.Array(r.db, published)); err != nil {
+			return fmt.Errorf("failed to mark outbox messages published: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(published), publishErr
+}
+
+func (r *Repository) pending(ctx context.Context, conn storage.Querier, limit int) ([]Message, error) {
+	query := `
+		SELECT id, tenant_id, aggregate_type, aggregate_id, event_type, payload, created_at
+		FROM jump.public.outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+	`
+
+	rows, err := conn.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []Message
+	for rows.Next() {
+		var m Message
+		if err := rows.Scan(&m.ID, &m.TenantID, &m.AggregateType, &m.AggregateID, &m.Type, &m.Payload, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	return messages, nil
+}
+
+// Lag returns how long the oldest unpublished message has been waiting, zero when every message was published.
+func (r *Repository) Lag(ctx context.Context) (_ time.Duration, err error) {
+	ctx, span := storage.StartSpan(ctx, "outbox.Repository.Lag", storage.OperationSelect, "outbox")
+	defer storage.EndSpan(span, &err)
+
+	query := `
+		SELECT COALESCE(EXTRACT(EPOCH FROM now() - MIN(created_at)), 0)
+		FROM jump.public.outbox
+		WHERE published_at IS NULL
+	`
+
+	var seconds float64
+	if err := r.db.QueryRowContext(ctx, query).Scan(&seconds); err != nil {
+		return 0, fmt.Errorf("failed to get outbox lag: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,99 @@
+package outbox
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const pendingQuery = "SELECT id, tenant_id, aggregate_type, aggregate_id, event_type, payload, created_at FROM jump.public.outbox WHERE published_at IS NULL ORDER BY id LIMIT $1"
+
+func TestRepository_Append(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewOutboxRepository(db)
+	ctx := tenant.NewContext(context.Background(), &tenant.Tenant{ID: "acme"})
+
+	// Mock the expected queries, the message is written in the transaction of the caller
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO jump.public.outbox (tenant_id, aggregate_type, aggregate_id, event_type, payload) VALUES ($1, $2, $3, $4, $5)").
+		WithArgs("acme", "invoice", "10", "invoice.paid", []byte(`{"invoice_id":10}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = storage.WithinTx(ctx, db, func(ctx context.Context) error {
+		return repo.Append(ctx, "invoice", "10", "invoice.paid", map[string]int64{"invoice_id": 10})
+	})
+	require.NoError(t, err)
+
+	// Ensure all expectations were met
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_Drain(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewOutboxRepository(db)
+	createdAt := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	// Mock the expected queries and results
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock($1)").WithArgs(relayLockKey).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(true))
+	mock.ExpectQuery(pendingQuery).WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "aggregate_type", "aggregate_id", "event_type", "payload", "created_at"}).
+			AddRow(1, "acme", "invoice", "10", "invoice.created", []byte(`{}`), createdAt).
+			AddRow(2, "acme", "invoice", "10", "invoice.paid", []byte(`{}`), createdAt))
+	mock.ExpectExec("UPDATE jump.public.outbox SET published_at = now() WHERE id = ANY($1)").WithArgs("{1,2}").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	memory := NewMemoryPublisher()
+	n, err := repo.Drain(context.Background(), 10, (&Relay{publisher: memory}).publish)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, memory.Messages(), 2)
+
+	// Another replica holds the lock, nothing is published
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pg_try_advisory_xact_lock($1)").WithArgs(relayLockKey).
+		WillReturnRows(sqlmock.NewRows([]string{"locked"}).AddRow(false))
+	mock.ExpectCommit()
+
+	n, err = repo.Drain(context.Background(), 10, (&Relay{publisher: memory}).publish)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// Ensure all expectations were met
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_Lag(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	// The lag is measured across the tenants
+	mock.ExpectQuery("SELECT COALESCE(EXTRACT(EPOCH FROM now() - MIN(created_at)), 0) FROM jump.public.outbox WHERE published_at IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(2.5))
+
+	lag, err := NewOutboxRepository(db).Lag(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2500*time.Millisecond, lag)
+
+	// Ensure all expectations were met
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,153 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/broker"
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/logging"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/go-playground/validator/v10"
+)
+
+// Confirmation is a payment confirmed by the bank connector.
+type Confirmation struct {
+	TenantID  string  `json:"tenant_id"`
+	InvoiceID int64   `json:"invoice_id"`
+	Amount    float64 `json:"amount"`
+	Reference string  `json:"reference"`
+}
+
+var (
+	ErrInvalidMessage = errors.New("invalid message")
+	ErrPanic          = errors.New("panic while applying")
+)
+
+// Consumer applies the payment confirmations read from the broker, with the logic of the transaction endpoint.
+type Consumer struct {
+	subscriber       broker.Subscriber
+	tenantRepository interface {
+		GetByID(ctx context.Context, id string) (*tenant.Tenant, error)
+	}
+	payments interface {
+		Apply(ctx context.Context, payload invoice.TransactionPayload) error
+	}
+	transactionRepository interface {
+		Exists(ctx context.Context, invoiceID int64, reference string) (bool, error)
+	}
+	maxDeliveries int
+	backoff       time.Duration
+}
+
+func NewConsumer(subscriber broker.Subscriber, tenantRepository *tenant.Repository, payments *invoice.Payments, transactionRepository *invoice.TransactionRepository, maxDeliveries int, backoff time.Duration) *Consumer {
+	return &Consumer{
+		subscriber:            subscriber,
+		tenantRepository:      tenantRepository,
+		payments:              payments,
+		transactionRepository: transactionRepository,
+		maxDeliveries:         maxDeliveries,
+		backoff:               backoff,
+	}
+}
+
+// Run consumes the confirmations until ctx is done.
+func (c *Consumer) Run(ctx context.Context) error {
+	return c.subscriber.Subscribe(ctx, c.handle)
+}
+
+// handle acks the confirmations applied, dead letters the ones that can never be applied and naks the others so
+// that they are delivered again later, until they were delivered maxDeliveries times.
+func (c *Consumer) handle(ctx context.Context, msg broker.Message) {
+	err := c.applySafely(ctx, msg.Data())
+
+	logger := logging.FromContext(ctx).With(slog.Int("deliveries", msg.Deliveries()))
+	var settleErr error
+	switch {
+	case err == nil:
+		settleErr = msg.Ack(ctx)
+	case isPoison(err):
+		logger.Warn("payment confirmation dead lettered", slog.Any("error", err))
+		settleErr = msg.DeadLetter(ctx, err.Error())
+	case msg.Deliveries() >= c.maxDeliveries:
+		logger.Error("payment confirmation dead lettered after its last delivery", slog.Any("error", err))
+		settleErr = msg.DeadLetter(ctx, fmt.Sprintf("gave up after %d deliveries: %v", msg.Deliveries(), err))
+	default:
+		logger.Warn("payment confirmation retried", slog.Any("error", err))
+		settleErr = msg.Nak(ctx, c.delay(msg.Deliveries()))
+	}
+	if settleErr != nil {
+		logger.Error("payment confirmation not settled", slog.Any("error", settleErr))
+	}
+}
+
+// applySafely turns a panic into an error, the confirmation is retried rather than the process exiting while other
+// confirmations are applied.
+func (c *Consumer) applySafely(ctx context.Context, data []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrPanic, r)
+		}
+	}()
+	return c.apply(ctx, data)
+}
+
+func (c *Consumer) apply(ctx context.Context, data []byte) error {
+	var confirmation Confirmation
+	if err := json.Unmarshal(data, &confirmation); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMessage, err)
+	}
+	if confirmation.TenantID == "" {
+		return fmt.Errorf("%w: missing tenant_id", ErrInvalidMessage)
+	}
+
+	t, err := c.tenantRepository.GetByID(ctx, confirmation.TenantID)
+	if err != nil {
+		return fmt.Errorf("tenantRepository.GetByID: %w", err)
+	}
+	ctx = tenant.NewContext(ctx, t)
+
+	err = c.payments.Apply(ctx, invoice.TransactionPayload{
+		InvoiceID: confirmation.InvoiceID,
+		Amount:    confirmation.Amount,
+		Reference: confirmation.Reference,
+	})
+	if errors.Is(err, invoice.ErrInvoiceAlreadyPaid) {
+		// The confirmation was applied before and delivered again, it is not a second payment
+		exists, existsErr := c.transactionRepository.Exists(ctx, confirmation.InvoiceID, confirmation.Reference)
+		if existsErr != nil {
+			return fmt.Errorf("transactionRepository.Exists: %w", existsErr)
+		}
+		if exists {
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("payments.Apply: %w", err)
+	}
+	return nil
+}
+
+// isPoison reports whether err comes from the confirmation itself, delivering it again would not help.
+func isPoison(err error) bool {
+	var validationErrors validator.ValidationErrors
+	return errors.Is(err, ErrInvalidMessage) ||
+		errors.Is(err, tenant.ErrTenantNotFound) ||
+		errors.Is(err, invoice.ErrInvoiceNotFound) ||
+		errors.Is(err, invoice.ErrInvalidAmount) ||
+		errors.Is(err, invoice.ErrInvoiceAlreadyPaid) ||
+		errors.As(err, &validationErrors)
+}
+
+// delay doubles the backoff with every delivery.
+func (c *Consumer) delay(deliveries int) time.Duration {
+	delay := c.backoff
+	for i := 1; i < deliveries && i < 10; i++ {
+		delay *= 2
+	}
+	return delay
+}
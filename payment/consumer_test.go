@@ -0,0 +1,167 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/broker"
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	errDatabaseDown = errors.New("database down")
+	// errBug makes fakePayments panic.
+	errBug = errors.New("bug")
+)
+
+type fakeTenantRepository struct{}
+
+func (fakeTenantRepository) GetByID(_ context.Context, id string) (*tenant.Tenant, error) {
+	if id != "acme" {
+		return nil, tenant.ErrTenantNotFound
+	}
+	return &tenant.Tenant{ID: id}, nil
+}
+
+// fakePayments returns the errors in turn, then applies the payments.
+type fakePayments struct {
+	mu      sync.Mutex
+	errs    []error
+	applied []invoice.TransactionPayload
+}
+
+func (f *fakePayments) Apply(ctx context.Context, payload invoice.TransactionPayload) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := tenant.ID(ctx); err != nil {
+		return err
+	}
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		if errors.Is(err, errBug) {
+			panic("assignment to entry in nil map")
+		}
+		return err
+	}
+	f.applied = append(f.applied, payload)
+	return nil
+}
+
+type fakeTransactionRepository map[string]bool
+
+func (f fakeTransactionRepository) Exists(_ context.Context, _ int64, reference string) (bool, error) {
+	return f[reference], nil
+}
+
+// consume publishes data to a memory broker and consumes it until it is settled.
+func consume(t *testing.T, payments *fakePayments, data string) *broker.Memory {
+	t.Helper()
+
+	memory := broker.NewMemory()
+	consumer := &Consumer{
+		subscriber:            memory,
+		tenantRepository:      fakeTenantRepository{},
+		payments:              payments,
+		transactionRepository: fakeTransactionRepository{"bank-1": true},
+		maxDeliveries:         3,
+		backoff:               time.Second,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- consumer.Run(ctx) }()
+
+	memory.Publish([]byte(data))
+	require.Eventually(t, func() bool {
+		return len(memory.Acked())+len(memory.DeadLetters()) > 0
+	}, time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	return memory
+}
+
+func TestConsumer(t *testing.T) {
+	const confirmation = `{"tenant_id":"acme","invoice_id":10,"amount":10,"reference":"bank-2"}`
+
+	t.Run("applied", func(t *testing.T) {
+		payments := &fakePayments{}
+		memory := consume(t, payments, confirmation)
+
+		assert.Len(t, memory.Acked(), 1)
+		assert.Equal(t, []invoice.TransactionPayload{{InvoiceID: 10, Amount: 10, Reference: "bank-2"}}, payments.applied)
+	})
+
+	t.Run("retried with backoff", func(t *testing.T) {
+		payments := &fakePayments{errs: []error{errDatabaseDown, errDatabaseDown}}
+		memory := consume(t, payments, confirmation)
+
+		assert.Len(t, memory.Acked(), 1)
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, memory.Delays())
+	})
+
+	t.Run("retried after a panic", func(t *testing.T) {
+		payments := &fakePayments{errs: []error{errBug}}
+		memory := consume(t, payments, confirmation)
+
+		assert.Len(t, memory.Acked(), 1)
+		assert.Equal(t, []time.Duration{time.Second}, memory.Delays())
+	})
+
+	t.Run("dead once the deliveries are exhausted", func(t *testing.T) {
+		payments := &fakePayments{errs: []error{errDatabaseDown, errDatabaseDown, errDatabaseDown}}
+		memory := consume(t, payments, confirmation)
+
+		require.Len(t, memory.DeadLetters(), 1)
+		assert.Equal(t, "gave up after 3 deliveries: payments.Apply: database down", memory.DeadLetters()[0].Reason)
+	})
+
+	t.Run("delivered again once applied", func(t *testing.T) {
+		payments := &fakePayments{errs: []error{invoice.ErrInvoiceAlreadyPaid}}
+		memory := consume(t, payments, `{"tenant_id":"acme","invoice_id":10,"amount":10,"reference":"bank-1"}`)
+
+		assert.Len(t, memory.Acked(), 1)
+	})
+
+	for name, test := range map[string]struct {
+		data   string
+		errs   []error
+		reason string
+	}{
+		"not json": {
+			data:   `{`,
+			reason: "invalid message: unexpected end of JSON input",
+		},
+		"unknown tenant": {
+			data:   `{"tenant_id":"globex","invoice_id":10,"amount":10,"reference":"bank-2"}`,
+			reason: "tenantRepository.GetByID: tenant not found",
+		},
+		"second payment": {
+			data:   confirmation,
+			errs:   []error{invoice.ErrInvoiceAlreadyPaid},
+			reason: "payments.Apply: invoice already paid",
+		},
+		"wrong amount": {
+			data:   confirmation,
+			errs:   []error{invoice.ErrInvalidAmount},
+			reason: "payments.Apply: invalid amount",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			memory := consume(t, &fakePayments{errs: test.errs}, test.data)
+
+			// Poison messages are set aside on their first delivery
+			assert.Empty(t, memory.Delays())
+			require.Len(t, memory.DeadLetters(), 1)
+			assert.Equal(t, test.reason, memory.DeadLetters()[0].Reason)
+		})
+	}
+}
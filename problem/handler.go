@@ -0,0 +1,84 @@
+package problem
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/emilien-puget/invoice_microservice/logging"
+	"github.com/labstack/echo/v4"
+)
+
+// statusCodes are the codes of the errors returned by echo and its middlewares, by status.
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeInvalidRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusConflict:              CodeConflict,
+	http.StatusUnsupportedMediaType:  CodeUnsupportedMediaType,
+	http.StatusUnprocessableEntity:   CodeUnprocessable,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusRequestEntityTooLarge: CodeInvalidRequest,
+}
+
+// ErrorHandler is the HTTPErrorHandler of echo, it writes every error as a problem. Errors other than an *Error or an
+// *echo.HTTPError are internal: they are logged with the logger of the request and only its correlation ID is returned.
+func ErrorHandler(err error, c echo.Context) {
+	p := Problem{Instance: c.Request().URL.Path, CorrelationID: correlationID(c)}
+	logger := logging.FromContext(c.Request().Context()).With(slog.String("correlation_id", p.CorrelationID))
+
+	var problemErr *Error
+	var httpErr *echo.HTTPError
+	switch {
+	case errors.As(err, &problemErr):
+		p.Status, p.Code, p.Detail, p.Errors = problemErr.Status, problemErr.Code, problemErr.Detail, problemErr.Fields
+	case errors.As(err, &httpErr):
+		p.Status, p.Code, p.Detail = httpErr.Code, statusCode(httpErr.Code), fmt.Sprint(httpErr.Message)
+		if httpErr.Internal != nil {
+			logger.Error("request failed", slog.Any("error", httpErr.Internal))
+		}
+	default:
+		logger.Error("request failed", slog.Any("error", err))
+		p.Status, p.Code = http.StatusInternalServerError, CodeInternal
+	}
+	p.Type = typePrefix + p.Code
+	p.Title = http.StatusText(p.Status)
+
+	if c.Response().Committed {
+		return
+	}
+	c.Response().Header().Set(echo.HeaderContentType, ContentType)
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(p.Status)
+	} else {
+		err = c.JSON(p.Status, p)
+	}
+	if err != nil {
+		logger.Error("write problem", slog.Any("error", err))
+	}
+}
+
+func statusCode(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
+
+// correlationID returns the request ID set by the middlewares, a new ID when there is none.
+func correlationID(c echo.Context) string {
+	if id := c.Response().Header().Get(echo.HeaderXRequestID); id != "" {
+		return id
+	}
+	if id := c.Request().Header.Get(echo.HeaderXRequestID); id != "" {
+		return id
+	}
+	return logging.NewRequestID()
+}
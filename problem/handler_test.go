@@ -0,0 +1,97 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errConnectionLost = errors.New("connection lost")
+
+type payload struct {
+	UserID int64  `json:"user_id" validate:"required"`
+	Label  string `json:"label" validate:"required"`
+}
+
+func TestErrorHandler(t *testing.T) {
+	validate := validator.New()
+	validate.RegisterTagNameFunc(JSONName)
+	var validationErrors validator.ValidationErrors
+	require.ErrorAs(t, validate.Struct(payload{Label: "Consulting"}), &validationErrors)
+
+	for name, test := range map[string]struct {
+		err     error
+		path    string
+		problem Problem
+	}{
+		"problem": {
+			err:  New(http.StatusUnprocessableEntity, CodeInvoiceAlreadyPaid, "invoice already paid"),
+			path: "/fail",
+			problem: Problem{
+				Type: "urn:invoice_microservice:problem:invoice_already_paid", Title: "Unprocessable Entity", Status: http.StatusUnprocessableEntity,
+				Detail: "invoice already paid", Instance: "/fail", Code: CodeInvoiceAlreadyPaid, CorrelationID: "req-1",
+			},
+		},
+		"validation": {
+			err:  Validation(validationErrors),
+			path: "/fail",
+			problem: Problem{
+				Type: "urn:invoice_microservice:problem:validation_failed", Title: "Bad Request", Status: http.StatusBadRequest,
+				Detail: "The payload is invalid.", Instance: "/fail", Code: CodeValidationFailed, CorrelationID: "req-1",
+				Errors: []FieldError{{Field: "user_id", Rule: "required", Message: "is required"}},
+			},
+		},
+		"echo": {
+			path: "/unknown",
+			problem: Problem{
+				Type: "urn:invoice_microservice:problem:not_found", Title: "Not Found", Status: http.StatusNotFound,
+				Detail: "Not Found", Instance: "/unknown", Code: CodeNotFound, CorrelationID: "req-1",
+			},
+		},
+		"internal": {
+			err:  errConnectionLost,
+			path: "/fail",
+			problem: Problem{
+				Type: "urn:invoice_microservice:problem:internal", Title: "Internal Server Error", Status: http.StatusInternalServerError,
+				Instance: "/fail", Code: CodeInternal, CorrelationID: "req-1",
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			e := echo.New()
+			e.HTTPErrorHandler = ErrorHandler
+			e.GET("/fail", func(echo.Context) error { return test.err })
+
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			req.Header.Set(echo.HeaderXRequestID, "req-1")
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.problem.Status, rec.Code)
+			assert.Equal(t, ContentType, rec.Header().Get(echo.HeaderContentType))
+			var problem Problem
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, test.problem, problem)
+			// Internal errors are only logged
+			assert.NotContains(t, rec.Body.String(), errConnectionLost.Error())
+		})
+	}
+}
+
+func TestErrorHandler_CorrelationID(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = ErrorHandler
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Len(t, problem.CorrelationID, 32)
+}
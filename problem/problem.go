@@ -0,0 +1,128 @@
+package problem
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ContentType is the media type of the problems, RFC 7807.
+const ContentType = "application/problem+json"
+
+// Codes identify the problems, clients branch on them rather than on the detail meant for humans.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeUnprocessable        = "unprocessable"
+	CodeUnavailable          = "unavailable"
+	CodeInternal             = "internal"
+
+	CodeMissingPermission           = "missing_permission"
+	CodeTenantRequired              = "tenant_required"
+	CodeTenantNotAllowed            = "tenant_not_allowed"
+	CodeUserNotFound                = "user_not_found"
+	CodeInvoiceNotFound             = "invoice_not_found"
+	CodeInvalidAmount               = "invalid_amount"
+	CodeInvoiceAlreadyPaid          = "invoice_already_paid"
+	CodeDatasetNotFound             = "dataset_not_found"
+	CodeExportJobNotFound           = "export_job_not_found"
+	CodeExportJobNotDone            = "export_job_not_done"
+	CodeTooManyExports              = "too_many_exports"
+	CodeWebhookSubscriptionNotFound = "webhook_subscription_not_found"
+	CodeWebhookDeliveryNotFound     = "webhook_delivery_not_found"
+)
+
+// typePrefix makes the codes URIs, as RFC 7807 expects for the type member.
+const typePrefix = "urn:invoice_microservice:problem:"
+
+// Problem is the body of the error responses.
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request.
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+	// CorrelationID identifies the request in the logs.
+	CorrelationID string       `json:"correlation_id,omitempty"`
+	Errors        []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError is a field of the payload failing a validation rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Error is an error returned to the client as a problem, the handlers return it for the errors of the client.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []FieldError
+}
+
+func New(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Detail)
+}
+
+// Validation returns the problem of a payload failing validation, with the rule failed by every field.
+func Validation(errs validator.ValidationErrors) *Error {
+	e := New(http.StatusBadRequest, CodeValidationFailed, "The payload is invalid.")
+	e.Fields = make([]FieldError, len(errs))
+	for i, fieldError := range errs {
+		e.Fields[i] = FieldError{
+			Field:   fieldName(fieldError),
+			Rule:    fieldError.Tag(),
+			Message: message(fieldError),
+		}
+	}
+	return e
+}
+
+// JSONName names the fields after their JSON name, register it with validator.Validate.RegisterTagNameFunc so that
+// the fields of the problems are the ones of the payload.
+func JSONName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// fieldName is the path of the field below the payload, with the names the validator was told to use.
+func fieldName(fieldError validator.FieldError) string {
+	namespace := fieldError.Namespace()
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func message(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must have at least " + fieldError.Param() + " elements"
+	case "http_url":
+		return "must be an http or https URL"
+	default:
+		return "fails the " + fieldError.Tag() + " rule"
+	}
+}
@@ -0,0 +1,77 @@
+package report
+
+import (
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+)
+
+// AgingBuckets are the labels of the buckets of an aging report, in the order of AgingAmounts.Buckets.
+var AgingBuckets = []string{"current", "1-30", "31-60", "61-90", "90+"}
+
+// AgingAmounts splits an outstanding amount by how many days it is past due.
+type AgingAmounts struct {
+	Current    money.Money
+	Days1To30  money.Money
+	Days31To60 money.Money
+	Days61To90 money.Money
+	Over90     money.Money
+}
+
+func (a AgingAmounts) Buckets() []money.Money {
+	return []money.Money{a.Current, a.Days1To30, a.Days31To60, a.Days61To90, a.Over90}
+}
+
+func (a AgingAmounts) Total() money.Money {
+	return a.Current + a.Days1To30 + a.Days31To60 + a.Days61To90 + a.Over90
+}
+
+func (a *AgingAmounts) add(daysPastDue int, amount money.Money) {
+	switch {
+	case daysPastDue <= 0:
+		a.Current += amount
+	case daysPastDue <= 30:
+		a.Days1To30 += amount
+	case daysPastDue <= 60:
+		a.Days31To60 += amount
+	case daysPastDue <= 90:
+		a.Days61To90 += amount
+	default:
+		a.Over90 += amount
+	}
+}
+
+type UserAging struct {
+	UserID int64
+	AgingAmounts
+}
+
+// Aging is the accounts receivable aging report as of a date.
+type Aging struct {
+	AsOf  time.Time
+	Users []UserAging
+	Total AgingAmounts
+}
+
+// NewAging buckets receivables by days past due as of asOf, receivables must be sorted by user.
+func NewAging(asOf time.Time, receivables []Receivable) Aging {
+	aging := Aging{AsOf: asOf, Users: []UserAging{}}
+	for _, receivable := range receivables {
+		if len(aging.Users) == 0 || aging.Users[len(aging.Users)-1].UserID != receivable.UserID {
+			aging.Users = append(aging.Users, UserAging{UserID: receivable.UserID})
+		}
+		days := daysBetween(receivable.DueAt, asOf)
+		aging.Users[len(aging.Users)-1].add(days, receivable.Outstanding)
+		aging.Total.add(days, receivable.Outstanding)
+	}
+	return aging
+}
+
+// daysBetween counts the calendar days from a to b in UTC.
+func daysBetween(a, b time.Time) int {
+	day := func(t time.Time) time.Time {
+		y, m, d := t.UTC().Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	return int(day(b).Sub(day(a)).Hours() / 24)
+}
@@ -0,0 +1,137 @@
+package report
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/labstack/echo/v4"
+)
+
+type AgingHandler struct {
+	reportRepository interface {
+		Receivables(ctx context.Context, at time.Time) ([]Receivable, error)
+	}
+	billing invoice.Billing
+}
+
+func NewAgingHandler(reportRepository *Repository, billing invoice.Billing) *AgingHandler {
+	return &AgingHandler{reportRepository: reportRepository, billing: billing}
+}
+
+type AgingHandlerResponse struct {
+	AsOf     time.Time       `json:"as_of"`
+	Currency string          `json:"currency"`
+	Users    []AgingResponse `json:"users"`
+	Total    AgingResponse   `json:"total"`
+}
+
+type AgingResponse struct {
+	UserID     int64   `json:"user_id,omitempty"`
+	Current    float64 `json:"current"`
+	Days1To30  float64 `json:"days_1_30"`
+	Days31To60 float64 `json:"days_31_60"`
+	Days61To90 float64 `json:"days_61_90"`
+	Over90     float64 `json:"over_90"`
+	Total      float64 `json:"total"`
+}
+
+func newAgingResponse(userID int64, a AgingAmounts) AgingResponse {
+	return AgingResponse{
+		UserID:     userID,
+		Current:    a.Current.ToFloat(),
+		Days1To30:  a.Days1To30.ToFloat(),
+		Days31To60: a.Days31To60.ToFloat(),
+		Days61To90: a.Days61To90.ToFloat(),
+		Over90:     a.Over90.ToFloat(),
+		Total:      a.Total().ToFloat(),
+	}
+}
+
+func (h AgingHandler) Handle(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	asOf, at := time.Now().UTC(), time.Now().UTC()
+	if v := c.QueryParam("as_of"); v != "" {
+		var err error
+		if asOf, at, err = parseAsOf(v); err != nil {
+			return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid as_of")
+		}
+	}
+
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "csv" {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid format")
+	}
+
+	receivables, err := h.reportRepository.Receivables(ctx, at)
+	if err != nil {
+		return fmt.Errorf("reportRepository.Receivables: %w", err)
+	}
+	aging := NewAging(asOf, receivables)
+
+	if format == "csv" {
+		c.Response().Header().Set(echo.HeaderContentType, "text/csv")
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"ar-aging-%s.csv\"", asOf.Format(time.DateOnly)))
+		c.Response().WriteHeader(http.StatusOK)
+		return writeAgingCSV(c.Response(), aging)
+	}
+
+	response := AgingHandlerResponse{
+		AsOf:     aging.AsOf,
+		Currency: h.billing.ForTenant(ctx).Currency,
+		Users:    make([]AgingResponse, len(aging.Users)),
+		Total:    newAgingResponse(0, aging.Total),
+	}
+	for i, u := range aging.Users {
+		response.Users[i] = newAgingResponse(u.UserID, u.AgingAmounts)
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// writeAgingCSV writes one row per user followed by a total row.
+func writeAgingCSV(w http.ResponseWriter, aging Aging) error {
+	writer := csv.NewWriter(w)
+	row := func(first string, a AgingAmounts) []string {
+		record := []string{first}
+		for _, amount := range a.Buckets() {
+			record = append(record, amount.String())
+		}
+		return append(record, a.Total().String())
+	}
+
+	header := append(append([]string{"user_id"}, AgingBuckets...), "total")
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	for _, u := range aging.Users {
+		if err := writer.Write(row(strconv.FormatInt(u.UserID, 10), u.AgingAmounts)); err != nil {
+			return fmt.Errorf("write row: %w", err)
+		}
+	}
+	if err := writer.Write(row("total", aging.Total)); err != nil {
+		return fmt.Errorf("write total: %w", err)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// parseAsOf returns the date the report is computed for and the instant the data is read at, a date alone covers
+// the whole day.
+func parseAsOf(s string) (asOf, at time.Time, err error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return t, t.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,32 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAging(t *testing.T) {
+	asOf := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) time.Time {
+		return asOf.AddDate(0, 0, -days)
+	}
+
+	aging := NewAging(asOf, []Receivable{
+		{InvoiceID: 1, UserID: 1, DueAt: asOf.AddDate(0, 0, 10), Outstanding: 100},
+		{InvoiceID: 2, UserID: 1, DueAt: daysAgo(0), Outstanding: 200},
+		{InvoiceID: 3, UserID: 1, DueAt: daysAgo(30), Outstanding: 300},
+		{InvoiceID: 4, UserID: 2, DueAt: daysAgo(31), Outstanding: 400},
+		{InvoiceID: 5, UserID: 2, DueAt: daysAgo(90), Outstanding: 500},
+		// Only the calendar day of the due date counts.
+		{InvoiceID: 6, UserID: 2, DueAt: daysAgo(91).Add(23 * time.Hour), Outstanding: 600},
+	})
+
+	assert.Equal(t, []UserAging{
+		{UserID: 1, AgingAmounts: AgingAmounts{Current: 300, Days1To30: 300}},
+		{UserID: 2, AgingAmounts: AgingAmounts{Days31To60: 400, Days61To90: 500, Over90: 600}},
+	}, aging.Users)
+	assert.Equal(t, AgingAmounts{Current: 300, Days1To30: 300, Days31To60: 400, Days61To90: 500, Over90: 600}, aging.Total)
+	assert.EqualValues(t, 2100, aging.Total.Total())
+}
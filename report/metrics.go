@@ -0,0 +1,54 @@
+package report
+
+import (
+	"context"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectTimeout bounds the query run on every scrape.
+const collectTimeout = 5 * time.Second
+
+// ReceivablesCollector exposes the outstanding receivables of every tenant per currency, they are read from the
+// database when scraped.
+type ReceivablesCollector struct {
+	reportRepository interface {
+		Outstanding(ctx context.Context, defaultCurrency string) (map[string]money.Money, error)
+	}
+	currency    string
+	outstanding *prometheus.Desc
+}
+
+// NewReceivablesCollector returns a collector whose metric name is prefixed with namespace, the tenants without a
+// currency of their own bill in currency.
+func NewReceivablesCollector(reportRepository *Repository, namespace, currency string) *ReceivablesCollector {
+	return &ReceivablesCollector{
+		reportRepository: reportRepository,
+		currency:         currency,
+		outstanding: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "outstanding_receivables"),
+			"Amount still to be paid on the invoices, tax included.",
+			[]string{"currency"}, nil,
+		),
+	}
+}
+
+func (c *ReceivablesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.outstanding
+}
+
+func (c *ReceivablesCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	outstanding, err := c.reportRepository.Outstanding(ctx, c.currency)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.outstanding, err)
+		return
+	}
+	for currency, amount := range outstanding {
+		ch <- prometheus.MustNewConstMetric(c.outstanding, prometheus.GaugeValue, amount.ToFloat(), currency)
+	}
+}
@@ -0,0 +1,41 @@
+package report
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceivablesCollector(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(`SELECT COALESCE(tn.currency, $1), SUM(r.outstanding)
+		FROM (
+			SELECT i.tenant_id, i.amount - COALESCE(SUM(t.amount), 0) AS outstanding
+			FROM jump.public.invoices i
+			LEFT JOIN jump.public.transactions t ON t.invoice_id = i.id
+			GROUP BY i.id, i.tenant_id, i.amount
+			HAVING i.amount - COALESCE(SUM(t.amount), 0) > 0
+		) r
+		JOIN jump.public.tenants tn ON tn.id = r.tenant_id
+		GROUP BY 1`).
+		WithArgs("EUR").
+		WillReturnRows(sqlmock.NewRows([]string{"currency", "outstanding"}).AddRow("EUR", 125050).AddRow("USD", 400))
+
+	expected := `
+		# HELP test_outstanding_receivables Amount still to be paid on the invoices, tax included.
+		# TYPE test_outstanding_receivables gauge
+		test_outstanding_receivables{currency="EUR"} 1250.5
+		test_outstanding_receivables{currency="USD"} 4
+	`
+	require.NoError(t, testutil.CollectAndCompare(NewReceivablesCollector(NewReportRepository(db), "test", "EUR"), strings.NewReader(expected)))
+
+	// Ensure all expectations were met
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,113 @@
+package report
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+)
+
+// Receivable is the part of an invoice that is still to be paid.
+type Receivable struct {
+	InvoiceID   int64
+	UserID      int64
+	DueAt       time.Time
+	Outstanding money.Money
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewReportRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Receivables returns the invoices issued before at that were not fully paid before at, partial payments are
+// deducted from the invoice amount.
+func (r *Repository) Receivables(ctx context.Context, at time.Time) (_ []Receivable, err error) {
+	ctx, span := storage.StartSpan(ctx, "report.Repository.Receivables", storage.OperationSelect, "invoices")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT i.id, i.user_id, i.due_at, i.amount - COALESCE(SUM(t.amount), 0) AS outstanding
+		FROM jump.public.invoices i
+		LEFT JOIN jump.public.transactions t ON t.invoice_id = i.id AND t.created_at < $2
+		WHERE i.tenant_id = $1 AND i.created_at < $2
+		GROUP BY i.id, i.user_id, i.due_at, i.amount
+		HAVING i.amount - COALESCE(SUM(t.amount), 0) > 0
+		ORDER BY i.user_id, i.id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receivables: %w", err)
+	}
+	defer rows.Close()
+
+	receivables := []Receivable{}
+	for rows.Next() {
+		var receivable Receivable
+		if err := rows.Scan(&receivable.InvoiceID, &receivable.UserID, &receivable.DueAt, &receivable.Outstanding); err != nil {
+			return nil, fmt.Errorf("failed to scan receivable: %w", err)
+		}
+		receivables = append(receivables, receivable)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read receivables: %w", err)
+	}
+
+	return receivables, nil
+}
+
+// Outstanding returns the amount still to be paid on the invoices of every tenant, per currency. The tenants without
+// a currency of their own bill in defaultCurrency.
+func (r *Repository) Outstanding(ctx context.Context, defaultCurrency string) (_ map[string]money.Money, err error) {
+	ctx, span := storage.StartSpan(ctx, "report.Repository.Outstanding", storage.OperationSelect, "invoices")
+	defer storage.EndSpan(span, &err)
+
+	query := `
+		SELECT COALESCE(tn.currency, $1), SUM(r.outstanding)
+		FROM (
+			SELECT i.tenant_id, i.amount - COALESCE(SUM(t.amount), 0) AS outstanding
+			FROM jump.public.invoices i
+			LEFT JOIN jump.public.transactions t ON t.invoice_id = i.id
+			GROUP BY i.id, i.tenant_id, i.amount
+			HAVING i.amount - COALESCE(SUM(t.amount), 0) > 0
+		) r
+		JOIN jump.public.tenants tn ON tn.id = r.tenant_id
+		GROUP BY 1
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, defaultCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outstanding amounts: %w", err)
+	}
+	defer rows.Close()
+
+	outstanding := map[string]money.Money{}
+	for rows.Next() {
+		var currency string
+		var amount money.Money
+		if err := rows.Scan(&currency, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan outstanding amount: %w", err)
+		}
+		outstanding[currency] += amount
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outstanding amounts: %w", err)
+	}
+
+	return outstanding, nil
+}
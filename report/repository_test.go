@@ -0,0 +1,116 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/tenant/tenanttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportRepository_Receivables(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewReportRepository(db)
+	at := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	dueAt := time.Date(2023, 6, 15, 0, 0, 0, 0, time.UTC)
+
+	// Mock the expected query and result
+	mock.ExpectQuery(`SELECT i.id, i.user_id, i.due_at, i.amount - COALESCE(SUM(t.amount), 0) AS outstanding
+		FROM jump.public.invoices i
+		LEFT JOIN jump.public.transactions t ON t.invoice_id = i.id AND t.created_at < $2
+		WHERE i.tenant_id = $1 AND i.created_at < $2
+		GROUP BY i.id, i.user_id, i.due_at, i.amount
+		HAVING i.amount - COALESCE(SUM(t.amount), 0) > 0
+		ORDER BY i.user_id, i.id`).
+		WithArgs("acme", at).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "due_at", "outstanding"}).
+			AddRow(10, 1, dueAt, 400))
+
+	// Call the Receivables method
+	receivables, err := repo.Receivables(tenanttest.Context(), at)
+	require.NoError(t, err)
+	assert.Equal(t, []Receivable{{InvoiceID: 10, UserID: 1, DueAt: dueAt, Outstanding: 400}}, receivables)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestReportRepository_Revenue(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewReportRepository(db)
+	q := SeriesQuery{
+		Granularity: "month",
+		From:        time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2023, 3, 1, 0, 0, 0, 0, time.UTC),
+		ByUser:      true,
+	}
+	january := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Mock the expected query and result
+	mock.ExpectQuery(`SELECT date_trunc($2, created_at, 'UTC') AS period, CASE WHEN $5 THEN user_id ELSE 0 END AS user_id, COUNT(*), SUM(amount)
+		FROM jump.public.invoices
+		WHERE tenant_id = $1 AND created_at >= $3 AND created_at < $4
+		GROUP BY 1, 2
+		ORDER BY 1, 2`).
+		WithArgs("acme", "month", q.From, q.To, true).
+		WillReturnRows(sqlmock.NewRows([]string{"period", "user_id", "count", "sum"}).
+			AddRow(january, 1, 2, 3000).
+			AddRow(january, 2, 1, 500))
+
+	// Call the Revenue method
+	points, err := repo.Revenue(tenanttest.Context(), q)
+	require.NoError(t, err)
+	assert.Equal(t, []Point{
+		{Period: january, UserID: 1, Count: 2, Amount: 3000},
+		{Period: january, UserID: 2, Count: 1, Amount: 500},
+	}, points)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestReportRepository_Collections(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewReportRepository(db)
+	q := SeriesQuery{
+		Granularity: "week",
+		From:        time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2023, 1, 16, 0, 0, 0, 0, time.UTC),
+	}
+	week := time.Date(2023, 1, 9, 0, 0, 0, 0, time.UTC)
+
+	// Mock the expected query and result
+	mock.ExpectQuery(`SELECT date_trunc($2, created_at, 'UTC') AS period, CASE WHEN $5 THEN user_id ELSE 0 END AS user_id, COUNT(*), SUM(amount)
+		FROM jump.public.transactions
+		WHERE tenant_id = $1 AND created_at >= $3 AND created_at < $4
+		GROUP BY 1, 2
+		ORDER BY 1, 2`).
+		WithArgs("acme", "week", q.From, q.To, false).
+		WillReturnRows(sqlmock.NewRows([]string{"period", "user_id", "count", "sum"}).
+			AddRow(week, 0, 3, 4500))
+
+	// Call the Collections method
+	points, err := repo.Collections(tenanttest.Context(), q)
+	require.NoError(t, err)
+	assert.Equal(t, []Point{{Period: week, Count: 3, Amount: 4500}}, points)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
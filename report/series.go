@@ -0,0 +1,90 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+)
+
+// Granularities are the periods series can be grouped by, they are date_trunc fields.
+var Granularities = map[string]bool{"day": true, "week": true, "month": true}
+
+// SeriesQuery selects the rows created in [From, To) and groups them by Granularity, and by user when ByUser is set.
+type SeriesQuery struct {
+	Granularity string
+	From        time.Time
+	To          time.Time
+	ByUser      bool
+}
+
+// Point is the aggregate of a period, UserID is zero unless the query is grouped by user.
+type Point struct {
+	Period time.Time
+	UserID int64
+	Count  int64
+	Amount money.Money
+}
+
+// Revenue returns the amounts invoiced per period.
+func (r *Repository) Revenue(ctx context.Context, q SeriesQuery) (_ []Point, err error) {
+	ctx, span := storage.StartSpan(ctx, "report.Repository.Revenue", storage.OperationSelect, "invoices")
+	defer storage.EndSpan(span, &err)
+
+	query := `
+		SELECT date_trunc($2, created_at, 'UTC') AS period, CASE WHEN $5 THEN user_id ELSE 0 END AS user_id, COUNT(*), SUM(amount)
+		FROM jump.public.invoices
+		WHERE tenant_id = $1 AND created_at >= $3 AND created_at < $4
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`
+
+	return r.series(ctx, query, q)
+}
+
+// Collections returns the amounts collected per period, as recorded by the transactions.
+func (r *Repository) Collections(ctx context.Context, q SeriesQuery) (_ []Point, err error) {
+	ctx, span := storage.StartSpan(ctx, "report.Repository.Collections", storage.OperationSelect, "transactions")
+	defer storage.EndSpan(span, &err)
+
+	query := `
+		SELECT date_trunc($2, created_at, 'UTC') AS period, CASE WHEN $5 THEN user_id ELSE 0 END AS user_id, COUNT(*), SUM(amount)
+		FROM jump.public.transactions
+		WHERE tenant_id = $1 AND created_at >= $3 AND created_at < $4
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`
+
+	return r.series(ctx, query, q)
+}
+
+func (r *Repository) series(ctx context.Context, query string, q SeriesQuery) ([]Point, error) {
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, q.Granularity, q.From, q.To, q.ByUser)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query series: %w", err)
+	}
+	defer rows.Close()
+
+	points := []Point{}
+	for rows.Next() {
+		var point Point
+		if err := rows.Scan(&point.Period, &point.UserID, &point.Count, &point.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan point: %w", err)
+		}
+		points = append(points, point)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read series: %w", err)
+	}
+
+	return points, nil
+}
@@ -0,0 +1,141 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/labstack/echo/v4"
+)
+
+type RevenueHandler struct {
+	reportRepository interface {
+		Revenue(ctx context.Context, q SeriesQuery) ([]Point, error)
+	}
+	billing invoice.Billing
+}
+
+func NewRevenueHandler(reportRepository *Repository, billing invoice.Billing) *RevenueHandler {
+	return &RevenueHandler{reportRepository: reportRepository, billing: billing}
+}
+
+type CollectionsHandler struct {
+	reportRepository interface {
+		Collections(ctx context.Context, q SeriesQuery) ([]Point, error)
+	}
+	billing invoice.Billing
+}
+
+func NewCollectionsHandler(reportRepository *Repository, billing invoice.Billing) *CollectionsHandler {
+	return &CollectionsHandler{reportRepository: reportRepository, billing: billing}
+}
+
+type SeriesHandlerResponse struct {
+	GroupBy  string          `json:"group_by"`
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Currency string          `json:"currency"`
+	Points   []PointResponse `json:"points"`
+	Total    float64         `json:"total"`
+}
+
+type PointResponse struct {
+	Period time.Time `json:"period"`
+	UserID int64     `json:"user_id,omitempty"`
+	Count  int64     `json:"count"`
+	Amount float64   `json:"amount"`
+}
+
+func (h RevenueHandler) Handle(c echo.Context) error {
+	q, err := parseSeriesQuery(c)
+	if err != nil {
+		return err
+	}
+
+	points, err := h.reportRepository.Revenue(c.Request().Context(), q)
+	if err != nil {
+		return fmt.Errorf("reportRepository.Revenue: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, newSeriesResponse(q, h.billing.ForTenant(c.Request().Context()).Currency, points))
+}
+
+func (h CollectionsHandler) Handle(c echo.Context) error {
+	q, err := parseSeriesQuery(c)
+	if err != nil {
+		return err
+	}
+
+	points, err := h.reportRepository.Collections(c.Request().Context(), q)
+	if err != nil {
+		return fmt.Errorf("reportRepository.Collections: %w", err)
+	}
+
+	return c.JSON(http.StatusOK, newSeriesResponse(q, h.billing.ForTenant(c.Request().Context()).Currency, points))
+}
+
+// parseSeriesQuery reads the group_by, by_user, from and to parameters, the period defaults to the last 30 days
+// grouped by day.
+func parseSeriesQuery(c echo.Context) (SeriesQuery, error) {
+	now := time.Now().UTC()
+	q := SeriesQuery{Granularity: "day", From: now.AddDate(0, 0, -30), To: now}
+
+	if v := c.QueryParam("group_by"); v != "" {
+		if !Granularities[v] {
+			return q, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid group_by, expected day, week or month")
+		}
+		q.Granularity = v
+	}
+	if v := c.QueryParam("by_user"); v != "" {
+		byUser, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid by_user")
+		}
+		q.ByUser = byUser
+	}
+
+	var err error
+	if v := c.QueryParam("from"); v != "" {
+		if q.From, _, err = parseAsOf(v); err != nil {
+			return q, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid from")
+		}
+	}
+	if v := c.QueryParam("to"); v != "" {
+		if q.To, _, err = parseAsOf(v); err != nil {
+			return q, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid to")
+		}
+	}
+	if !q.From.Before(q.To) {
+		return q, problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "from must be before to")
+	}
+
+	return q, nil
+}
+
+func newSeriesResponse(q SeriesQuery, currency string, points []Point) SeriesHandlerResponse {
+	response := SeriesHandlerResponse{
+		GroupBy:  q.Granularity,
+		From:     q.From,
+		To:       q.To,
+		Currency: currency,
+		Points:   make([]PointResponse, len(points)),
+	}
+	var total money.Money
+	for i, point := range points {
+		response.Points[i] = PointResponse{
+			Period: point.Period,
+			UserID: point.UserID,
+			Count:  point.Count,
+			Amount: point.Amount.ToFloat(),
+		}
+		total += point.Amount
+	}
+	response.Total = total.ToFloat()
+
+	return response
+}
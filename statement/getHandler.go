@@ -0,0 +1,139 @@
+package statement
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/labstack/echo/v4"
+)
+
+type GetHandler struct {
+	statementRepository interface {
+		Balance(ctx context.Context, userID int64, at time.Time) (money.Money, error)
+		Entries(ctx context.Context, userID int64, from, to time.Time) ([]Entry, error)
+	}
+	userRepository interface {
+		GetById(ctx context.Context, id int64) (*user.User, error)
+	}
+	billing invoice.Billing
+}
+
+func NewGetHandler(statementRepository *Repository, userRepository *user.Repository, billing invoice.Billing) *GetHandler {
+	return &GetHandler{statementRepository: statementRepository, userRepository: userRepository, billing: billing}
+}
+
+type GetStatementHandlerResponse struct {
+	UserID         int64               `json:"user_id"`
+	From           time.Time           `json:"from"`
+	To             time.Time           `json:"to"`
+	Currency       string              `json:"currency"`
+	OpeningBalance float64             `json:"opening_balance"`
+	Entries        []GetStatementEntry `json:"entries"`
+	ClosingBalance float64             `json:"closing_balance"`
+}
+
+type GetStatementEntry struct {
+	Date        time.Time `json:"date"`
+	Type        string    `json:"type"`
+	Reference   string    `json:"reference"`
+	Description string    `json:"description"`
+	Amount      float64   `json:"amount"`
+	Balance     float64   `json:"balance"`
+}
+
+func (h GetHandler) Handle(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid user id")
+	}
+
+	// The period defaults to the whole history of the user.
+	from, to := time.Unix(0, 0).UTC(), time.Now().UTC()
+	if v := c.QueryParam("from"); v != "" {
+		if from, err = parseDate(v); err != nil {
+			return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid from")
+		}
+	}
+	if v := c.QueryParam("to"); v != "" {
+		if to, err = parseDate(v); err != nil {
+			return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid to")
+		}
+	}
+	if !from.Before(to) {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "from must be before to")
+	}
+
+	format := c.QueryParam("format")
+	if format != "" && format != "json" && format != "pdf" {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid format")
+	}
+
+	u, err := h.userRepository.GetById(ctx, userID)
+	if err != nil {
+		if errors.Is(err, user.ErrUserNotFound) {
+			return problem.New(http.StatusNotFound, problem.CodeUserNotFound, "user not found")
+		}
+		return fmt.Errorf("userRepository.GetById: %w", err)
+	}
+
+	opening, err := h.statementRepository.Balance(ctx, userID, from)
+	if err != nil {
+		return fmt.Errorf("statementRepository.Balance: %w", err)
+	}
+	entries, err := h.statementRepository.Entries(ctx, userID, from, to)
+	if err != nil {
+		return fmt.Errorf("statementRepository.Entries: %w", err)
+	}
+	s := New(*u, from, to, opening, entries)
+	billing := h.billing.ForTenant(ctx)
+
+	if format == "pdf" {
+		var buf bytes.Buffer
+		if err := NewPDFRenderer(billing).Render(&buf, s); err != nil {
+			return fmt.Errorf("PDFRenderer.Render: %w", err)
+		}
+		c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("inline; filename=\"statement-%d.pdf\"", userID))
+		return c.Blob(http.StatusOK, "application/pdf", buf.Bytes())
+	}
+
+	response := GetStatementHandlerResponse{
+		UserID:         userID,
+		From:           s.From,
+		To:             s.To,
+		Currency:       billing.Currency,
+		OpeningBalance: s.OpeningBalance.ToFloat(),
+		Entries:        make([]GetStatementEntry, len(s.Lines)),
+		ClosingBalance: s.ClosingBalance.ToFloat(),
+	}
+	for i, line := range s.Lines {
+		response.Entries[i] = GetStatementEntry{
+			Date:        line.Date,
+			Type:        line.Type,
+			Reference:   reference(billing, line.Entry),
+			Description: line.Description,
+			Amount:      line.Amount.ToFloat(),
+			Balance:     line.Balance.ToFloat(),
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func parseDate(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Parse(time.DateOnly, s)
+	}
+	return t, nil
+}
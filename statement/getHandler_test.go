@@ -0,0 +1,47 @@
+package statement
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/emilien-puget/invoice_microservice/tenant/tenanttest"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type customerAuthenticator int64
+
+func (id customerAuthenticator) Authenticate(*http.Request) (*auth.Principal, error) {
+	return &auth.Principal{Role: auth.RoleCustomer, UserID: int64(id), TenantID: "acme"}, nil
+}
+
+func TestGetHandler_CrossTenant(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	billing := invoice.Billing{NumberFormat: "INV-%06d", Currency: "EUR"}
+	handler := NewGetHandler(NewStatementRepository(db), user.NewUserRepository(db), billing)
+
+	// The user lookup is scoped to the customer so user 2 is not found and no entry is read
+	query := "SELECT id, first_name, last_name, balance FROM jump.public.users WHERE id = $1 AND tenant_id = $2 AND ($3 = 0 OR id = $3)"
+	mock.ExpectPrepare(query)
+	mock.ExpectQuery(query).WithArgs(2, "acme", 1).WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "balance"}))
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.ErrorHandler
+	e.GET("/users/:id/statement", handler.Handle, auth.Middleware(customerAuthenticator(1)), tenanttest.Middleware, auth.RequirePermission(auth.ScopeUsersRead))
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/2/statement", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
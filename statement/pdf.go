@@ -0,0 +1,96 @@
+package statement
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/emilien-puget/invoice_microservice/invoice"
+	"github.com/go-pdf/fpdf"
+)
+
+const dateLayout = "2006-01-02"
+
+// PDFRenderer renders statements as PDF documents, the same statement always renders to the same bytes.
+type PDFRenderer struct {
+	billing invoice.Billing
+}
+
+func NewPDFRenderer(billing invoice.Billing) *PDFRenderer {
+	return &PDFRenderer{billing: billing}
+}
+
+func (r *PDFRenderer) Render(w io.Writer, s Statement) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetCreationDate(s.To.UTC())
+	pdf.SetModificationDate(s.To.UTC())
+	pdf.SetCatalogSort(true)
+	pdf.SetCompression(false)
+	pdf.SetTitle("Statement", true)
+	pdf.SetAuthor(r.billing.Seller.Name, true)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 6, tr(r.billing.Seller.Name), "", 1, "R", false, 0, "")
+	pdf.Ln(4)
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, "Account statement", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 5, tr(s.User.FirstName+" "+s.User.LastName), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5, fmt.Sprintf("Period: %s to %s", s.From.UTC().Format(dateLayout), s.To.UTC().Format(dateLayout)), "", 1, "L", false, 0, "")
+	pdf.Ln(6)
+
+	widths := []float64{25, 25, 65, 25, 25, 25}
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(230, 230, 230)
+	for i, title := range []string{"Date", "Reference", "Description", "Debit", "Credit", "Balance"} {
+		align := "L"
+		if i > 2 {
+			align = "R"
+		}
+		pdf.CellFormat(widths[i], 7, title, "1", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 9)
+	row := func(date, reference, description, debit, credit, balance string) {
+		for i, value := range []string{date, reference, description, debit, credit, balance} {
+			align := "L"
+			if i > 2 {
+				align = "R"
+			}
+			pdf.CellFormat(widths[i], 6, tr(value), "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	row(s.From.UTC().Format(dateLayout), "", "Opening balance", "", "", s.OpeningBalance.String())
+	for _, line := range s.Lines {
+		debit, credit := "", ""
+		if line.Amount >= 0 {
+			debit = line.Amount.String()
+		} else {
+			credit = (-line.Amount).String()
+		}
+		row(line.Date.UTC().Format(dateLayout), reference(r.billing, line.Entry), line.Description, debit, credit, line.Balance.String())
+	}
+	pdf.SetFont("Helvetica", "B", 9)
+	row(s.To.UTC().Format(dateLayout), "", "Closing balance", "", "", s.ClosingBalance.String())
+
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 5, fmt.Sprintf("Amounts in %s, a positive balance is owed to %s.", r.billing.Currency, tr(r.billing.Seller.Name)), "", 1, "L", false, 0, "")
+
+	if err := pdf.Output(w); err != nil {
+		return fmt.Errorf("pdf.Output: %w", err)
+	}
+	return nil
+}
+
+// reference returns the identifier of the document behind the entry as the user knows it.
+func reference(billing invoice.Billing, e Entry) string {
+	if e.Type == EntryInvoice {
+		return billing.Number(&invoice.Invoice{ID: e.DocumentID, Number: e.Number})
+	}
+	return fmt.Sprintf("%s-%d", e.Type, e.DocumentID)
+}
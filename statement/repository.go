@@ -0,0 +1,103 @@
+package statement
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+)
+
+const (
+	EntryInvoice = "invoice"
+	EntryPayment = "payment"
+)
+
+// Entry is a movement on the account of a user, a positive amount increases what the user owes.
+type Entry struct {
+	Type       string
+	DocumentID int64
+	// Number is the number of an invoice entry.
+	Number      int64
+	Description string
+	Amount      money.Money
+	Date        time.Time
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewStatementRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Balance returns what the user owed right before at: the invoices issued minus the payments received.
+func (r *Repository) Balance(ctx context.Context, userID int64, at time.Time) (_ money.Money, err error) {
+	ctx, span := storage.StartSpan(ctx, "statement.Repository.Balance", storage.OperationSelect, "")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		SELECT
+			COALESCE((SELECT SUM(amount) FROM jump.public.invoices WHERE tenant_id = $1 AND user_id = $2 AND created_at < $3), 0)
+			- COALESCE((SELECT SUM(amount) FROM jump.public.transactions WHERE tenant_id = $1 AND user_id = $2 AND created_at < $3), 0)
+	`
+
+	var balance money.Money
+	if err := storage.Conn(ctx, r.db).QueryRowContext(ctx, storage.Dialect(r.db, query), tenantID, userID, at).Scan(&balance); err != nil {
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+// Entries returns the invoices and payments of the user in [from, to), oldest first.
+func (r *Repository) Entries(ctx context.Context, userID int64, from, to time.Time) (_ []Entry, err error) {
+	ctx, span := storage.StartSpan(ctx, "statement.Repository.Entries", storage.OperationSelect, "")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT 'invoice', id, number, label, amount, created_at
+		FROM jump.public.invoices
+		WHERE tenant_id = $1 AND user_id = $2 AND created_at >= $3 AND created_at < $4
+		UNION ALL
+		SELECT 'payment', id, 0, reference, -amount, created_at
+		FROM jump.public.transactions
+		WHERE tenant_id = $1 AND user_id = $2 AND created_at >= $3 AND created_at < $4
+		ORDER BY 6, 1, 2
+	`
+
+	rows, err := storage.Conn(ctx, r.db).QueryContext(ctx, storage.Dialect(r.db, query), tenantID, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []Entry{}
+	for rows.Next() {
+		var entry Entry
+		if err := rows.Scan(&entry.Type, &entry.DocumentID, &entry.Number, &entry.Description, &entry.Amount, &entry.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read entries: %w", err)
+	}
+
+	return entries, nil
+}
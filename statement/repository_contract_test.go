@@ -0,0 +1,53 @@
+package statement
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/storage/storagetest"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementRepository_Contract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, ctx context.Context, db *sql.DB) {
+		conn := storage.Conn(ctx, db)
+		_, err := conn.ExecContext(ctx, `INSERT INTO tenants (id, name) VALUES ('storagetest', 'Storage test')`)
+		require.NoError(t, err)
+		var userID, invoiceID, transactionID int64
+		err = conn.QueryRowContext(ctx, `INSERT INTO users (first_name, last_name, tenant_id) VALUES ('Ada', 'Lovelace', 'storagetest') RETURNING id`).Scan(&userID)
+		require.NoError(t, err)
+		err = conn.QueryRowContext(ctx, `INSERT INTO invoices (tenant_id, number, user_id, label, amount, due_at) VALUES ('storagetest', 1, $1, 'Consulting', 1500, $2) RETURNING id`,
+			userID, time.Now()).Scan(&invoiceID)
+		require.NoError(t, err)
+		err = conn.QueryRowContext(ctx, `INSERT INTO transactions (tenant_id, invoice_id, user_id, amount, reference) VALUES ('storagetest', $1, $2, 500, 'PAY-1') RETURNING id`,
+			invoiceID, userID).Scan(&transactionID)
+		require.NoError(t, err)
+		ctx = tenant.NewContext(ctx, &tenant.Tenant{ID: "storagetest"})
+		repo := NewStatementRepository(db)
+
+		balance, err := repo.Balance(ctx, userID, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, money.Money(1000), balance)
+		balance, err = repo.Balance(ctx, userID, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, balance, "the entries created after at are left out")
+
+		entries, err := repo.Entries(ctx, userID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		for i := range entries {
+			assert.False(t, entries[i].Date.IsZero())
+			entries[i].Date = time.Time{}
+		}
+		assert.ElementsMatch(t, []Entry{
+			{Type: EntryInvoice, DocumentID: invoiceID, Number: 1, Description: "Consulting", Amount: 1500},
+			{Type: EntryPayment, DocumentID: transactionID, Description: "PAY-1", Amount: -500},
+		}, entries)
+	})
+}
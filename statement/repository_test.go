@@ -0,0 +1,69 @@
+package statement
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatementRepository_Balance(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewStatementRepository(db)
+	at := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	// Mock the expected query and result
+	mock.ExpectQuery("SELECT COALESCE((SELECT SUM(amount) FROM jump.public.invoices WHERE tenant_id = $1 AND user_id = $2 AND created_at < $3), 0) - COALESCE((SELECT SUM(amount) FROM jump.public.transactions WHERE tenant_id = $1 AND user_id = $2 AND created_at < $3), 0)").
+		WithArgs("acme", 1, at).
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(1500))
+
+	// Call the Balance method
+	balance, err := repo.Balance(tenant.NewContext(context.Background(), &tenant.Tenant{ID: "acme"}), 1, at)
+	require.NoError(t, err)
+	assert.Equal(t, money.Money(1500), balance)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestStatementRepository_Entries(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewStatementRepository(db)
+	from := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	invoicedAt := time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC)
+	paidAt := time.Date(2023, 7, 3, 0, 0, 0, 0, time.UTC)
+
+	// Mock the expected query and result
+	mock.ExpectQuery("SELECT 'invoice', id, number, label, amount, created_at FROM jump.public.invoices WHERE tenant_id = $1 AND user_id = $2 AND created_at >= $3 AND created_at < $4 UNION ALL SELECT 'payment', id, 0, reference, -amount, created_at FROM jump.public.transactions WHERE tenant_id = $1 AND user_id = $2 AND created_at >= $3 AND created_at < $4 ORDER BY 6, 1, 2").
+		WithArgs("acme", 1, from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "id", "number", "description", "amount", "created_at"}).
+			AddRow(EntryInvoice, 10, 4, "Consulting", 1000, invoicedAt).
+			AddRow(EntryPayment, 20, 0, "REF-1", -1000, paidAt))
+
+	// Call the Entries method
+	entries, err := repo.Entries(tenant.NewContext(context.Background(), &tenant.Tenant{ID: "acme"}), 1, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{Type: EntryInvoice, DocumentID: 10, Number: 4, Description: "Consulting", Amount: 1000, Date: invoicedAt},
+		{Type: EntryPayment, DocumentID: 20, Description: "REF-1", Amount: -1000, Date: paidAt},
+	}, entries)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
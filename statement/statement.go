@@ -0,0 +1,41 @@
+package statement
+
+import (
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/user"
+)
+
+// Line is an entry along with the balance once it is applied.
+type Line struct {
+	Entry
+	Balance money.Money
+}
+
+// Statement lists what happened on the account of a user during [From, To).
+// Refunds and credit notes are not modelled by the service yet, so only invoices and payments show up.
+type Statement struct {
+	User           user.User
+	From           time.Time
+	To             time.Time
+	OpeningBalance money.Money
+	Lines          []Line
+	ClosingBalance money.Money
+}
+
+func New(u user.User, from, to time.Time, opening money.Money, entries []Entry) Statement {
+	s := Statement{
+		User:           u,
+		From:           from,
+		To:             to,
+		OpeningBalance: opening,
+		Lines:          make([]Line, len(entries)),
+		ClosingBalance: opening,
+	}
+	for i, entry := range entries {
+		s.ClosingBalance += entry.Amount
+		s.Lines[i] = Line{Entry: entry, Balance: s.ClosingBalance}
+	}
+	return s
+}
@@ -0,0 +1,24 @@
+package statement
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/user"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	entries := []Entry{
+		{Type: EntryInvoice, DocumentID: 1, Amount: 1000},
+		{Type: EntryInvoice, DocumentID: 2, Amount: 500},
+		{Type: EntryPayment, DocumentID: 1, Amount: -1000},
+	}
+
+	s := New(user.User{ID: 1}, time.Time{}, time.Time{}, 200, entries)
+
+	assert.Equal(t, money.Money(200), s.OpeningBalance)
+	assert.Equal(t, []money.Money{1200, 1700, 700}, []money.Money{s.Lines[0].Balance, s.Lines[1].Balance, s.Lines[2].Balance})
+	assert.Equal(t, money.Money(700), s.ClosingBalance)
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion returns the version of the last migration applied by golang-migrate, zero when none was, and whether
+// it failed midway.
+func SchemaVersion(ctx context.Context, db *sql.DB) (version uint, dirty bool, err error) {
+	ctx, span := StartSpan(ctx, "storage.SchemaVersion", OperationSelect, "schema_migrations")
+	defer EndSpan(span, &err)
+
+	query := `SELECT version, dirty FROM jump.public.schema_migrations LIMIT 1`
+
+	if err := db.QueryRowContext(ctx, Dialect(db, query)).Scan(&version, &dirty); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, dirty, nil
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/lib/pq"
+)
+
+// The drivers of PostgreSQL, the repositories run the same queries on both.
+const (
+	DriverPQ  = "pq"
+	DriverPgx = "pgx"
+)
+
+// DriverSQLite is the driver of SQLite, for the single node deployments and the tests. The outbox relay, the webhooks
+// and the reports are specific to PostgreSQL.
+const DriverSQLite = "sqlite"
+
+// Drivers are the drivers Open accepts.
+var Drivers = []string{DriverPQ, DriverPgx, DriverSQLite}
+
+var ErrUnknownDriver = errors.New("unknown database driver, expected pq, pgx or sqlite")
+
+// Open returns the pool of connections to the database of dsn, through lib/pq or jackc/pgx. With DriverSQLite, dsn is
+// the path of the database file.
+func Open(driver, dsn string) (*sql.DB, error) {
+	var name string
+	switch driver {
+	case DriverPQ:
+		name = "postgres"
+	case DriverPgx:
+		name = "pgx"
+	case DriverSQLite:
+		name = sqliteDriverName
+		dsn = sqliteDSN(dsn)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownDriver, driver)
+	}
+
+	db, err := sql.Open(name, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("sql.Open: %w", err)
+	}
+	return db, nil
+}
+
+// Array returns the argument binding the slice a to a PostgreSQL array parameter. pgx encodes the slices in the binary
+// format of the arrays itself, lib/pq needs them wrapped by pq.Array.
+func Array(db *sql.DB, a any) any {
+	if _, ok := db.Driver().(*stdlib.Driver); ok {
+		return a
+	}
+	return pq.Array(a)
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArray(t *testing.T) {
+	ids := []int64{1, 2}
+
+	for name, test := range map[string]struct {
+		driver string
+		want   any
+	}{
+		"pgx encodes the slice": {
+			driver: DriverPgx,
+			want:   ids,
+		},
+		"lib/pq needs it wrapped": {
+			driver: DriverPQ,
+			want:   (*pq.Int64Array)(&ids),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Open does not connect, the database does not need to exist
+			db, err := Open(test.driver, "postgres://localhost/jump")
+			require.NoError(t, err)
+			defer db.Close()
+
+			assert.Equal(t, test.want, Array(db, ids))
+		})
+	}
+}
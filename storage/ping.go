@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/logging"
+)
+
+// Ping pings db until it answers or ctx is done, waiting backoff between the attempts, doubled every attempt up to
+// maxBackoff, so that the service starts while the database is still starting too. The error returned once ctx is
+// done is the last one of the database, rather than the one of ctx.
+func Ping(ctx context.Context, db *sql.DB, backoff, maxBackoff time.Duration) error {
+	var last error
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil && last != nil {
+			return fmt.Errorf("db.PingContext: %d attempts: %w", attempt-1, last)
+		}
+		last = err
+		logging.FromContext(ctx).Warn("database not ready", slog.Int("attempt", attempt), slog.Duration("retry_in", backoff), slog.Any("error", err))
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("db.PingContext: %d attempts: %w", attempt, err)
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
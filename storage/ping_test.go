@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errNotReady = errors.New("the database system is starting up")
+
+func TestPing(t *testing.T) {
+	tests := map[string]struct {
+		failures int
+		timeout  time.Duration
+		wantErr  bool
+	}{
+		"ready": {
+			timeout: time.Second,
+		},
+		"ready after retries": {
+			failures: 3,
+			timeout:  time.Second,
+		},
+		"never ready": {
+			failures: 100,
+			timeout:  50 * time.Millisecond,
+			wantErr:  true,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Create a new mock database connection
+			db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+			require.NoError(t, err)
+			defer db.Close()
+			for i := 0; i < tt.failures; i++ {
+				mock.ExpectPing().WillReturnError(errNotReady)
+			}
+			if !tt.wantErr {
+				mock.ExpectPing()
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			err = Ping(ctx, db, time.Millisecond, 10*time.Millisecond)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, errNotReady)
+				return
+			}
+			require.NoError(t, err)
+
+			// Ensure all expectations were met
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// sqliteDriverName is the name the SQLite driver of Open is registered under, modernc.org/sqlite registers its own
+// driver as sqlite.
+const sqliteDriverName = "jump-sqlite"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqliteDriver{})
+}
+
+// sqliteParams are added to the DSN of SQLite: the foreign keys are enforced, a writer waits for the others instead of
+// failing, transactions take the write lock when they begin so that the read of a row and its update cannot interleave
+// with another transaction, and times are written in a format that compares in order.
+var sqliteParams = []string{
+	"_pragma=foreign_keys(1)",
+	"_pragma=busy_timeout(5000)",
+	"_pragma=journal_mode(WAL)",
+	"_txlock=immediate",
+	"_time_format=sqlite",
+}
+
+// sqliteDSN returns the DSN of the database file path, with sqliteParams.
+func sqliteDSN(path string) string {
+	if !strings.HasPrefix(path, "file:") {
+		path = "file:" + path
+	}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + strings.Join(sqliteParams, "&")
+}
+
+// IsSQLite reports whether db was opened with DriverSQLite, the repositories run the queries of its dialect where
+// they differ from those of PostgreSQL.
+func IsSQLite(db *sql.DB) bool {
+	_, ok := db.Driver().(*sqliteDriver)
+	return ok
+}
+
+// sqliteTables names the tables of SQLite, which has neither databases nor schemas.
+var sqliteTables = strings.NewReplacer("jump.public.", "")
+
+// Dialect returns the query of PostgreSQL in the dialect of db, the tables are named without the database and the
+// schema on SQLite. The queries whose SQL differs otherwise are written for each.
+func Dialect(db *sql.DB, query string) string {
+	if IsSQLite(db) {
+		return sqliteTables.Replace(query)
+	}
+	return query
+}
+
+// sqliteDriver wraps the driver of modernc.org/sqlite, which is written in Go and builds with CGO disabled, to write
+// the times in UTC.
+type sqliteDriver struct {
+	sqlite.Driver
+}
+
+// sqliteBackend is what sqliteConn needs of the connections of modernc.org/sqlite.
+type sqliteBackend interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+func (d *sqliteDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	backend, ok := conn.(sqliteBackend)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected connection %T", conn)
+	}
+	return &sqliteConn{sqliteBackend: backend}, nil
+}
+
+type sqliteConn struct {
+	sqliteBackend
+}
+
+// CheckNamedValue writes the times in UTC, so that they compare in order whatever their location. The other values
+// are converted the default way.
+func (c *sqliteConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if t, ok := nv.Value.(time.Time); ok {
+		nv.Value = t.UTC()
+		return nil
+	}
+	return driver.ErrSkip
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialect(t *testing.T) {
+	const query = `SELECT id FROM jump.public.invoices JOIN jump.public.users ON users.id = invoices.user_id`
+
+	for name, test := range map[string]struct {
+		driver string
+		dsn    string
+		want   string
+	}{
+		"postgresql": {
+			driver: DriverPgx,
+			dsn:    "postgres://localhost/jump",
+			want:   query,
+		},
+		"sqlite": {
+			driver: DriverSQLite,
+			dsn:    filepath.Join(t.TempDir(), "jump.db"),
+			want:   `SELECT id FROM invoices JOIN users ON users.id = invoices.user_id`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			db, err := Open(test.driver, test.dsn)
+			require.NoError(t, err)
+			defer db.Close()
+
+			assert.Equal(t, test.want, Dialect(db, query))
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestSchema describes the schema the migrations of every driver lead to, table by table, and compares it with the
+// golden file so that the SQLite and the PostgreSQL migrations stay equivalent. The checks are left out, SQLite
+// checks the values PostgreSQL stores as enums.
+func TestSchema(t *testing.T) {
+	golden := filepath.Join("testdata", "schema.golden")
+
+	for _, driver := range storage.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			db := open(t, ctx, driver)
+
+			describe := describePostgres
+			if storage.IsSQLite(db) {
+				describe = describeSQLite
+			}
+			schema, err := describeSchema(ctx, db, describe)
+			require.NoError(t, err)
+
+			if *update && driver == storage.DriverSQLite {
+				require.NoError(t, os.WriteFile(golden, []byte(schema), 0o644))
+			}
+			expected, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), schema)
+		})
+	}
+}
+
+// table is the description of a table common to both dialects. The types are the classes of values stored, the
+// columns of the primary key are not null.
+type table struct {
+	name        string
+	columns     []string
+	primaryKey  string
+	uniques     []string
+	foreignKeys []string
+}
+
+// dialect queries the catalog of a database.
+type dialect struct {
+	tables      string
+	columns     string
+	primaryKey  string
+	uniques     string
+	foreignKeys string
+	// class returns the class of the values of a column type.
+	class func(columnType string) string
+}
+
+var describeSQLite = dialect{
+	tables:  `SELECT name FROM sqlite_schema WHERE type = 'table' AND name NOT LIKE 'sqlite_%' AND name <> 'schema_migrations'`,
+	columns: `SELECT name, type, "notnull" OR pk > 0 FROM pragma_table_info($1)`,
+	primaryKey: `SELECT coalesce(group_concat(name, ', '), '') FROM (
+		SELECT name FROM pragma_table_info($1) WHERE pk > 0 ORDER BY pk
+	)`,
+	uniques: `SELECT (
+		SELECT group_concat(name, ', ') FROM (SELECT name FROM pragma_index_info(l.name) ORDER BY seqno)
+	) FROM pragma_index_list($1) AS l WHERE l."unique" AND l.origin <> 'pk'`,
+	foreignKeys: `SELECT group_concat("from", ', '), "table", group_concat("to", ', ') FROM (
+		SELECT id, seq, "from", "table", "to" FROM pragma_foreign_key_list($1) ORDER BY id, seq
+	) GROUP BY id`,
+	class: func(columnType string) string {
+		// The rules of the type affinity of SQLite, the times are text in the format of the driver
+		columnType = strings.ToUpper(columnType)
+		switch {
+		case strings.Contains(columnType, "INT"):
+			return "integer"
+		case strings.HasPrefix(columnType, "TIMESTAMP"):
+			return "timestamp"
+		case strings.Contains(columnType, "CHAR"), strings.Contains(columnType, "CLOB"), strings.Contains(columnType, "TEXT"):
+			return "text"
+		case strings.Contains(columnType, "REAL"), strings.Contains(columnType, "FLOA"), strings.Contains(columnType, "DOUB"):
+			return "real"
+		default:
+			return columnType
+		}
+	},
+}
+
+var describePostgres = dialect{
+	tables: `SELECT table_name FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_type = 'BASE TABLE' AND table_name <> 'schema_migrations'`,
+	columns: `SELECT column_name, data_type, is_nullable = 'NO' FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = $1`,
+	primaryKey: `SELECT coalesce(string_agg(a.attname, ', ' ORDER BY k.ord), '')
+		FROM pg_index i
+		CROSS JOIN LATERAL unnest(i.indkey::int2[]) WITH ORDINALITY AS k (attnum, ord)
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+		WHERE i.indrelid = ('public.' || $1::text)::regclass AND i.indisprimary`,
+	uniques: `SELECT (
+			SELECT string_agg(a.attname, ', ' ORDER BY k.ord)
+			FROM unnest(i.indkey::int2[]) WITH ORDINALITY AS k (attnum, ord)
+			JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+		)
+		FROM pg_index i
+		WHERE i.indrelid = ('public.' || $1::text)::regclass AND i.indisunique AND NOT i.indisprimary`,
+	foreignKeys: `SELECT (
+			SELECT string_agg(a.attname, ', ' ORDER BY k.ord)
+			FROM unnest(c.conkey) WITH ORDINALITY AS k (attnum, ord)
+			JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+		), (
+			SELECT relname FROM pg_class WHERE oid = c.confrelid
+		), (
+			SELECT string_agg(a.attname, ', ' ORDER BY k.ord)
+			FROM unnest(c.confkey) WITH ORDINALITY AS k (attnum, ord)
+			JOIN pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.attnum
+		)
+		FROM pg_constraint c
+		WHERE c.conrelid = ('public.' || $1::text)::regclass AND c.contype = 'f'`,
+	class: func(columnType string) string {
+		switch columnType {
+		case "integer", "bigint":
+			return "integer"
+		case "timestamp with time zone":
+			return "timestamp"
+		// The arrays, the JSON documents and the enums are text on SQLite
+		case "character varying", "character", "text", "ARRAY", "jsonb", "USER-DEFINED":
+			return "text"
+		case "double precision":
+			return "real"
+		default:
+			return columnType
+		}
+	},
+}
+
+// describeSchema writes the tables of db in name order, and their columns, constraints and indexes in name order.
+func describeSchema(ctx context.Context, db *sql.DB, d dialect) (string, error) {
+	names, err := queryStrings(ctx, db, d.tables)
+	if err != nil {
+		return "", fmt.Errorf("failed to list tables: %w", err)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		t, err := describeTable(ctx, db, d, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to describe %s: %w", name, err)
+		}
+		fmt.Fprintf(&b, "table %s\n", t.name)
+		for _, column := range t.columns {
+			fmt.Fprintf(&b, "\t%s\n", column)
+		}
+		fmt.Fprintf(&b, "\tprimary key (%s)\n", t.primaryKey)
+		for _, unique := range t.uniques {
+			fmt.Fprintf(&b, "\tunique (%s)\n", unique)
+		}
+		for _, foreignKey := range t.foreignKeys {
+			fmt.Fprintf(&b, "\tforeign key %s\n", foreignKey)
+		}
+	}
+	return b.String(), nil
+}
+
+func describeTable(ctx context.Context, db *sql.DB, d dialect, name string) (table, error) {
+	t := table{name: name}
+
+	rows, err := db.QueryContext(ctx, d.columns, name)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var column, columnType string
+		var notNull bool
+		if err := rows.Scan(&column, &columnType, &notNull); err != nil {
+			return t, err
+		}
+		description := column + " " + d.class(columnType)
+		if notNull {
+			description += " not null"
+		}
+		t.columns = append(t.columns, description)
+	}
+	if err := rows.Err(); err != nil {
+		return t, err
+	}
+	sort.Strings(t.columns)
+
+	if err := db.QueryRowContext(ctx, d.primaryKey, name).Scan(&t.primaryKey); err != nil {
+		return t, err
+	}
+	if t.uniques, err = queryStrings(ctx, db, d.uniques, name); err != nil {
+		return t, err
+	}
+	sort.Strings(t.uniques)
+
+	rows, err = db.QueryContext(ctx, d.foreignKeys, name)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var from, referenced, to string
+		if err := rows.Scan(&from, &referenced, &to); err != nil {
+			return t, err
+		}
+		t.foreignKeys = append(t.foreignKeys, fmt.Sprintf("(%s) references %s (%s)", from, referenced, to))
+	}
+	if err := rows.Err(); err != nil {
+		return t, err
+	}
+	sort.Strings(t.foreignKeys)
+	return t, nil
+}
+
+func queryStrings(ctx context.Context, db *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,100 @@
+// Package storagetest runs the tests of the repositories against every driver of storage.Open, so that the
+// repositories honour the same contract on PostgreSQL and SQLite.
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/emilien-puget/invoice_microservice/migrations"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// DSNEnv names the environment variable of the PostgreSQL database the tests run against, the PostgreSQL drivers are
+// skipped when it is not set, and fail on CI. The queries name the database, it must be jump. It is migrated by the tests. SQLite
+// runs on a database file created for each test. The fixtures of the tests name the tables without the database and
+// the schema, so that the same statements run on both.
+const DSNEnv = "TEST_POSTGRES_DSN"
+
+// migrationLock is the key of the advisory lock taken while migrating, the packages are tested concurrently.
+const migrationLock = 7_220_001
+
+var errRollback = errors.New("rolled back by storagetest")
+
+// Run calls fn once per driver with a context carrying a transaction, which is rolled back once fn returns so that
+// the repositories joining it leave nothing behind.
+func Run(t *testing.T, fn func(t *testing.T, ctx context.Context, db *sql.DB)) {
+	t.Helper()
+
+	for _, driver := range storage.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			db := open(t, ctx, driver)
+
+			err := storage.WithinTx(ctx, db, func(ctx context.Context) error {
+				fn(t, ctx, db)
+				return errRollback
+			})
+			require.ErrorIs(t, err, errRollback)
+		})
+	}
+}
+
+// open returns the migrated database of driver.
+func open(t *testing.T, ctx context.Context, driver string) *sql.DB {
+	t.Helper()
+
+	dsn := filepath.Join(t.TempDir(), "jump.db")
+	all, err := migrations.SQLite()
+	if driver != storage.DriverSQLite {
+		dsn = os.Getenv(DSNEnv)
+		if dsn == "" && os.Getenv("CI") != "" {
+			t.Fatalf("%s is not set, the PostgreSQL drivers must be tested on CI", DSNEnv)
+		}
+		if dsn == "" {
+			t.Skipf("%s is not set", DSNEnv)
+		}
+		all, err = migrations.All()
+	}
+	require.NoError(t, err)
+
+	db, err := storage.Open(driver, dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	require.NoError(t, migrate(ctx, db, all))
+	return db
+}
+
+// migrate applies the migrations the database misses, the packages migrating PostgreSQL concurrently wait for each
+// other.
+func migrate(ctx context.Context, db *sql.DB, all []migrations.Migration) (err error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("db.Conn: %w", err)
+	}
+	defer conn.Close()
+
+	// SQLite locks the database file in the transactions instead.
+	if !storage.IsSQLite(db) {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLock); err != nil {
+			return fmt.Errorf("failed to lock migrations: %w", err)
+		}
+		defer func() {
+			if _, unlockErr := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLock); unlockErr != nil && err == nil {
+				err = fmt.Errorf("failed to unlock migrations: %w", unlockErr)
+			}
+		}()
+	}
+
+	if err := migrations.Up(ctx, conn, all); err != nil {
+		return fmt.Errorf("migrations.Up: %w", err)
+	}
+	return nil
+}
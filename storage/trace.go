@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/emilien-puget/invoice_microservice/storage"
+
+// The SQL operations of the repository spans.
+const (
+	OperationSelect = "SELECT"
+	OperationInsert = "INSERT"
+	OperationUpdate = "UPDATE"
+)
+
+// StartSpan starts the span of a repository method, named after it, e.g. invoice.Repository.GetByID, with the SQL
+// operation it runs on table, table is empty when the query reads several tables. End it with EndSpan.
+func StartSpan(ctx context.Context, name, operation, table string) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBNamespace("jump"),
+		semconv.DBOperationName(operation),
+	}
+	if table != "" {
+		attributes = append(attributes, semconv.DBCollectionName(table))
+	}
+
+	// The tracer is looked up on every call so that the spans go to the provider installed last
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+// EndSpan records the error *err points to, if any, and ends span. It is meant to be deferred with the named error
+// result of the repository method.
+func EndSpan(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
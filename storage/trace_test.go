@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/emilien-puget/invoice_microservice/tracing/tracingtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errQuery = errors.New("query failed")
+
+func TestStartSpan(t *testing.T) {
+	for name, test := range map[string]struct {
+		table      string
+		err        error
+		attributes []attribute.KeyValue
+		status     codes.Code
+	}{
+		"succeeded": {
+			table: "invoices",
+			attributes: []attribute.KeyValue{
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.namespace", "jump"),
+				attribute.String("db.operation.name", "SELECT"),
+				attribute.String("db.collection.name", "invoices"),
+			},
+			status: codes.Unset,
+		},
+		"several tables": {
+			attributes: []attribute.KeyValue{
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.namespace", "jump"),
+				attribute.String("db.operation.name", "SELECT"),
+			},
+			status: codes.Unset,
+		},
+		"failed": {
+			table: "invoices",
+			err:   errQuery,
+			attributes: []attribute.KeyValue{
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.namespace", "jump"),
+				attribute.String("db.operation.name", "SELECT"),
+				attribute.String("db.collection.name", "invoices"),
+			},
+			status: codes.Error,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			recorder := tracingtest.Record(t)
+
+			func() (err error) {
+				_, span := StartSpan(context.Background(), "invoice.Repository.GetByID", OperationSelect, test.table)
+				defer EndSpan(span, &err)
+				return test.err
+			}()
+
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			assert.Equal(t, "invoice.Repository.GetByID", spans[0].Name())
+			assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+			assert.Equal(t, test.attributes, spans[0].Attributes())
+			assert.Equal(t, test.status, spans[0].Status().Code)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderTenantID selects the tenant of a request made with credentials that are not bound to a tenant.
+const HeaderTenantID = "X-Tenant-Id"
+
+var (
+	ErrTenantRequired   = errors.New("tenant required")
+	ErrTenantNotAllowed = errors.New("tenant not allowed")
+)
+
+// Resolve returns the tenant of a call made by the principal of ctx, id is the tenant the call selects. The tenant of
+// the principal takes precedence, id is only used by principals that are not bound to a tenant.
+func Resolve(ctx context.Context, tenantRepository interface {
+	GetByID(ctx context.Context, id string) (*Tenant, error)
+}, id string,
+) (*Tenant, error) {
+	if p, ok := auth.FromContext(ctx); ok && p.TenantID != "" {
+		if id != "" && id != p.TenantID {
+			return nil, ErrTenantNotAllowed
+		}
+		id = p.TenantID
+	}
+	if id == "" {
+		return nil, ErrTenantRequired
+	}
+
+	t, err := tenantRepository.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrTenantNotFound) {
+			return nil, ErrTenantNotAllowed
+		}
+		return nil, fmt.Errorf("tenantRepository.GetByID: %w", err)
+	}
+	return t, nil
+}
+
+// Middleware resolves the tenant of the request from the principal or the HeaderTenantID header and stores it in the
+// request context, it must run after auth.Middleware.
+func Middleware(tenantRepository *Repository) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			t, err := Resolve(ctx, tenantRepository, c.Request().Header.Get(HeaderTenantID))
+			if err != nil {
+				switch {
+				case errors.Is(err, ErrTenantNotAllowed):
+					return problem.New(http.StatusForbidden, problem.CodeTenantNotAllowed, "tenant not allowed")
+				case errors.Is(err, ErrTenantRequired):
+					return problem.New(http.StatusBadRequest, problem.CodeTenantRequired, "tenant required")
+				}
+				return err
+			}
+
+			c.SetRequest(c.Request().WithContext(NewContext(ctx, t)))
+			return next(c)
+		}
+	}
+}
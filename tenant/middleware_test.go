@@ -0,0 +1,72 @@
+package tenant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	query := "SELECT id, name, number_format, currency, tax_rate, buyer_country, seller_name, seller_vat_id, seller_street, seller_postal_code, seller_city, seller_country, seller_iban FROM jump.public.tenants WHERE id = $1"
+	columns := []string{"id", "name", "number_format", "currency", "tax_rate", "buyer_country", "seller_name", "seller_vat_id", "seller_street", "seller_postal_code", "seller_city", "seller_country", "seller_iban"}
+
+	handler := Middleware(NewTenantRepository(db))(func(c echo.Context) error {
+		t, _ := FromContext(c.Request().Context())
+		return c.String(http.StatusOK, t.ID+" "+*t.Settings.Currency)
+	})
+
+	for name, test := range map[string]struct {
+		principal *auth.Principal
+		header    string
+		lookup    string
+		found     bool
+		status    int
+	}{
+		"bound principal":        {principal: &auth.Principal{TenantID: "acme"}, lookup: "acme", found: true, status: http.StatusOK},
+		"same header":            {principal: &auth.Principal{TenantID: "acme"}, header: "acme", lookup: "acme", found: true, status: http.StatusOK},
+		"other header":           {principal: &auth.Principal{TenantID: "acme"}, header: "globex", status: http.StatusForbidden},
+		"unbound with header":    {principal: &auth.Principal{}, header: "globex", lookup: "globex", found: true, status: http.StatusOK},
+		"unbound without header": {principal: &auth.Principal{}, status: http.StatusBadRequest},
+		"unknown tenant":         {principal: &auth.Principal{}, header: "initech", lookup: "initech", status: http.StatusForbidden},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if test.lookup != "" {
+				rows := sqlmock.NewRows(columns)
+				if test.found {
+					rows.AddRow(test.lookup, test.lookup, nil, "USD", nil, nil, nil, nil, nil, nil, nil, nil, nil)
+				}
+				mock.ExpectQuery(query).WithArgs(test.lookup).WillReturnRows(rows)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req = req.WithContext(auth.NewContext(req.Context(), test.principal))
+			if test.header != "" {
+				req.Header.Set(HeaderTenantID, test.header)
+			}
+			rec := httptest.NewRecorder()
+			err := handler(echo.New().NewContext(req, rec))
+
+			if test.status == http.StatusOK {
+				require.NoError(t, err)
+				assert.Equal(t, test.lookup+" USD", rec.Body.String())
+			} else {
+				var problemErr *problem.Error
+				require.ErrorAs(t, err, &problemErr)
+				assert.Equal(t, test.status, problemErr.Status)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
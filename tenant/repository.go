@@ -0,0 +1,45 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/emilien-puget/invoice_microservice/storage"
+)
+
+var ErrTenantNotFound = errors.New("tenant not found")
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewTenantRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) GetByID(ctx context.Context, id string) (_ *Tenant, err error) {
+	ctx, span := storage.StartSpan(ctx, "tenant.Repository.GetByID", storage.OperationSelect, "tenants")
+	defer storage.EndSpan(span, &err)
+
+	query := `
+		SELECT id, name, number_format, currency, tax_rate, buyer_country,
+			seller_name, seller_vat_id, seller_street, seller_postal_code, seller_city, seller_country, seller_iban
+		FROM jump.public.tenants
+		WHERE id = $1
+	`
+
+	t := &Tenant{}
+	s := &t.Settings
+	err = r.db.QueryRowContext(ctx, storage.Dialect(r.db, query), id).Scan(&t.ID, &t.Name, &s.NumberFormat, &s.Currency, &s.TaxRate, &s.BuyerCountry,
+		&s.SellerName, &s.SellerVatID, &s.SellerStreet, &s.SellerPostalCode, &s.SellerCity, &s.SellerCountry, &s.SellerIban)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, fmt.Errorf("failed to get tenant: %w", err)
+	}
+
+	return t, nil
+}
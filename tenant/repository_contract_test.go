@@ -0,0 +1,42 @@
+package tenant
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantRepository_Contract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, ctx context.Context, db *sql.DB) {
+		conn := storage.Conn(ctx, db)
+		_, err := conn.ExecContext(ctx, `INSERT INTO tenants (id, name) VALUES ('storagetest', 'Storage test')`)
+		require.NoError(t, err)
+
+		// Each statement runs in a savepoint, a failed one aborts the transaction on PostgreSQL.
+		exec := func(query string, args ...any) error {
+			_, err := conn.ExecContext(ctx, `SAVEPOINT number_format`)
+			require.NoError(t, err)
+			_, execErr := conn.ExecContext(ctx, query, args...)
+			_, err = conn.ExecContext(ctx, `ROLLBACK TO SAVEPOINT number_format`)
+			require.NoError(t, err)
+			return execErr
+		}
+
+		for format, valid := range numberFormats {
+			insertErr := exec(`INSERT INTO tenants (id, name, number_format) VALUES ('inserted', 'Inserted', $1)`, format)
+			updateErr := exec(`UPDATE tenants SET number_format = $1 WHERE id = 'storagetest'`, format)
+			if valid {
+				assert.NoError(t, insertErr, format)
+				assert.NoError(t, updateErr, format)
+				continue
+			}
+			assert.Error(t, insertErr, "the database rejects %q as CheckNumberFormat does", format)
+			assert.Error(t, updateErr, "the database rejects %q as CheckNumberFormat does", format)
+		}
+	})
+}
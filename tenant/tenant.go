@@ -0,0 +1,69 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Tenant is an organization sharing the deployment, its data is isolated from the other tenants.
+type Tenant struct {
+	ID       string
+	Name     string
+	Settings Settings
+}
+
+// Settings override the configuration for a tenant, nil fields keep the configured value.
+type Settings struct {
+	NumberFormat     *string
+	Currency         *string
+	TaxRate          *float64
+	BuyerCountry     *string
+	SellerName       *string
+	SellerVatID      *string
+	SellerStreet     *string
+	SellerPostalCode *string
+	SellerCity       *string
+	SellerCountry    *string
+	SellerIban       *string
+}
+
+// numberFormat matches the formats with exactly one verb, an integer one, %% being a literal percent sign. Migration
+// 0010 checks the same in the database.
+var numberFormat = regexp.MustCompile(`^([^%]|%%)*%[-+ #0]*[0-9]*(\.[0-9]*)?[dxXo]([^%]|%%)*$`)
+
+var ErrInvalidNumberFormat = errors.New("invalid number format, expected a single integer verb such as INV-%06d")
+
+// CheckNumberFormat returns ErrInvalidNumberFormat unless format renders the number of an invoice with fmt.Sprintf,
+// a format without a verb or with another one prints %!d(MISSING) or %!(EXTRA ...) on legal documents.
+func CheckNumberFormat(format string) error {
+	if !numberFormat.MatchString(format) {
+		return fmt.Errorf("%w: %q", ErrInvalidNumberFormat, format)
+	}
+	return nil
+}
+
+// ErrNoTenant is returned by the repositories when they are called without a tenant, so that no query can read
+// across tenants by mistake.
+var ErrNoTenant = errors.New("no tenant in context")
+
+type tenantKey struct{}
+
+func NewContext(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, t)
+}
+
+func FromContext(ctx context.Context) (*Tenant, bool) {
+	t, ok := ctx.Value(tenantKey{}).(*Tenant)
+	return t, ok
+}
+
+// ID returns the ID of the tenant of ctx, repositories filter every query with it.
+func ID(ctx context.Context) (string, error) {
+	t, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrNoTenant
+	}
+	return t.ID, nil
+}
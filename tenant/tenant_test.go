@@ -0,0 +1,41 @@
+package tenant
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// numberFormats are the formats checked by CheckNumberFormat and by the database, mapped to whether they are valid.
+var numberFormats = map[string]bool{
+	"INV-%06d":      true,
+	"%d":            true,
+	"%x/2024":       true,
+	"100%% - %-8d.": true,
+	"%.4d":          true,
+	"INV":           false,
+	"INV-%s-%d":     false,
+	"INV-%d-%d":     false,
+	"INV-%s":        false,
+	"INV-%06d%":     false,
+	"%%d":           false,
+	"":              false,
+}
+
+func TestCheckNumberFormat(t *testing.T) {
+	for format, valid := range numberFormats {
+		t.Run(format, func(t *testing.T) {
+			err := CheckNumberFormat(format)
+			if !valid {
+				assert.ErrorIs(t, err, ErrInvalidNumberFormat)
+				return
+			}
+			assert.NoError(t, err)
+			rendered := fmt.Sprintf(format, 42)
+			assert.NotContains(t, rendered, "%!", "the format renders the number")
+			assert.False(t, strings.Contains(rendered, "MISSING") || strings.Contains(rendered, "EXTRA"))
+		})
+	}
+}
@@ -0,0 +1,28 @@
+// Package tenanttest provides the tenant fixtures shared by the tests of the other packages.
+package tenanttest
+
+import (
+	"context"
+
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/labstack/echo/v4"
+)
+
+// ID is the tenant of Context.
+const ID = "acme"
+
+// Context returns a context carrying the tenant ID, as the ones handed to the repositories by the handlers.
+func Context() context.Context {
+	return tenant.NewContext(context.Background(), &tenant.Tenant{ID: ID})
+}
+
+// Middleware stands for tenant.Middleware, it puts the tenant of the principal in the request context without
+// loading it.
+func Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		p, _ := auth.FromEcho(c)
+		c.SetRequest(c.Request().WithContext(tenant.NewContext(c.Request().Context(), &tenant.Tenant{ID: p.TenantID})))
+		return next(c)
+	}
+}
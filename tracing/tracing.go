@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// The exporters of the spans.
+const (
+	// ExporterOTLP sends the spans to an OTLP collector over gRPC, configured by the OTEL_EXPORTER_OTLP_* variables.
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes the spans to w as JSON.
+	ExporterStdout = "stdout"
+	// ExporterNone records no span, the trace context is still propagated.
+	ExporterNone = "none"
+)
+
+var ErrUnknownExporter = errors.New("unknown trace exporter")
+
+// Setup installs the global tracer provider exporting the spans with exporter and the W3C trace context and baggage
+// propagators. The returned function flushes the spans left and stops the provider.
+func Setup(ctx context.Context, exporter string, w io.Writer, serviceName, version string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case ExporterOTLP:
+		spanExporter, err = otlptracegrpc.New(ctx)
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExporter, exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s exporter: %w", exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName), semconv.ServiceVersion(version))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
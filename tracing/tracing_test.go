@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	t.Run("stdout", func(t *testing.T) {
+		var buf bytes.Buffer
+		shutdown, err := Setup(context.Background(), ExporterStdout, &buf, "invoice_microservice", "1.2.3")
+		require.NoError(t, err)
+
+		_, span := otel.Tracer("test").Start(context.Background(), "invoice.Repository.GetByID")
+		span.End()
+		require.NoError(t, shutdown(context.Background()))
+
+		type keyValue struct {
+			Key   string
+			Value struct{ Value any }
+		}
+		var exported struct {
+			Name     string
+			Resource []keyValue
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &exported))
+		assert.Equal(t, "invoice.Repository.GetByID", exported.Name)
+		serviceName := keyValue{Key: "service.name"}
+		serviceName.Value.Value = "invoice_microservice"
+		assert.Contains(t, exported.Resource, serviceName)
+		assert.ElementsMatch(t, []string{"traceparent", "tracestate", "baggage"}, otel.GetTextMapPropagator().Fields())
+	})
+
+	t.Run("none", func(t *testing.T) {
+		shutdown, err := Setup(context.Background(), ExporterNone, nil, "invoice_microservice", "1.2.3")
+		require.NoError(t, err)
+		assert.NoError(t, shutdown(context.Background()))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := Setup(context.Background(), "zipkin", nil, "invoice_microservice", "1.2.3")
+		assert.ErrorIs(t, err, ErrUnknownExporter)
+	})
+}
@@ -9,13 +9,13 @@ import (
 )
 
 type GetAllHandler struct {
-	userRepository interface {
-		GetAll(ctx context.Context) ([]*User, error)
+	users interface {
+		List(ctx context.Context) ([]*User, error)
 	}
 }
 
-func NewGetAllHandler(userRepository *Repository) *GetAllHandler {
-	return &GetAllHandler{userRepository: userRepository}
+func NewGetAllHandler(users *Users) *GetAllHandler {
+	return &GetAllHandler{users: users}
 }
 
 type GetUsersHandlerResponse struct {
@@ -27,9 +27,9 @@ type GetUsersHandlerResponse struct {
 
 func (g GetAllHandler) Handle(c echo.Context) error {
 	ctx := c.Request().Context()
-	users, err := g.userRepository.GetAll(ctx)
+	users, err := g.users.List(ctx)
 	if err != nil {
-		return fmt.Errorf("userRepository.GetAll: %w", err)
+		return fmt.Errorf("users.List: %w", err)
 	}
 
 	results := make([]GetUsersHandlerResponse, len(users))
@@ -4,8 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
+	"github.com/emilien-puget/invoice_microservice/auth"
 	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant"
 )
 
 type Repository struct {
@@ -21,22 +25,32 @@ type User struct {
 	FirstName string
 	LastName  string
 	Balance   money.Money
+	// CreatedAt is only loaded by Export.
+	CreatedAt time.Time
 }
 
-func (r *Repository) ModifyBalance(ctx context.Context, userID int64, amount money.Money) error {
+func (r *Repository) ModifyBalance(ctx context.Context, userID int64, amount money.Money) (err error) {
+	ctx, span := storage.StartSpan(ctx, "user.Repository.ModifyBalance", storage.OperationUpdate, "users")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE jump.public.users
 		SET balance = balance + $1
-		WHERE id = $2
+		WHERE id = $2 AND tenant_id = $3
 	`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := storage.Conn(ctx, r.db).PrepareContext(ctx, storage.Dialect(r.db, query))
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, amount, userID)
+	_, err = stmt.ExecContext(ctx, amount, userID, tenantID)
 	if err != nil {
 		return err
 	}
@@ -44,20 +58,28 @@ func (r *Repository) ModifyBalance(ctx context.Context, userID int64, amount mon
 	return nil
 }
 
-func (r *Repository) Update(ctx context.Context, user *User) error {
+func (r *Repository) Update(ctx context.Context, user *User) (err error) {
+	ctx, span := storage.StartSpan(ctx, "user.Repository.Update", storage.OperationUpdate, "users")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE jump.public.users
-		SET first_name = ?, last_name = ?, balance = ?
-		WHERE id = ?
+		SET first_name = $1, last_name = $2, balance = $3
+		WHERE id = $4 AND tenant_id = $5
 	`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := storage.Conn(ctx, r.db).PrepareContext(ctx, storage.Dialect(r.db, query))
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.ExecContext(ctx, user.FirstName, user.LastName, user.Balance, user.ID)
+	_, err = stmt.ExecContext(ctx, user.FirstName, user.LastName, user.Balance, user.ID, tenantID)
 	if err != nil {
 		return err
 	}
@@ -67,21 +89,30 @@ func (r *Repository) Update(ctx context.Context, user *User) error {
 
 var ErrUserNotFound = errors.New("user not found")
 
-func (r *Repository) GetById(ctx context.Context, id int64) (*User, error) {
+// GetById returns ErrUserNotFound for the users the principal of ctx may not read.
+func (r *Repository) GetById(ctx context.Context, id int64) (_ *User, err error) {
+	ctx, span := storage.StartSpan(ctx, "user.Repository.GetById", storage.OperationSelect, "users")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT id, first_name, last_name, balance
 		FROM jump.public.users
-		WHERE id = $1
+		WHERE id = $1 AND tenant_id = $2 AND ($3 = 0 OR id = $3)
 	`
 
-	stmt, err := r.db.PrepareContext(ctx, query)
+	stmt, err := storage.Conn(ctx, r.db).PrepareContext(ctx, storage.Dialect(r.db, query))
 	if err != nil {
 		return nil, err
 	}
 	defer stmt.Close()
 
 	user := &User{}
-	err = stmt.QueryRow(id).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Balance)
+	err = stmt.QueryRowContext(ctx, id, tenantID, auth.ScopedUserID(ctx)).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Balance)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrUserNotFound
@@ -92,13 +123,23 @@ func (r *Repository) GetById(ctx context.Context, id int64) (*User, error) {
 	return user, nil
 }
 
-func (r *Repository) GetAll(ctx context.Context) ([]*User, error) {
+// GetAll returns the users the principal of ctx may read.
+func (r *Repository) GetAll(ctx context.Context) (_ []*User, err error) {
+	ctx, span := storage.StartSpan(ctx, "user.Repository.GetAll", storage.OperationSelect, "users")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
 		SELECT id, first_name, last_name, balance
 		FROM jump.public.users
+		WHERE tenant_id = $1 AND ($2 = 0 OR id = $2)
 	`
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := storage.Conn(ctx, r.db).QueryContext(ctx, storage.Dialect(r.db, query), tenantID, auth.ScopedUserID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -120,3 +161,40 @@ func (r *Repository) GetAll(ctx context.Context) ([]*User, error) {
 
 	return users, nil
 }
+
+// Export calls fn for every user created in [from, to), rows are handed over as they are read.
+func (r *Repository) Export(ctx context.Context, from, to time.Time, fn func(*User) error) (err error) {
+	ctx, span := storage.StartSpan(ctx, "user.Repository.Export", storage.OperationSelect, "users")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		SELECT id, first_name, last_name, balance, created_at
+		FROM jump.public.users
+		WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY id
+	`
+
+	rows, err := storage.Conn(ctx, r.db).QueryContext(ctx, storage.Dialect(r.db, query), tenantID, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	user := &User{}
+	for rows.Next() {
+		err := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Balance, &user.CreatedAt)
+		if err != nil {
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,62 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/auth"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/storage/storagetest"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserRepository_Contract(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, ctx context.Context, db *sql.DB) {
+		conn := storage.Conn(ctx, db)
+		_, err := conn.ExecContext(ctx, `INSERT INTO tenants (id, name) VALUES ('storagetest', 'Storage test'), ('other', 'Other')`)
+		require.NoError(t, err)
+		ids := make([]int64, 3)
+		for i, tenantID := range []string{"storagetest", "storagetest", "other"} {
+			err = conn.QueryRowContext(ctx, `INSERT INTO users (first_name, last_name, balance, tenant_id) VALUES ('Ada', 'Lovelace', 1000, $1) RETURNING id`, tenantID).Scan(&ids[i])
+			require.NoError(t, err)
+		}
+		ctx = tenant.NewContext(ctx, &tenant.Tenant{ID: "storagetest"})
+		repo := NewUserRepository(db)
+
+		require.NoError(t, repo.ModifyBalance(ctx, ids[0], -250))
+		require.NoError(t, repo.Update(ctx, &User{ID: ids[1], FirstName: "Grace", LastName: "Hopper", Balance: 500}))
+
+		got, err := repo.GetById(ctx, ids[0])
+		require.NoError(t, err)
+		assert.Equal(t, &User{ID: ids[0], FirstName: "Ada", LastName: "Lovelace", Balance: 750}, got)
+		_, err = repo.GetById(ctx, ids[2])
+		assert.ErrorIs(t, err, ErrUserNotFound, "the users of another tenant are not found")
+
+		all, err := repo.GetAll(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []*User{
+			{ID: ids[0], FirstName: "Ada", LastName: "Lovelace", Balance: 750},
+			{ID: ids[1], FirstName: "Grace", LastName: "Hopper", Balance: 500},
+		}, all)
+
+		customer := auth.NewContext(ctx, &auth.Principal{Role: auth.RoleCustomer, UserID: ids[1]})
+		all, err = repo.GetAll(customer)
+		require.NoError(t, err)
+		assert.Equal(t, []*User{{ID: ids[1], FirstName: "Grace", LastName: "Hopper", Balance: 500}}, all, "a customer only reads itself")
+		_, err = repo.GetById(customer, ids[0])
+		assert.ErrorIs(t, err, ErrUserNotFound)
+
+		var exported []int64
+		err = repo.Export(ctx, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), func(u *User) error {
+			assert.False(t, u.CreatedAt.IsZero())
+			exported = append(exported, u.ID)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, ids[:2], exported)
+	})
+}
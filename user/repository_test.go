@@ -4,9 +4,13 @@ import (
 	"context"
 	"database/sql/driver"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/auth"
 	"github.com/emilien-puget/invoice_microservice/money"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/emilien-puget/invoice_microservice/tenant/tenanttest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,16 +27,16 @@ func TestGetAllUsers(t *testing.T) {
 	repo := NewUserRepository(db)
 
 	// Define the expected query and rows for the GetAll() method
-	query := "SELECT id, first_name, last_name, balance FROM jump.public.users"
+	query := "SELECT id, first_name, last_name, balance FROM jump.public.users WHERE tenant_id = $1 AND ($2 = 0 OR id = $2)"
 	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "balance"}).
 		AddRow(1, "John", "Doe", 1000).
 		AddRow(2, "Jane", "Smith", 2000)
 
 	// Expect the query to be executed and return the mocked rows
-	mock.ExpectQuery(query).WillReturnRows(rows)
+	mock.ExpectQuery(query).WithArgs("acme", 0).WillReturnRows(rows)
 
 	// Call the GetAll() method
-	users, err := repo.GetAll(context.Background())
+	users, err := repo.GetAll(tenanttest.Context())
 	require.NoError(t, err)
 
 	// Assert the expected number of users
@@ -67,8 +71,8 @@ func TestGetUserById(t *testing.T) {
 	repo := NewUserRepository(db)
 
 	// Define the expected query, arguments, and rows for the GetById() method
-	query := "SELECT id, first_name, last_name, balance FROM jump.public.users WHERE id = $1"
-	args := []driver.Value{1}
+	query := "SELECT id, first_name, last_name, balance FROM jump.public.users WHERE id = $1 AND tenant_id = $2 AND ($3 = 0 OR id = $3)"
+	args := []driver.Value{1, "acme", 0}
 	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "balance"}).AddRow(1, "John", "Doe", 1000)
 
 	// Expect the query to be executed and return the mocked rows
@@ -76,7 +80,7 @@ func TestGetUserById(t *testing.T) {
 	mock.ExpectQuery(query).WithArgs(args...).WillReturnRows(rows)
 
 	// Call the GetById() method
-	user, err := repo.GetById(context.Background(), 1)
+	user, err := repo.GetById(tenanttest.Context(), 1)
 	require.NoError(t, err)
 
 	// Assert the properties of the user
@@ -90,6 +94,28 @@ func TestGetUserById(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestGetUserById_OtherCustomer(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+	ctx := auth.NewContext(tenanttest.Context(), &auth.Principal{Role: auth.RoleCustomer, UserID: 1, TenantID: "acme"})
+
+	// The read is scoped to the customer so the row of user 2 is filtered out
+	query := "SELECT id, first_name, last_name, balance FROM jump.public.users WHERE id = $1 AND tenant_id = $2 AND ($3 = 0 OR id = $3)"
+	mock.ExpectPrepare(query)
+	mock.ExpectQuery(query).WithArgs(2, "acme", 1).WillReturnRows(sqlmock.NewRows([]string{"id", "first_name", "last_name", "balance"}))
+
+	_, err = repo.GetById(ctx, 2)
+	require.ErrorIs(t, err, ErrUserNotFound)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
 func TestUpdateUser(t *testing.T) {
 	// Create a new mock database connection
 	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -102,8 +128,8 @@ func TestUpdateUser(t *testing.T) {
 	repo := NewUserRepository(db)
 
 	// Define the expected query, arguments, and rows for the Update() method
-	query := "UPDATE jump.public.users SET first_name = ?, last_name = ?, balance = ? WHERE id = ?"
-	args := []driver.Value{"John", "Doe", int64(2000), 1}
+	query := "UPDATE jump.public.users SET first_name = $1, last_name = $2, balance = $3 WHERE id = $4 AND tenant_id = $5"
+	args := []driver.Value{"John", "Doe", int64(2000), 1, "acme"}
 
 	// Expect the query to be executed
 	mock.ExpectPrepare(query)
@@ -118,10 +144,59 @@ func TestUpdateUser(t *testing.T) {
 	}
 
 	// Call the Update() method
-	err = repo.Update(context.Background(), user)
+	err = repo.Update(tenanttest.Context(), user)
 	assert.NoError(t, err)
 
 	// Ensure all expectations were met
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
+
+func TestExportUsers(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatalf("Error creating mock database connection: %v", err)
+	}
+	defer db.Close()
+
+	// Create a new UserRepository with the mock database connection
+	repo := NewUserRepository(db)
+
+	from := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2023, 7, 2, 0, 0, 0, 0, time.UTC)
+
+	// Define the expected query and rows for the Export() method
+	query := "SELECT id, first_name, last_name, balance, created_at FROM jump.public.users WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3 ORDER BY id"
+	rows := sqlmock.NewRows([]string{"id", "first_name", "last_name", "balance", "created_at"}).
+		AddRow(1, "John", "Doe", 1000, createdAt).
+		AddRow(2, "Jane", "Smith", 2000, createdAt)
+
+	// Expect the query to be executed and return the mocked rows
+	mock.ExpectQuery(query).WithArgs("acme", from, to).WillReturnRows(rows)
+
+	// Call the Export() method
+	var names []string
+	err = repo.Export(tenanttest.Context(), from, to, func(user *User) error {
+		names = append(names, user.FirstName)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"John", "Jane"}, names)
+
+	// Ensure all expectations were met
+	err = mock.ExpectationsWereMet()
+	require.NoError(t, err)
+}
+
+func TestGetUserById_NoTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Without a tenant the repository refuses to query
+	_, err = NewUserRepository(db).GetById(context.Background(), 1)
+	require.ErrorIs(t, err, tenant.ErrNoTenant)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,26 @@
+package user
+
+import (
+	"context"
+	"fmt"
+)
+
+// Users reads the users, whether for the REST or the gRPC API.
+type Users struct {
+	userRepository interface {
+		GetAll(ctx context.Context) ([]*User, error)
+	}
+}
+
+func NewUsers(userRepository *Repository) *Users {
+	return &Users{userRepository: userRepository}
+}
+
+// List returns the users the principal of ctx may read.
+func (u *Users) List(ctx context.Context) ([]*User, error) {
+	users, err := u.userRepository.GetAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("userRepository.GetAll: %w", err)
+	}
+	return users, nil
+}
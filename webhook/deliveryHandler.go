@@ -0,0 +1,100 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/labstack/echo/v4"
+)
+
+type DeliveryResponse struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int64      `json:"subscription_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+func newDeliveryResponse(delivery Delivery) DeliveryResponse {
+	response := DeliveryResponse{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+	if delivery.Status == StatusPending {
+		response.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	return response
+}
+
+type ListDeliveriesHandler struct {
+	repository interface {
+		Deliveries(ctx context.Context, status string) ([]Delivery, error)
+	}
+}
+
+func NewListDeliveriesHandler(repository *Repository) *ListDeliveriesHandler {
+	return &ListDeliveriesHandler{repository: repository}
+}
+
+// Handle lists the latest deliveries, ?status=dead lists the dead letters.
+func (h ListDeliveriesHandler) Handle(c echo.Context) error {
+	status := c.QueryParam("status")
+	if status != "" && status != StatusPending && status != StatusDelivered && status != StatusDead {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid status")
+	}
+
+	deliveries, err := h.repository.Deliveries(c.Request().Context(), status)
+	if err != nil {
+		return fmt.Errorf("repository.Deliveries: %w", err)
+	}
+
+	response := make([]DeliveryResponse, len(deliveries))
+	for i, delivery := range deliveries {
+		response[i] = newDeliveryResponse(delivery)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+type RedeliverHandler struct {
+	repository interface {
+		Redeliver(ctx context.Context, id int64) error
+	}
+}
+
+func NewRedeliverHandler(repository *Repository) *RedeliverHandler {
+	return &RedeliverHandler{repository: repository}
+}
+
+func (h RedeliverHandler) Handle(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid delivery id")
+	}
+
+	err = h.repository.Redeliver(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrDeliveryNotFound) {
+			return problem.New(http.StatusNotFound, problem.CodeWebhookDeliveryNotFound, "webhook delivery not found")
+		}
+		return fmt.Errorf("repository.Redeliver: %w", err)
+	}
+
+	return c.NoContent(http.StatusAccepted)
+}
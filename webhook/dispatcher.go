@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/logging"
+)
+
+// batchSize bounds the number of deliveries posted concurrently.
+const batchSize = 20
+
+// maxResponseBody bounds what is read of the responses of the subscribers, only their status matters.
+const maxResponseBody = 64 << 10
+
+var ErrUnexpectedStatus = errors.New("unexpected status")
+
+// RetryPolicy spaces the attempts of a delivery exponentially, it is dead once MaxAttempts failed.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Delay returns how long to wait after the failed attempt number attempt, starting at 1.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
+
+// Dispatcher posts the queued deliveries to the subscribers.
+type Dispatcher struct {
+	repository interface {
+		Claim(ctx context.Context, limit int, lease time.Duration) ([]Attempt, error)
+		Update(ctx context.Context, delivery Delivery) error
+	}
+	client   *http.Client
+	interval time.Duration
+	policy   RetryPolicy
+}
+
+func NewDispatcher(repository *Repository, timeout, interval time.Duration, policy RetryPolicy) *Dispatcher {
+	return &Dispatcher{
+		repository: repository,
+		client:     &http.Client{Timeout: timeout},
+		interval:   interval,
+		policy:     policy,
+	}
+}
+
+// Run polls the due deliveries every interval until ctx is done, the batch in flight then is delivered before
+// returning.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			workCtx := context.WithoutCancel(ctx)
+			for ctx.Err() == nil {
+				n, err := d.Dispatch(workCtx)
+				if err != nil {
+					logging.FromContext(ctx).Error("webhook dispatch failed", slog.Any("error", err))
+				}
+				if n < batchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Dispatch posts a batch of due deliveries and records their outcome, it returns the number of deliveries attempted.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	// The lease outlives the attempts, which are all bounded by the timeout of the client.
+	attempts, err := d.repository.Claim(ctx, batchSize, 2*d.client.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("repository.Claim: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, attempt := range attempts {
+		wg.Add(1)
+		go func(attempt Attempt) {
+			defer wg.Done()
+			d.deliver(ctx, attempt)
+		}(attempt)
+	}
+	wg.Wait()
+
+	return len(attempts), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, attempt Attempt) {
+	delivery := attempt.Delivery
+	delivery.Attempts++
+
+	err := d.post(ctx, attempt)
+	now := time.Now()
+	delivery.NextAttemptAt = now
+	switch {
+	case err == nil:
+		delivery.Status = StatusDelivered
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= d.policy.MaxAttempts:
+		delivery.Status = StatusDead
+		delivery.LastError = err.Error()
+	default:
+		delivery.Status = StatusPending
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(d.policy.Delay(delivery.Attempts))
+	}
+
+	if err := d.repository.Update(ctx, delivery); err != nil {
+		logging.FromContext(ctx).Error("webhook delivery not recorded", slog.Int64("delivery_id", delivery.ID), slog.Any("error", err))
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, attempt Attempt) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, attempt.URL, bytes.NewReader(attempt.Payload))
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, attempt.EventID)
+	req.Header.Set(HeaderEventType, attempt.EventType)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(attempt.Secret, timestamp, attempt.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+	return nil
+}
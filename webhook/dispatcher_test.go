@@ -0,0 +1,129 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRepository hands the attempts over once and records the updates.
+type fakeRepository struct {
+	mu       sync.Mutex
+	attempts []Attempt
+	updates  []Delivery
+}
+
+func (f *fakeRepository) Claim(_ context.Context, limit int, _ time.Duration) ([]Attempt, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := min(limit, len(f.attempts))
+	claimed := f.attempts[:n]
+	f.attempts = f.attempts[n:]
+	return claimed, nil
+}
+
+func (f *fakeRepository) Update(_ context.Context, delivery Delivery) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.updates = append(f.updates, delivery)
+	return nil
+}
+
+func testDispatcher(repository *fakeRepository) *Dispatcher {
+	return &Dispatcher{
+		repository: repository,
+		client:     &http.Client{Timeout: time.Second},
+		policy:     RetryPolicy{MaxAttempts: 3, Backoff: time.Minute, MaxBackoff: time.Hour},
+	}
+}
+
+func testAttempt(url string, attempts int) Attempt {
+	return Attempt{
+		Delivery: Delivery{ID: 1, SubscriptionID: 2, EventID: "evt_1", EventType: EventInvoicePaid, Status: StatusPending, Attempts: attempts},
+		TenantID: "acme",
+		Payload:  []byte(`{"id":"evt_1","type":"invoice.paid"}`),
+		URL:      url,
+		Secret:   "whsec_test",
+	}
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	repository := &fakeRepository{attempts: []Attempt{testAttempt(receiver.URL, 0)}}
+	n, err := testDispatcher(repository).Dispatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// The receiver gets the payload, signed with the secret of the subscription
+	require.NotNil(t, received)
+	assert.Equal(t, `{"id":"evt_1","type":"invoice.paid"}`, string(body))
+	assert.Equal(t, "evt_1", received.Header.Get(HeaderEventID))
+	assert.Equal(t, EventInvoicePaid, received.Header.Get(HeaderEventType))
+	require.NoError(t, Verify("whsec_test", received.Header.Get(HeaderTimestamp), received.Header.Get(HeaderSignature), body))
+	require.ErrorIs(t, Verify("whsec_other", received.Header.Get(HeaderTimestamp), received.Header.Get(HeaderSignature), body), ErrInvalidSignature)
+
+	require.Len(t, repository.updates, 1)
+	assert.Equal(t, StatusDelivered, repository.updates[0].Status)
+	assert.Equal(t, 1, repository.updates[0].Attempts)
+	assert.NotNil(t, repository.updates[0].DeliveredAt)
+}
+
+func TestDispatcher_Dispatch_Failure(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	t.Run("retried with backoff", func(t *testing.T) {
+		repository := &fakeRepository{attempts: []Attempt{testAttempt(receiver.URL, 1)}}
+		before := time.Now()
+		_, err := testDispatcher(repository).Dispatch(context.Background())
+		require.NoError(t, err)
+
+		require.Len(t, repository.updates, 1)
+		update := repository.updates[0]
+		assert.Equal(t, StatusPending, update.Status)
+		assert.Equal(t, 2, update.Attempts)
+		assert.Equal(t, "unexpected status: 500", update.LastError)
+		// The second failure waits twice the base backoff
+		assert.WithinDuration(t, before.Add(2*time.Minute), update.NextAttemptAt, 5*time.Second)
+	})
+
+	t.Run("dead once the attempts are exhausted", func(t *testing.T) {
+		repository := &fakeRepository{attempts: []Attempt{testAttempt(receiver.URL, 2)}}
+		_, err := testDispatcher(repository).Dispatch(context.Background())
+		require.NoError(t, err)
+
+		require.Len(t, repository.updates, 1)
+		assert.Equal(t, StatusDead, repository.updates[0].Status)
+		assert.Equal(t, 3, repository.updates[0].Attempts)
+		assert.Nil(t, repository.updates[0].DeliveredAt)
+	})
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, Backoff: 30 * time.Second, MaxBackoff: 5 * time.Minute}
+
+	assert.Equal(t, 30*time.Second, policy.Delay(1))
+	assert.Equal(t, time.Minute, policy.Delay(2))
+	assert.Equal(t, 4*time.Minute, policy.Delay(4))
+	assert.Equal(t, 5*time.Minute, policy.Delay(5))
+	assert.Equal(t, 5*time.Minute, policy.Delay(100))
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/outbox"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+)
+
+// The events a subscription can register for.
+const (
+	EventInvoiceCreated = "invoice.created"
+	EventInvoicePaid    = "invoice.paid"
+	EventInvoiceOverdue = "invoice.overdue"
+)
+
+// Events lists the event types, in the order they are documented. Refunds are not recorded by the service, there is
+// no event for them.
+var Events = []string{EventInvoiceCreated, EventInvoicePaid, EventInvoiceOverdue}
+
+// Event is the body posted to the subscribers.
+type Event struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	TenantID  string          `json:"tenant_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Publisher queues the messages of the outbox as events for the subscriptions of their tenant.
+type Publisher struct {
+	repository interface {
+		Enqueue(ctx context.Context, event Event) (int64, error)
+	}
+}
+
+func NewPublisher(repository *Repository) *Publisher {
+	return &Publisher{repository: repository}
+}
+
+// Publish queues the message, nothing is queued when no subscription registered for its type. The event id is
+// derived from the message so that a message published twice is delivered once.
+func (p *Publisher) Publish(ctx context.Context, message outbox.Message) error {
+	event := Event{
+		ID:        fmt.Sprintf("evt_%d", message.ID),
+		Type:      message.Type,
+		TenantID:  message.TenantID,
+		CreatedAt: message.CreatedAt.UTC(),
+		Data:      message.Payload,
+	}
+	ctx = tenant.NewContext(ctx, &tenant.Tenant{ID: message.TenantID})
+	if _, err := p.repository.Enqueue(ctx, event); err != nil {
+		return fmt.Errorf("repository.Enqueue: %w", err)
+	}
+	return nil
+}
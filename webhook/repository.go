@@ -0,0 +1,341 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant"
+	"github.com/lib/pq"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusDead      = "dead"
+)
+
+type Subscription struct {
+	ID     int64
+	URL    string
+	Events []string
+	// Secret is only read back when the subscription is created.
+	Secret    string
+	CreatedAt time.Time
+}
+
+type Delivery struct {
+	ID             int64
+	SubscriptionID int64
+	EventID        string
+	EventType      string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+// Attempt is a delivery claimed by the dispatcher, with what it needs to post it.
+type Attempt struct {
+	Delivery
+	TenantID string
+	Payload  []byte
+	URL      string
+	Secret   string
+}
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+)
+
+// maxDeliveries bounds the number of deliveries listed at once.
+const maxDeliveries = 100
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) CreateSubscription(ctx context.Context, subscription Subscription) (_ int64, err error) {
+	ctx, span := storage.StartSpan(ctx, "webhook.Repository.CreateSubscription", storage.OperationInsert, "webhook_subscriptions")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		INSERT INTO jump.public.webhook_subscriptions (tenant_id, url, secret, events)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	var id int64
+	err = r.db.QueryRowContext(ctx, query, tenantID, subscription.URL, subscription.Secret, pq.Array(subscription.Events)).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return id, nil
+}
+
+func (r *Repository) Subscriptions(ctx context.Context) (_ []Subscription, err error) {
+	ctx, span := storage.StartSpan(ctx, "webhook.Repository.Subscriptions", storage.OperationSelect, "webhook_subscriptions")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, url, events, created_at
+		FROM jump.public.webhook_subscriptions
+		WHERE tenant_id = $1 AND deleted_at IS NULL
+		ORDER BY id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []Subscription{}
+	for rows.Next() {
+		var subscription Subscription
+		if err := rows.Scan(&subscription.ID, &subscription.URL, pq.Array(&subscription.Events), &subscription.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook subscription: %w", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook subscriptions: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// DeleteSubscription stops the deliveries of the subscription, the ones still pending are marked dead.
+func (r *Repository) DeleteSubscription(ctx context.Context, id int64) (err error) {
+	ctx, span := storage.StartSpan(ctx, "webhook.Repository.DeleteSubscription", storage.OperationUpdate, "webhook_subscriptions")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return storage.WithinTx(ctx, r.db, func(ctx context.Context) error {
+		conn := storage.Conn(ctx, r.db)
+
+		query := `
+			UPDATE jump.public.webhook_subscriptions
+			SET deleted_at = now()
+			WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		`
+
+		result, err := conn.ExecContext(ctx, query, id, tenantID)
+		if err != nil {
+			return fmt.Errorf("failed to delete webhook subscription: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			return ErrSubscriptionNotFound
+		}
+
+		query = `
+			UPDATE jump.public.webhook_deliveries
+			SET status = 'dead', last_error = 'subscription deleted'
+			WHERE subscription_id = $1 AND status = 'pending'
+		`
+
+		if _, err := conn.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("failed to cancel webhook deliveries: %w", err)
+		}
+		return nil
+	})
+}
+
+// Enqueue queues a delivery of the event for every subscription of the tenant registered for its type, it returns
+// the number of deliveries queued. An event already queued is not queued again.
+func (r *Repository) Enqueue(ctx context.Context, event Event) (_ int64, err error) {
+	ctx, span := storage.StartSpan(ctx, "webhook.Repository.Enqueue", storage.OperationInsert, "webhook_deliveries")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	query := `
+		INSERT INTO jump.public.webhook_deliveries (tenant_id, subscription_id, event_id, event_type, payload)
+		SELECT $1, id, $2, $3, $4
+		FROM jump.public.webhook_subscriptions
+		WHERE tenant_id = $1 AND deleted_at IS NULL AND $3 = ANY(events)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`
+
+	result, err := storage.Conn(ctx, r.db).ExecContext(ctx, query, tenantID, event.ID, event.Type, payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+	queued, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return queued, nil
+}
+
+// Deliveries returns the latest deliveries of the tenant, only the ones in status unless it is empty.
+func (r *Repository) Deliveries(ctx context.Context, status string) (_ []Delivery, err error) {
+	ctx, span := storage.StartSpan(ctx, "webhook.Repository.Deliveries", storage.OperationSelect, "webhook_deliveries")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, subscription_id, event_id, event_type, status, attempts, next_attempt_at, COALESCE(last_error, ''), created_at, delivered_at
+		FROM jump.public.webhook_deliveries
+		WHERE tenant_id = $1 AND ($2 = '' OR status::text = $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, tenantID, status, maxDeliveries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// Redeliver queues the delivery again with a fresh set of attempts, whatever its status.
+func (r *Repository) Redeliver(ctx context.Context, id int64) (err error) {
+	ctx, span := storage.StartSpan(ctx, "webhook.Repository.Redeliver", storage.OperationUpdate, "webhook_deliveries")
+	defer storage.EndSpan(span, &err)
+
+	tenantID, err := tenant.ID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE jump.public.webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = now(), last_error = NULL
+		WHERE id = $1 AND tenant_id = $2 AND subscription_id IN (
+			SELECT id FROM jump.public.webhook_subscriptions WHERE tenant_id = $2 AND deleted_at IS NULL
+		)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to redeliver webhook delivery: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrDeliveryNotFound
+	}
+
+	return nil
+}
+
+// Claim returns up to limit deliveries that are due, across every tenant. They are leased by pushing their next
+// attempt past lease, so that another replica does not post them at the same time.
+func (r *Repository) Claim(ctx context.Context, limit int, lease time.Duration) (_ []Attempt, err error) {
+	ctx, span := storage.StartSpan(ctx, "webhook.Repository.Claim", storage.OperationUpdate, "webhook_deliveries")
+	defer storage.EndSpan(span, &err)
+
+	query := `
+		UPDATE jump.public.webhook_deliveries d
+		SET next_attempt_at = now() + $2 * interval '1 millisecond'
+		FROM jump.public.webhook_subscriptions s
+		WHERE s.id = d.subscription_id AND d.id IN (
+			SELECT id
+			FROM jump.public.webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.tenant_id, d.subscription_id, d.event_id, d.event_type, d.status, d.attempts, d.created_at, d.payload, s.url, s.secret
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []Attempt
+	for rows.Next() {
+		var a Attempt
+		err := rows.Scan(&a.ID, &a.TenantID, &a.SubscriptionID, &a.EventID, &a.EventType, &a.Status, &a.Attempts, &a.CreatedAt, &a.Payload, &a.URL, &a.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate webhook deliveries: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// Update records the outcome of an attempt made by the dispatcher.
+func (r *Repository) Update(ctx context.Context, delivery Delivery) (err error) {
+	ctx, span := storage.StartSpan(ctx, "webhook.Repository.Update", storage.OperationUpdate, "webhook_deliveries")
+	defer storage.EndSpan(span, &err)
+
+	query := `
+		UPDATE jump.public.webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_error = NULLIF($5, ''), delivered_at = $6
+		WHERE id = $1
+	`
+
+	_, err = r.db.ExecContext(ctx, query, delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, delivery.DeliveredAt)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/outbox"
+	"github.com/emilien-puget/invoice_microservice/storage"
+	"github.com/emilien-puget/invoice_microservice/tenant/tenanttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_Publish(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	publisher := NewPublisher(NewWebhookRepository(db))
+
+	// Mock the expected query, a delivery is queued per subscription registered for the event
+	query := `
+		INSERT INTO jump.public.webhook_deliveries (tenant_id, subscription_id, event_id, event_type, payload)
+		SELECT $1, id, $2, $3, $4
+		FROM jump.public.webhook_subscriptions
+		WHERE tenant_id = $1 AND deleted_at IS NULL AND $3 = ANY(events)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`
+	var payload []byte
+	mock.ExpectExec(query).
+		WithArgs("acme", "evt_42", EventInvoiceCreated, argCapture{&payload}).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	// The tenant is taken from the message, the relay has none in its context
+	err = publisher.Publish(context.Background(), outbox.Message{
+		ID:            42,
+		TenantID:      "acme",
+		AggregateType: "invoice",
+		AggregateID:   "1",
+		Type:          EventInvoiceCreated,
+		Payload:       []byte(`{"invoice_id":1}`),
+		CreatedAt:     time.Date(2023, 7, 1, 10, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+
+	var event Event
+	require.NoError(t, json.Unmarshal(payload, &event))
+	assert.Equal(t, EventInvoiceCreated, event.Type)
+	assert.Equal(t, "acme", event.TenantID)
+	assert.Equal(t, "evt_42", event.ID)
+	assert.JSONEq(t, `{"invoice_id":1}`, string(event.Data))
+
+	// Ensure all expectations were met
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPublisher_Publish_WithinTx(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	publisher := NewPublisher(NewWebhookRepository(db))
+
+	// The deliveries are queued in the transaction of the relay, they are rolled back along with it
+	mock.ExpectBegin()
+	mock.ExpectExec(`
+		INSERT INTO jump.public.webhook_deliveries (tenant_id, subscription_id, event_id, event_type, payload)
+		SELECT $1, id, $2, $3, $4
+		FROM jump.public.webhook_subscriptions
+		WHERE tenant_id = $1 AND deleted_at IS NULL AND $3 = ANY(events)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`).WithArgs("acme", "evt_42", EventInvoiceCreated, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	err = storage.WithinTx(context.Background(), db, func(ctx context.Context) error {
+		if err := publisher.Publish(ctx, outbox.Message{ID: 42, TenantID: "acme", Type: EventInvoiceCreated, Payload: []byte(`{}`)}); err != nil {
+			return err
+		}
+		return errConnectionLost
+	})
+	require.ErrorIs(t, err, errConnectionLost)
+
+	// Ensure all expectations were met
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+var errConnectionLost = errors.New("connection lost")
+
+// argCapture matches any argument and keeps it.
+type argCapture struct {
+	value *[]byte
+}
+
+func (a argCapture) Match(v driver.Value) bool {
+	b, ok := v.([]byte)
+	*a.value = b
+	return ok
+}
+
+func TestRepository_Redeliver(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	repo := NewWebhookRepository(db)
+	query := `
+		UPDATE jump.public.webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = now(), last_error = NULL
+		WHERE id = $1 AND tenant_id = $2 AND subscription_id IN (
+			SELECT id FROM jump.public.webhook_subscriptions WHERE tenant_id = $2 AND deleted_at IS NULL
+		)
+	`
+
+	// Mock the expected query and result
+	mock.ExpectExec(query).WithArgs(1, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, repo.Redeliver(tenanttest.Context(), 1))
+
+	// The deliveries of the other tenants are not found
+	mock.ExpectExec(query).WithArgs(2, "acme").WillReturnResult(sqlmock.NewResult(0, 0))
+	require.ErrorIs(t, repo.Redeliver(tenanttest.Context(), 2), ErrDeliveryNotFound)
+
+	// Ensure all expectations were met
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_Update(t *testing.T) {
+	// Create a new mock database connection
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer db.Close()
+
+	next := time.Date(2023, 7, 1, 10, 0, 0, 0, time.UTC)
+
+	// Mock the expected query and result
+	mock.ExpectExec(`
+		UPDATE jump.public.webhook_deliveries
+		SET status = $2, attempts = $3, next_attempt_at = $4, last_error = NULLIF($5, ''), delivered_at = $6
+		WHERE id = $1
+	`).WithArgs(1, StatusDead, 8, next, "unexpected status: 500", nil).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = NewWebhookRepository(db).Update(context.Background(), Delivery{ID: 1, Status: StatusDead, Attempts: 8, NextAttemptAt: next, LastError: "unexpected status: 500"})
+	require.NoError(t, err)
+
+	// Ensure all expectations were met
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_DeleteSubscription(t *testing.T) {
+	const (
+		deleteQuery = `
+			UPDATE jump.public.webhook_subscriptions
+			SET deleted_at = now()
+			WHERE id = $1 AND tenant_id = $2 AND deleted_at IS NULL
+		`
+		cancelQuery = `
+			UPDATE jump.public.webhook_deliveries
+			SET status = 'dead', last_error = 'subscription deleted'
+			WHERE subscription_id = $1 AND status = 'pending'
+		`
+	)
+
+	for name, test := range map[string]struct {
+		expect  func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		"deleted": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteQuery).WithArgs(1, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(cancelQuery).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectCommit()
+			},
+		},
+		"not found": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteQuery).WithArgs(1, "acme").WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: ErrSubscriptionNotFound,
+		},
+		"deliveries not cancelled": {
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(deleteQuery).WithArgs(1, "acme").WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(cancelQuery).WithArgs(1).WillReturnError(errConnectionLost)
+				mock.ExpectRollback()
+			},
+			wantErr: errConnectionLost,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Create a new mock database connection
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer db.Close()
+
+			test.expect(mock)
+			err = NewWebhookRepository(db).DeleteSubscription(tenanttest.Context(), 1)
+			if test.wantErr != nil {
+				require.ErrorIs(t, err, test.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// Ensure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The headers sent with every delivery.
+const (
+	HeaderEventID   = "Webhook-Id"
+	HeaderEventType = "Webhook-Event"
+	HeaderTimestamp = "Webhook-Timestamp"
+	HeaderSignature = "Webhook-Signature"
+)
+
+const signaturePrefix = "sha256="
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the HMAC-SHA256 of the timestamp and the body, the timestamp is signed too so that a captured delivery
+// cannot be replayed later with a fresh one.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature header of a delivery, receivers written in Go can use it as is.
+func Verify(secret, timestamp, signature string, body []byte) error {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// GenerateSecret returns a new signing secret, it is only shown once when the subscription is created.
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("read random: %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+type SubscriptionResponse struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newSubscriptionResponse(subscription Subscription) SubscriptionResponse {
+	return SubscriptionResponse{
+		ID:        subscription.ID,
+		URL:       subscription.URL,
+		Events:    subscription.Events,
+		Secret:    subscription.Secret,
+		CreatedAt: subscription.CreatedAt,
+	}
+}
+
+type CreateSubscriptionHandler struct {
+	repository interface {
+		CreateSubscription(ctx context.Context, subscription Subscription) (int64, error)
+	}
+	validator *validator.Validate
+}
+
+func NewCreateSubscriptionHandler(validate *validator.Validate, repository *Repository) *CreateSubscriptionHandler {
+	return &CreateSubscriptionHandler{validator: validate, repository: repository}
+}
+
+type createSubscriptionPayload struct {
+	URL    string   `json:"url" validate:"required,http_url"`
+	Events []string `json:"events" validate:"required,min=1,dive,required"`
+}
+
+// Handle registers the subscription and returns its signing secret, it cannot be read afterward.
+func (h CreateSubscriptionHandler) Handle(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	payload := new(createSubscriptionPayload)
+	if err := c.Bind(payload); err != nil {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid request payload")
+	}
+
+	if err := h.validator.Struct(payload); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			return problem.Validation(validationErrors)
+		}
+		return fmt.Errorf("validator.Struct: %w", err)
+	}
+	for _, event := range payload.Events {
+		if !isEvent(event) {
+			return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, fmt.Sprintf("Invalid event, expected one of %s", strings.Join(Events, ", ")))
+		}
+	}
+
+	secret, err := GenerateSecret()
+	if err != nil {
+		return fmt.Errorf("GenerateSecret: %w", err)
+	}
+
+	subscription := Subscription{URL: payload.URL, Events: payload.Events, Secret: secret, CreatedAt: time.Now()}
+	subscription.ID, err = h.repository.CreateSubscription(ctx, subscription)
+	if err != nil {
+		return fmt.Errorf("repository.CreateSubscription: %w", err)
+	}
+
+	return c.JSON(http.StatusCreated, newSubscriptionResponse(subscription))
+}
+
+func isEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+type ListSubscriptionsHandler struct {
+	repository interface {
+		Subscriptions(ctx context.Context) ([]Subscription, error)
+	}
+}
+
+func NewListSubscriptionsHandler(repository *Repository) *ListSubscriptionsHandler {
+	return &ListSubscriptionsHandler{repository: repository}
+}
+
+func (h ListSubscriptionsHandler) Handle(c echo.Context) error {
+	subscriptions, err := h.repository.Subscriptions(c.Request().Context())
+	if err != nil {
+		return fmt.Errorf("repository.Subscriptions: %w", err)
+	}
+
+	response := make([]SubscriptionResponse, len(subscriptions))
+	for i, subscription := range subscriptions {
+		response[i] = newSubscriptionResponse(subscription)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+type DeleteSubscriptionHandler struct {
+	repository interface {
+		DeleteSubscription(ctx context.Context, id int64) error
+	}
+}
+
+func NewDeleteSubscriptionHandler(repository *Repository) *DeleteSubscriptionHandler {
+	return &DeleteSubscriptionHandler{repository: repository}
+}
+
+func (h DeleteSubscriptionHandler) Handle(c echo.Context) error {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return problem.New(http.StatusBadRequest, problem.CodeInvalidRequest, "Invalid subscription id")
+	}
+
+	err = h.repository.DeleteSubscription(c.Request().Context(), id)
+	if err != nil {
+		if errors.Is(err, ErrSubscriptionNotFound) {
+			return problem.New(http.StatusNotFound, problem.CodeWebhookSubscriptionNotFound, "webhook subscription not found")
+		}
+		return fmt.Errorf("repository.DeleteSubscription: %w", err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/emilien-puget/invoice_microservice/problem"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateSubscriptionHandler_InvalidEvent(t *testing.T) {
+	for name, event := range map[string]string{
+		"unknown event": "invoice.deleted",
+		// Refunds are not recorded, a subscription to them would never be called
+		"refund": "payment.refunded",
+	} {
+		t.Run(name, func(t *testing.T) {
+			// Create a new mock database connection, no query is expected
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer db.Close()
+
+			e := echo.New()
+			e.HTTPErrorHandler = problem.ErrorHandler
+			e.POST("/webhooks", NewCreateSubscriptionHandler(validator.New(), NewWebhookRepository(db)).Handle)
+			req := httptest.NewRequest(http.MethodPost, "/webhooks",
+				strings.NewReader(`{"url":"https://example.com/hook","events":["invoice.paid","`+event+`"]}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), "expected one of invoice.created, invoice.paid, invoice.overdue")
+			// Ensure all expectations were met
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}